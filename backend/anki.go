@@ -0,0 +1,365 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"dsa-revisit/sqlitefile"
+
+	"github.com/google/uuid"
+)
+
+// Anki export. Active problems become one note each (front: title,
+// difficulty and topic; back: link, notes and the latest revisit journal)
+// and flashcards become notes of their own. Note GUIDs are derived from our
+// IDs, so importing a newer export updates the cards already in Anki
+// instead of duplicating them.
+
+const (
+	ankiDeckName  = "DSA Revisit"
+	ankiModelName = "DSA Revisit"
+
+	// Fixed IDs so repeated imports reuse the same note type and deck
+	ankiModelID int64 = 1700000000001
+	ankiDeckID  int64 = 1700000000002
+)
+
+// ankiNote is one exported note; Front and Back are HTML.
+type ankiNote struct {
+	ID    int64 // milliseconds, also used for the card
+	GUID  string
+	Front string
+	Back  string
+	Tags  []string
+}
+
+// ankiProblem is a problem along with its latest revisit journal entry.
+type ankiProblem struct {
+	Problem
+	JournalAt    NullTime
+	JournalNotes string
+}
+
+const ankiGUIDChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!#$%&()*+,-./:;<=>?@[]^_`{|}~"
+
+// ankiGUID derives a stable note GUID from an ID, in Anki's base91 style.
+func ankiGUID(id uuid.UUID) string {
+	n := binary.BigEndian.Uint64(id[:8]) ^ binary.BigEndian.Uint64(id[8:])
+	if n == 0 {
+		return string(ankiGUIDChars[0])
+	}
+	var buf []byte
+	for n > 0 {
+		buf = append([]byte{ankiGUIDChars[n%91]}, buf...)
+		n /= 91
+	}
+	return string(buf)
+}
+
+// ankiTag turns a label into an Anki tag (tags can't contain spaces).
+func ankiTag(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), "_"))
+}
+
+// textToHTML escapes plain text for an Anki field, keeping line breaks.
+func textToHTML(s string) string {
+	return strings.ReplaceAll(html.EscapeString(strings.TrimSpace(s)), "\n", "<br>")
+}
+
+// problemAnkiNote builds the note for a problem.
+func problemAnkiNote(p ankiProblem) ankiNote {
+	front := `<div class="title">` + textToHTML(p.Title) + `</div>`
+	var meta []string
+	for _, s := range []string{p.Difficulty, p.Topic} {
+		if strings.TrimSpace(s) != "" {
+			meta = append(meta, textToHTML(s))
+		}
+	}
+	if len(meta) > 0 {
+		front += `<div class="meta">` + strings.Join(meta, " · ") + `</div>`
+	}
+
+	back := fmt.Sprintf(`<div class="link"><a href="%s">%s</a></div>`, html.EscapeString(p.Link), html.EscapeString(p.Link))
+	if strings.TrimSpace(p.Notes) != "" {
+		back += `<div class="notes">` + textToHTML(p.Notes) + `</div>`
+	}
+	if p.JournalAt.Valid && strings.TrimSpace(p.JournalNotes) != "" {
+		back += `<div class="journal"><b>Last revisit (` + p.JournalAt.Time.Format("2006-01-02") + `):</b><br>` +
+			textToHTML(p.JournalNotes) + `</div>`
+	}
+
+	tags := []string{"dsa-revisit"}
+	for _, s := range []string{p.Difficulty, p.Topic} {
+		if t := ankiTag(s); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return ankiNote{ID: p.DateAdded.UnixMilli(), GUID: ankiGUID(p.ID), Front: front, Back: back, Tags: tags}
+}
+
+// flashcardAnkiNote builds the note for a flashcard.
+func flashcardAnkiNote(c Flashcard) ankiNote {
+	return ankiNote{
+		ID:    c.CreatedAt.UnixMilli(),
+		GUID:  ankiGUID(c.ID),
+		Front: `<div class="prompt">` + textToHTML(c.Prompt) + `</div><div class="meta">` + textToHTML(c.ProblemTitle) + `</div>`,
+		Back:  `<div class="answer">` + textToHTML(c.Answer) + `</div>`,
+		Tags:  []string{"dsa-revisit", "insight"},
+	}
+}
+
+var htmlTagRe = regexp.MustCompile(`<[^>]*>`)
+
+// stripHTML approximates Anki's plain-text view of a field (sort field and checksum).
+func stripHTML(s string) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTagRe.ReplaceAllString(s, "")))
+}
+
+// ankiChecksum is Anki's first-field checksum: the first 8 hex digits of
+// the SHA-1 of the stripped field, as an integer.
+func ankiChecksum(field string) int64 {
+	sum := sha1.Sum([]byte(stripHTML(field)))
+	n, _ := strconv.ParseInt(hex.EncodeToString(sum[:4]), 16, 64)
+	return n
+}
+
+// uniqueNoteIDs makes note IDs distinct (they come from creation times),
+// nudging collisions forward by a millisecond.
+func uniqueNoteIDs(notes []ankiNote) {
+	used := make(map[int64]bool, len(notes))
+	for i := range notes {
+		for used[notes[i].ID] {
+			notes[i].ID++
+		}
+		used[notes[i].ID] = true
+	}
+}
+
+// ankiSchema is the collection schema (version 11) that Anki imports.
+var ankiSchema = []struct{ name, sql string }{
+	{"col", `CREATE TABLE col (id integer primary key, crt integer not null, mod integer not null, scm integer not null, ver integer not null, dty integer not null, usn integer not null, ls integer not null, conf text not null, models text not null, decks text not null, dconf text not null, tags text not null)`},
+	{"notes", `CREATE TABLE notes (id integer primary key, guid text not null, mid integer not null, mod integer not null, usn integer not null, tags text not null, flds text not null, sfld integer not null, csum integer not null, flags integer not null, data text not null)`},
+	{"cards", `CREATE TABLE cards (id integer primary key, nid integer not null, did integer not null, ord integer not null, mod integer not null, usn integer not null, type integer not null, queue integer not null, due integer not null, ivl integer not null, factor integer not null, reps integer not null, lapses integer not null, left integer not null, odue integer not null, odid integer not null, flags integer not null, data text not null)`},
+	{"revlog", `CREATE TABLE revlog (id integer primary key, cid integer not null, usn integer not null, ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null, type integer not null)`},
+	{"graves", `CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null)`},
+}
+
+// ankiCollectionJSON returns the col row's conf, models, decks and dconf.
+func ankiCollectionJSON(now time.Time, noteCount int) (conf, models, decks, dconf string) {
+	mod := now.Unix()
+	mustJSON := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	deck := func(id int64, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"id": id, "name": name, "mod": mod, "usn": 0, "desc": "", "dyn": 0, "conf": 1,
+			"collapsed": false, "browserCollapsed": false, "extendNew": 0, "extendRev": 0,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+		}
+	}
+	modelID := strconv.FormatInt(ankiModelID, 10)
+
+	conf = mustJSON(map[string]interface{}{
+		"nextPos": noteCount + 1, "estTimes": true, "activeDecks": []int64{ankiDeckID}, "sortType": "noteFld",
+		"timeLim": 0, "sortBackwards": false, "addToCur": true, "curDeck": ankiDeckID, "newSpread": 0,
+		"dueCounts": true, "curModel": modelID, "collapseTime": 1200,
+	})
+	models = mustJSON(map[string]interface{}{
+		modelID: map[string]interface{}{
+			"id": ankiModelID, "name": ankiModelName, "type": 0, "mod": mod, "usn": 0, "sortf": 0, "did": ankiDeckID,
+			"tmpls": []map[string]interface{}{{
+				"name": "Card 1", "ord": 0, "did": nil, "bqfmt": "", "bafmt": "",
+				"qfmt": "{{Front}}",
+				"afmt": "{{FrontSide}}\n\n<hr id=answer>\n\n{{Back}}",
+			}},
+			"flds": []map[string]interface{}{
+				{"name": "Front", "ord": 0, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []string{}},
+				{"name": "Back", "ord": 1, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []string{}},
+			},
+			"css": ".card { font-family: arial; font-size: 20px; text-align: center; color: black; background-color: white; }\n" +
+				".title, .prompt { font-weight: bold; }\n.meta { font-size: 14px; color: #666; }\n" +
+				".notes, .journal, .answer { text-align: left; margin-top: 12px; }",
+			"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+			"latexPost": "\\end{document}",
+			"latexsvg":  false,
+			"req":       []interface{}{[]interface{}{0, "any", []int{0}}},
+			"tags":      []string{},
+			"vers":      []int{},
+		},
+	})
+	decks = mustJSON(map[string]interface{}{
+		"1":                               deck(1, "Default"),
+		strconv.FormatInt(ankiDeckID, 10): deck(ankiDeckID, ankiDeckName),
+	})
+	dconf = mustJSON(map[string]interface{}{
+		"1": map[string]interface{}{
+			"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true, "timer": 0,
+			"replayq": true, "dyn": false,
+			"new": map[string]interface{}{
+				"delays": []float64{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500,
+				"order": 1, "perDay": 20, "bury": false,
+			},
+			"lapse": map[string]interface{}{
+				"delays": []float64{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0,
+			},
+			"rev": map[string]interface{}{
+				"perDay": 200, "ease4": 1.3, "fuzz": 0.05, "ivlFct": 1, "maxIvl": 36500, "bury": false, "minSpace": 1,
+			},
+		},
+	})
+	return
+}
+
+// buildAnkiCollection returns a collection.anki2 SQLite file holding notes,
+// each with one new card in the export deck.
+func buildAnkiCollection(notes []ankiNote, now time.Time) ([]byte, error) {
+	uniqueNoteIDs(notes)
+	mod := now.Unix()
+	conf, models, decks, dconf := ankiCollectionJSON(now, len(notes))
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	tables := make([]sqlitefile.Table, len(ankiSchema))
+	for i, t := range ankiSchema {
+		tables[i] = sqlitefile.Table{Name: t.name, SQL: t.sql}
+	}
+	tables[0].Rows = []sqlitefile.Row{{RowID: 1, Values: []interface{}{
+		nil, dayStart.Unix(), now.UnixMilli(), now.UnixMilli(), 11, 0, 0, 0, conf, models, decks, dconf, "{}",
+	}}}
+	for i, n := range notes {
+		tags := " " + strings.Join(n.Tags, " ") + " "
+		tables[1].Rows = append(tables[1].Rows, sqlitefile.Row{RowID: n.ID, Values: []interface{}{
+			nil, n.GUID, ankiModelID, mod, 0, tags, n.Front + "\x1f" + n.Back, stripHTML(n.Front), ankiChecksum(n.Front), 0, "",
+		}})
+		tables[2].Rows = append(tables[2].Rows, sqlitefile.Row{RowID: n.ID, Values: []interface{}{
+			nil, n.ID, ankiDeckID, 0, mod, 0, 0, 0, i + 1, 0, 0, 0, 0, 0, 0, 0, 0, "",
+		}})
+	}
+	return sqlitefile.Build(tables)
+}
+
+// buildAnkiPackage zips a collection into an .apkg (with an empty media map).
+func buildAnkiPackage(notes []ankiNote, now time.Time) ([]byte, error) {
+	collection, err := buildAnkiCollection(notes, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{"collection.anki2", collection}, {"media", []byte("{}")}} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ankiTSV renders notes in Anki's text import format (2.1.55+): GUID, front,
+// back and tags, with file headers so Anki picks the columns up itself.
+func ankiTSV(notes []ankiNote) string {
+	clean := func(s string) string {
+		return strings.NewReplacer("\t", " ", "\r", "", "\n", "<br>").Replace(s)
+	}
+	var b strings.Builder
+	b.WriteString("#separator:tab\n#html:true\n#notetype:Basic\n#deck:" + ankiDeckName + "\n")
+	b.WriteString("#guid column:1\n#tags column:4\n")
+	for _, n := range notes {
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\n", clean(n.GUID), clean(n.Front), clean(n.Back), strings.Join(n.Tags, " "))
+	}
+	return b.String()
+}
+
+// loadAnkiNotes loads the user's active problems and their flashcards as notes.
+func loadAnkiNotes(userID uuid.UUID) ([]ankiNote, error) {
+	rows, err := db.Query(`
+		SELECT `+problemColumnsFor("p")+`, j.revisited_at, COALESCE(j.notes, '')
+		FROM problems p
+		LEFT JOIN LATERAL (
+			SELECT revisited_at, notes FROM revisit_history
+			WHERE problem_id = p.id AND COALESCE(notes, '') <> ''
+			ORDER BY revisited_at DESC LIMIT 1
+		) j ON TRUE
+		WHERE p.user_id = $1 AND p.status = 'active'
+		ORDER BY p.date_added`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []ankiNote{}
+	for rows.Next() {
+		var p ankiProblem
+		if err := scanProblem(rows, &p.Problem, &p.JournalAt, &p.JournalNotes); err != nil {
+			return nil, err
+		}
+		notes = append(notes, problemAnkiNote(p))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	cards, err := queryFlashcards(userID, "p.status = 'active'")
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cards {
+		notes = append(notes, flashcardAnkiNote(c))
+	}
+	return notes, nil
+}
+
+// ExportAnkiPackage downloads active problems and flashcards as an Anki .apkg deck.
+func ExportAnkiPackage(w http.ResponseWriter, r *http.Request) {
+	notes, err := loadAnkiNotes(GetUserIDFromContext(r))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	pkg, err := buildAnkiPackage(notes, time.Now())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="dsa-revisit.apkg"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(pkg)
+}
+
+// ExportAnkiTSV downloads the same notes as a tab-separated file for Anki's text importer.
+func ExportAnkiTSV(w http.ResponseWriter, r *http.Request) {
+	notes, err := loadAnkiNotes(GetUserIDFromContext(r))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/tab-separated-values; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="dsa-revisit.tsv"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(ankiTSV(notes)))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAnkiGUID(t *testing.T) {
+	id := uuid.MustParse("6f1c2b9e-3a4d-4e5f-8a7b-1c2d3e4f5a6b")
+	if got := ankiGUID(id); got != "M5q)(OU_H/" {
+		t.Errorf("ankiGUID = %q, want %q", got, "M5q)(OU_H/")
+	}
+	if ankiGUID(id) != ankiGUID(uuid.MustParse(id.String())) {
+		t.Error("ankiGUID should be stable for the same ID")
+	}
+	if ankiGUID(uuid.New()) == ankiGUID(id) {
+		t.Error("different IDs should give different GUIDs")
+	}
+}
+
+func TestAnkiChecksum(t *testing.T) {
+	// Same as Anki: int(sha1("Two Sum").hexdigest()[:8], 16)
+	if got := ankiChecksum(`<div class="title">Two Sum</div>`); got != 1062249890 {
+		t.Errorf("ankiChecksum = %d, want 1062249890", got)
+	}
+}
+
+func TestProblemAnkiNote(t *testing.T) {
+	p := ankiProblem{
+		Problem: Problem{
+			ID: uuid.New(), Title: "Two Sum", Link: "https://leetcode.com/problems/two-sum/",
+			Difficulty: "Easy", Topic: "Arrays & Hashing", Notes: "Use a map\nof seen values",
+			DateAdded: time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+		},
+		JournalAt:    NullTime{sql.NullTime{Time: time.Date(2026, 2, 1, 8, 0, 0, 0, time.UTC), Valid: true}},
+		JournalNotes: "Forgot the <complement> check",
+	}
+	n := problemAnkiNote(p)
+
+	if n.Front != `<div class="title">Two Sum</div><div class="meta">Easy · Arrays &amp; Hashing</div>` {
+		t.Errorf("unexpected front: %s", n.Front)
+	}
+	for _, want := range []string{
+		`<a href="https://leetcode.com/problems/two-sum/">`,
+		`Use a map<br>of seen values`,
+		`Last revisit (2026-02-01):`,
+		`Forgot the &lt;complement&gt; check`,
+	} {
+		if !strings.Contains(n.Back, want) {
+			t.Errorf("back is missing %q: %s", want, n.Back)
+		}
+	}
+	if strings.Join(n.Tags, " ") != "dsa-revisit easy arrays_&_hashing" {
+		t.Errorf("unexpected tags: %v", n.Tags)
+	}
+	if n.GUID != ankiGUID(p.ID) || n.ID != p.DateAdded.UnixMilli() {
+		t.Errorf("unexpected GUID/ID: %s %d", n.GUID, n.ID)
+	}
+}
+
+func TestAnkiTSV(t *testing.T) {
+	got := ankiTSV([]ankiNote{{GUID: "abc", Front: "Q\tone", Back: "line1\nline2", Tags: []string{"dsa-revisit", "easy"}}})
+	want := "#separator:tab\n#html:true\n#notetype:Basic\n#deck:DSA Revisit\n#guid column:1\n#tags column:4\n" +
+		"abc\tQ one\tline1<br>line2\tdsa-revisit easy\n"
+	if got != want {
+		t.Errorf("ankiTSV =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestBuildAnkiPackage(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	notes := []ankiNote{
+		{ID: 1000, GUID: "a", Front: "A", Back: "a", Tags: []string{"dsa-revisit"}},
+		{ID: 1000, GUID: "b", Front: "B", Back: "b", Tags: []string{"dsa-revisit"}},
+	}
+	pkg, err := buildAnkiPackage(notes, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notes[1].ID != 1001 {
+		t.Errorf("colliding note IDs should be made unique, got %d", notes[1].ID)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(pkg), int64(len(pkg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	if !bytes.HasPrefix(files["collection.anki2"], []byte("SQLite format 3\x00")) {
+		t.Error("collection.anki2 is not a SQLite database")
+	}
+	if string(files["media"]) != "{}" {
+		t.Errorf("media = %q, want {}", files["media"])
+	}
+}
@@ -0,0 +1,638 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"dsa-revisit/openapi"
+)
+
+// The API's OpenAPI 3.1 description. Every route registered in newRouter has
+// an operation here (enforced by TestOpenAPICoversRoutes); requests are
+// validated against it by ValidateRequest and the document is served at
+// /api/openapi.json for generating client types.
+
+// apiBasePath is the prefix the documented paths are relative to.
+const apiBasePath = "/api"
+
+var apiSpec = buildAPISpec()
+
+// pathParamSchemas are the schemas of the path parameters, by name.
+var pathParamSchemas = map[string]func() *openapi.Schema{
+	"id":     func() *openapi.Schema { return openapi.String("uuid") },
+	"userID": func() *openapi.Schema { return openapi.String("uuid") },
+	"slug":   func() *openapi.Schema { return openapi.String() },
+	"token":  func() *openapi.Schema { return openapi.String() },
+	"kind":   func() *openapi.Schema { return openapi.Enum(feedKindCalendar, feedKindFocus, feedKindJournal) },
+	"version": func() *openapi.Schema {
+		return &openapi.Schema{Type: openapi.Types{"string"}, Pattern: `^([0-9]+|latest)$`}
+	},
+}
+
+func jsonContent(s *openapi.Schema) map[string]*openapi.MediaType {
+	return map[string]*openapi.MediaType{"application/json": {Schema: s}}
+}
+
+func jsonResponse(description string, s *openapi.Schema) *openapi.Response {
+	return &openapi.Response{Description: description, Content: jsonContent(s)}
+}
+
+func fileResponse(description string, contentTypes ...string) *openapi.Response {
+	content := make(map[string]*openapi.MediaType, len(contentTypes))
+	for _, t := range contentTypes {
+		content[t] = &openapi.MediaType{}
+	}
+	return &openapi.Response{Description: description, Content: content}
+}
+
+func jsonBody(s *openapi.Schema, required bool) *openapi.RequestBody {
+	return &openapi.RequestBody{Required: required, Content: jsonContent(s)}
+}
+
+func queryParam(name, description string, s *openapi.Schema) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: "query", Description: description, Schema: s}
+}
+
+func requiredQueryParam(name, description string, s *openapi.Schema) *openapi.Parameter {
+	p := queryParam(name, description, s)
+	p.Required = true
+	return p
+}
+
+// statusResponse is the {"status": ...} body most mutations return.
+func statusResponse(description string) *openapi.Response {
+	return jsonResponse(description, openapi.Ref("Status"))
+}
+
+var (
+	errorResponse       = jsonResponse("Error", openapi.Ref("Error"))
+	conflictResponse    = jsonResponse("Conflict", openapi.Ref("Error"))
+	notModifiedResponse = &openapi.Response{Description: "Not modified since the validator in If-None-Match or If-Modified-Since"}
+	publicAccess        = &[]openapi.SecurityRequirement{}
+	rateLimitedResponse = &openapi.Response{
+		Description: "Rate limit exceeded; retry after the given number of seconds",
+		Content:     jsonContent(openapi.Ref("Error")),
+		Headers: map[string]*openapi.Header{
+			"Retry-After":         {Description: "Seconds until a request will be accepted", Schema: openapi.Integer()},
+			"RateLimit-Limit":     {Description: "Requests the bucket holds when full", Schema: openapi.Integer()},
+			"RateLimit-Remaining": {Description: "Requests left in the bucket", Schema: openapi.Integer()},
+			"RateLimit-Reset":     {Description: "Seconds until the bucket is full again", Schema: openapi.Integer()},
+			"RateLimit-Policy":    {Description: `Bucket size and refill window, e.g. "120;w=60"`, Schema: openapi.String()},
+		},
+	}
+)
+
+// listQuery are the shared sorting and pagination parameters of list endpoints.
+func listQuery(sortFields map[string]sortField) []*openapi.Parameter {
+	names := make([]string, 0, len(sortFields))
+	for name := range sortFields {
+		names = append(names, name, "-"+name)
+	}
+	sort.Strings(names)
+	return []*openapi.Parameter{
+		queryParam("sort", "Sort field, prefixed with - for descending order", openapi.Enum(names...)),
+		queryParam("limit", "Page size; enables pagination", openapi.Integer().Between(1, maxPageSize)),
+		queryParam("cursor", "Opaque cursor from the Link header of the previous page", openapi.String()),
+	}
+}
+
+func listHeaders() map[string]*openapi.Header {
+	return map[string]*openapi.Header{
+		"Link":          {Description: `Next page as <url>; rel="next"`, Schema: openapi.String()},
+		"X-Total-Count": {Description: "Number of matching rows", Schema: openapi.Integer()},
+	}
+}
+
+func buildAPISpec() *openapi.Document {
+	var (
+		str, integer, boolean    = openapi.String, openapi.Integer, openapi.Boolean
+		array, ref, object, null = openapi.Array, openapi.Ref, openapi.Object, openapi.Nullable
+	)
+	type props = map[string]*openapi.Schema
+
+	doc := &openapi.Document{
+		OpenAPI: "3.1.0",
+		Info: openapi.Info{
+			Title:   "DSA Revisit API",
+			Version: "1.0.0",
+			Description: "Tracks solved DSA problems and schedules revisits. " +
+				"Authenticate with a Clerk session JWT as a Bearer token.",
+		},
+		Servers:  []openapi.Server{{URL: apiBasePath}},
+		Security: []openapi.SecurityRequirement{{"bearerAuth": {}}},
+		Components: openapi.Components{
+			Schemas: apiSchemas(),
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Clerk session token"},
+			},
+		},
+	}
+
+	add := func(method, path, id, tag, summary string, responses map[string]*openapi.Response) *openapi.Operation {
+		op := &openapi.Operation{OperationID: id, Tags: []string{tag}, Summary: summary, Responses: responses}
+		if _, ok := responses["default"]; !ok {
+			responses["default"] = errorResponse
+		}
+		for _, part := range strings.Split(path, "/") {
+			if open, end := strings.Index(part, "{"), strings.Index(part, "}"); open >= 0 && end > open {
+				name := part[open+1 : end]
+				op.Parameters = append(op.Parameters, &openapi.Parameter{Name: name, In: "path", Required: true, Schema: pathParamSchemas[name]()})
+			}
+		}
+		doc.Add(method, path, op)
+		return op
+	}
+	ok := func(description string, s *openapi.Schema) map[string]*openapi.Response {
+		return map[string]*openapi.Response{"200": jsonResponse(description, s)}
+	}
+
+	// ---------- Public ----------
+	op := add("GET", "/health", "getHealth", "system", "Health check", map[string]*openapi.Response{
+		"200": fileResponse("OK", "text/plain"),
+	})
+	op.Security = publicAccess
+	op = add("GET", "/openapi.json", "getOpenAPI", "system", "This document", ok("OpenAPI document", object(nil, "openapi", "paths")))
+	op.Security = publicAccess
+
+	feedResponses := func(contentType string) map[string]*openapi.Response {
+		return map[string]*openapi.Response{"200": fileResponse("Feed", contentType), "304": notModifiedResponse}
+	}
+	op = add("GET", "/feeds/calendar/{token}.ics", "getCalendarFeed", "feeds", "Today's focus and the projected focus as an ICS calendar",
+		map[string]*openapi.Response{"200": fileResponse("Calendar", "text/calendar")})
+	op.Security = publicAccess
+	op = add("GET", "/feeds/focus/{token}.atom", "getFocusFeed", "feeds", "Daily focus as an Atom feed", feedResponses("application/atom+xml"))
+	op.Security = publicAccess
+	op = add("GET", "/feeds/journal/{token}.atom", "getJournalFeed", "feeds", "Revisit journal as an Atom feed", feedResponses("application/atom+xml"))
+	op.Security = publicAccess
+	op = add("GET", "/feeds/journal/public/{userID}.atom", "getPublicJournalFeed", "feeds", "A user's published revisit journal", feedResponses("application/atom+xml"))
+	op.Security = publicAccess
+
+	// ---------- Events ----------
+	add("GET", "/events", "streamEvents", "events", "Server-Sent Events stream of the user's changes", map[string]*openapi.Response{
+		"200": fileResponse("Event stream; each data line is an Event", "text/event-stream"),
+	})
+
+	// ---------- Problems ----------
+	op = add("GET", "/problems", "listProblems", "problems", "List problems", map[string]*openapi.Response{
+		"200": {Description: "Problems", Headers: listHeaders(), Content: jsonContent(array(ref("Problem")))},
+	})
+	op.Parameters = append([]*openapi.Parameter{
+		queryParam("status", "", openapi.Enum("active", "retired")),
+		queryParam("difficulty", "Comma-separated", str()),
+		queryParam("source", "Comma-separated", str()),
+		queryParam("topic", "Comma-separated", str()),
+		queryParam("tag", "Alias of topic", str()),
+		queryParam("added_from", "Date (YYYY-MM-DD) or RFC 3339 timestamp", str()),
+		queryParam("added_to", "Date (YYYY-MM-DD, inclusive) or RFC 3339 timestamp", str()),
+		queryParam("revisited_from", "Date (YYYY-MM-DD) or RFC 3339 timestamp", str()),
+		queryParam("revisited_to", "Date (YYYY-MM-DD, inclusive) or RFC 3339 timestamp", str()),
+		queryParam("never_revisited", "", boolean()),
+	}, listQuery(problemSortFields)...)
+
+	op = add("POST", "/problems", "createProblem", "problems", "Add a problem", map[string]*openapi.Response{
+		"201": jsonResponse("Created", ref("Problem")),
+		"200": jsonResponse("Merged into the existing problem (on_duplicate=merge)", ref("Problem")),
+		"409": conflictResponse,
+	})
+	op.Parameters = []*openapi.Parameter{queryParam("on_duplicate", "merge folds a duplicate into the existing problem", openapi.Enum("merge"))}
+	op.RequestBody = jsonBody(ref("ProblemInput"), true)
+
+	add("GET", "/problems/today", "getTodaysFocus", "plans", "Today's focus", ok("Today's focus", ref("TodaysFocus")))
+	op = add("GET", "/plans", "getPlanHistory", "plans", "Past daily plans", ok("Plans, newest first", array(ref("PlanDaySummary"))))
+	op.Parameters = []*openapi.Parameter{queryParam("days", "Default 30", integer().Between(1, 365))}
+	op = add("GET", "/problems/forecast", "getForecast", "plans", "Project the workload", ok("Forecast", ref("Forecast")))
+	op.Parameters = []*openapi.Parameter{
+		queryParam("days", "Default 14", integer().Between(1, 90)),
+		queryParam("problems_per_day", "Overrides the user's setting", integer().Between(1, 100)),
+	}
+	op = add("GET", "/search", "searchProblems", "problems", "Full-text search over titles and notes", ok("Results", ref("SearchResponse")))
+	op.Parameters = []*openapi.Parameter{
+		requiredQueryParam("q", "Web search syntax", str().Length(1, 0)),
+		queryParam("limit", "Default 20", integer().Between(1, maxSearchLimit)),
+	}
+	add("GET", "/problems/weights", "getAllWeights", "problems", "Scheduling weights of active problems",
+		ok("Problems by weight, highest first", array(object(props{"problem": ref("Problem"), "weight": ref("ProblemWeight")}, "problem", "weight"))))
+	add("GET", "/problems/{id}", "getProblem", "problems", "Problem details", ok("Problem", ref("ProblemDetail")))
+	add("GET", "/problems/{id}/weight", "getProblemWeight", "problems", "Scheduling weight of a problem", ok("Weight", ref("ProblemWeight")))
+	op = add("PUT", "/problems/{id}", "updateProblem", "problems", "Update a problem", map[string]*openapi.Response{
+		"200": statusResponse("Updated"),
+		"409": conflictResponse,
+	})
+	op.Description = "Replaces the editable fields; omitted fields other than topic are cleared. Use PATCH to change only some fields."
+	op.RequestBody = jsonBody(ref("ProblemInput"), true)
+	op = add("PATCH", "/problems/{id}", "patchProblem", "problems", "Change some fields of a problem", map[string]*openapi.Response{
+		"200": jsonResponse("Updated", ref("Problem")),
+		"409": conflictResponse,
+	})
+	op.RequestBody = jsonBody(ref("ProblemPatch"), true)
+	add("DELETE", "/problems/{id}", "deleteProblem", "problems", "Move a problem to the trash", map[string]*openapi.Response{"200": statusResponse("Trashed")})
+
+	op = add("POST", "/problems/{id}/revisit", "markRevisited", "problems", "Record a revisit", map[string]*openapi.Response{
+		"200": jsonResponse("Recorded", ref("RevisitResult")),
+		"409": conflictResponse,
+	})
+	op.RequestBody = jsonBody(object(props{"notes": str(), "solution": ref("SolutionInput")}), false)
+	add("POST", "/problems/{id}/archive", "archiveProblem", "problems", "Retire a problem", map[string]*openapi.Response{"200": statusResponse("Retired")})
+	add("POST", "/problems/{id}/unarchive", "unarchiveProblem", "problems", "Return a retired problem to rotation", map[string]*openapi.Response{"200": statusResponse("Active")})
+	add("POST", "/problems/{id}/restore", "restoreProblem", "trash", "Restore a trashed problem", map[string]*openapi.Response{
+		"200": statusResponse("Restored to its previous status"),
+		"409": conflictResponse,
+	})
+	op = add("PUT", "/problems/{id}/overrides", "updateProblemOverrides", "problems", "Snooze, hold, pin or reprioritize a problem", ok("Problem", ref("Problem")))
+	op.RequestBody = jsonBody(ref("ProblemOverrides"), true)
+	add("POST", "/problems/{id}/focus", "focusProblemNow", "plans", "Add a problem to today's focus", map[string]*openapi.Response{"200": statusResponse("focused or already_planned")})
+
+	// ---------- History ----------
+	op = add("GET", "/history", "getRevisitHistory", "history", "Revisit history", map[string]*openapi.Response{
+		"200": {Description: "Revisits", Headers: listHeaders(), Content: jsonContent(array(ref("RevisitHistoryItem")))},
+	})
+	op.Parameters = append([]*openapi.Parameter{
+		queryParam("q", "Title or notes substring", str()),
+		queryParam("problem_id", "", str("uuid")),
+		queryParam("difficulty", "Comma-separated", str()),
+		queryParam("source", "Comma-separated", str()),
+		queryParam("topic", "Comma-separated", str()),
+		queryParam("tag", "Alias of topic", str()),
+		queryParam("from", "Date (YYYY-MM-DD) or RFC 3339 timestamp", str()),
+		queryParam("to", "Date (YYYY-MM-DD, inclusive) or RFC 3339 timestamp", str()),
+	}, listQuery(historySortFields)...)
+	op = add("PUT", "/history/{id}", "updateRevisit", "history", "Edit a revisit's notes or date", map[string]*openapi.Response{
+		"200": jsonResponse("Updated", ref("RevisitEntry")),
+		"409": conflictResponse,
+	})
+	op.RequestBody = jsonBody(object(props{"notes": null(str()), "revisited_at": str("date-time")}), true)
+	add("DELETE", "/history/{id}", "deleteRevisit", "history", "Delete a revisit", map[string]*openapi.Response{"200": statusResponse("Deleted")})
+
+	// ---------- Solutions ----------
+	add("GET", "/problems/{id}/solutions", "listSolutions", "solutions", "Solution versions", ok("Versions, oldest first", array(ref("Solution"))))
+	op = add("POST", "/problems/{id}/solutions", "createSolution", "solutions", "Save the next solution version", map[string]*openapi.Response{
+		"201": jsonResponse("Created", ref("Solution")),
+	})
+	op.RequestBody = jsonBody(ref("SolutionInput"), true)
+	op = add("GET", "/problems/{id}/solutions/diff", "diffSolutions", "solutions", "Unified diff between two versions", map[string]*openapi.Response{
+		"200": {Description: "Diff", Content: map[string]*openapi.MediaType{
+			"application/json": {Schema: ref("SolutionDiff")},
+			"text/x-diff":      {},
+		}},
+	})
+	op.Parameters = append(op.Parameters,
+		queryParam("from", "Version number or latest; defaults to the version before to, or an empty file for version 1", pathParamSchemas["version"]()),
+		queryParam("to", "Version number or latest (default)", pathParamSchemas["version"]()),
+		queryParam("format", "text returns text/x-diff", openapi.Enum("text")),
+	)
+	add("GET", "/problems/{id}/solutions/{version}", "getSolution", "solutions", "One solution version", ok("Solution", ref("Solution")))
+
+	// ---------- Flashcards ----------
+	add("GET", "/problems/{id}/flashcards", "listProblemFlashcards", "flashcards", "A problem's flashcards", ok("Flashcards", array(ref("Flashcard"))))
+	op = add("POST", "/problems/{id}/flashcards", "createFlashcard", "flashcards", "Add a flashcard", map[string]*openapi.Response{
+		"201": jsonResponse("Created", ref("Flashcard")),
+	})
+	op.RequestBody = jsonBody(ref("CardText"), true)
+	add("GET", "/flashcards/queue", "getFlashcardQueue", "flashcards", "Cards due for review", ok("Queue", object(props{
+		"due_count": integer(), "total": integer(), "flashcards": array(ref("Flashcard")),
+	}, "due_count", "total", "flashcards")))
+	op = add("GET", "/flashcards/drill", "getFlashcardDrill", "flashcards", "Today's drill", ok("Drill", object(props{
+		"date": str("date"), "reviewed_today": integer(), "flashcards": array(ref("Flashcard")),
+	}, "date", "reviewed_today", "flashcards")))
+	op.Parameters = []*openapi.Parameter{queryParam("limit", "Default 10", integer().Between(1, maxDrillSize))}
+	op = add("PUT", "/flashcards/{id}", "updateFlashcard", "flashcards", "Edit a manual flashcard", map[string]*openapi.Response{
+		"200": jsonResponse("Updated", ref("Flashcard")),
+		"409": conflictResponse,
+	})
+	op.RequestBody = jsonBody(ref("CardText"), true)
+	add("DELETE", "/flashcards/{id}", "deleteFlashcard", "flashcards", "Delete a manual flashcard", map[string]*openapi.Response{
+		"200": statusResponse("Deleted"),
+		"409": conflictResponse,
+	})
+	op = add("POST", "/flashcards/{id}/review", "reviewFlashcard", "flashcards", "Record a review", map[string]*openapi.Response{
+		"200": jsonResponse("Reviewed", object(props{"flashcard": ref("Flashcard"), "next_due_days": integer()}, "flashcard", "next_due_days")),
+		"409": conflictResponse,
+	})
+	op.RequestBody = jsonBody(object(props{"remembered": boolean()}, "remembered"), true)
+
+	// ---------- Catalog ----------
+	add("GET", "/catalog/lists", "listCatalogLists", "catalog", "Curated lists", ok("Lists", array(ref("CatalogList"))))
+	add("GET", "/catalog/lists/{slug}", "getCatalogList", "catalog", "A curated list", ok("List", ref("CatalogListDetail")))
+	op = add("POST", "/catalog/lists/{slug}/add", "addCatalogList", "catalog", "Add a list's problems", ok("Added problems", object(props{
+		"added": integer(), "problems": array(ref("Problem")),
+	}, "added", "problems")))
+	op.Parameters = append(op.Parameters, queryParam("limit", "Add at most this many, in list order", integer().AtLeast(1)))
+	add("GET", "/catalog/lists/{slug}/progress", "getCatalogListProgress", "catalog", "Progress through a list", ok("Progress", ref("ListProgress")))
+
+	// ---------- Collections ----------
+	add("GET", "/collections", "listCollections", "collections", "Collections", ok("Collections", array(ref("Collection"))))
+	op = add("POST", "/collections", "createCollection", "collections", "Create a collection", map[string]*openapi.Response{
+		"201": jsonResponse("Created", ref("Collection")),
+	})
+	op.RequestBody = jsonBody(&openapi.Schema{AllOf: []*openapi.Schema{ref("CollectionInput"), object(nil, "name")}}, true)
+	add("GET", "/collections/{id}", "getCollection", "collections", "A collection and its problems", ok("Collection", ref("CollectionDetail")))
+	op = add("PUT", "/collections/{id}", "updateCollection", "collections", "Update a collection", map[string]*openapi.Response{"200": statusResponse("Updated")})
+	op.RequestBody = jsonBody(ref("CollectionInput"), true)
+	add("DELETE", "/collections/{id}", "deleteCollection", "collections", "Delete a collection", map[string]*openapi.Response{"200": statusResponse("Deleted")})
+	add("GET", "/collections/{id}/progress", "getCollectionProgress", "collections", "Progress through a collection", ok("Progress", ref("ListProgress")))
+	op = add("PUT", "/focus-scope", "setFocusScope", "collections", "Scope today's focus to a collection", ok("Scope", object(props{
+		"focus_collection_id": null(str("uuid")), "applies_today": boolean(),
+	}, "focus_collection_id", "applies_today")))
+	op.RequestBody = jsonBody(object(props{"collection_id": null(str("uuid"))}), true)
+
+	// ---------- Goal ----------
+	add("GET", "/goal", "getGoal", "goal", "The active interview goal", ok("Goal", object(props{"goal": null(ref("Goal"))}, "goal")))
+	op = add("PUT", "/goal", "setGoal", "goal", "Set the interview goal", ok("Goal", object(props{"goal": ref("Goal")}, "goal")))
+	op.RequestBody = jsonBody(ref("GoalInput"), true)
+	add("DELETE", "/goal", "deleteGoal", "goal", "End the goal", map[string]*openapi.Response{"200": statusResponse("Ended")})
+	add("GET", "/goal/progress", "getGoalProgress", "goal", "Progress toward the goal", ok("Progress", &openapi.Schema{AllOf: []*openapi.Schema{
+		object(props{"goal": ref("Goal")}, "goal"), ref("GoalProgress"),
+	}}))
+
+	// ---------- Export ----------
+	add("GET", "/export/anki.apkg", "exportAnkiPackage", "export", "Anki deck package", map[string]*openapi.Response{
+		"200": fileResponse("Anki package", "application/octet-stream"),
+	})
+	add("GET", "/export/anki.tsv", "exportAnkiTSV", "export", "Anki-importable TSV", map[string]*openapi.Response{
+		"200": fileResponse("TSV", "text/tab-separated-values"),
+	})
+
+	// ---------- Trash ----------
+	add("GET", "/trash", "listTrash", "trash", "Trashed problems", ok("Trash, most recent first", array(ref("TrashedProblem"))))
+	add("DELETE", "/trash/{id}", "purgeProblem", "trash", "Permanently delete a trashed problem", map[string]*openapi.Response{"200": statusResponse("Purged")})
+
+	// ---------- Settings ----------
+	add("GET", "/settings", "getSettings", "settings", "User settings", ok("Settings", object(props{"message": str()})))
+	op = add("PUT", "/settings", "updateSettings", "settings", "Update user settings", ok("Settings", object(props{"message": str()})))
+	op.RequestBody = jsonBody(&openapi.Schema{Type: openapi.Types{"object"}}, false)
+	add("GET", "/settings/feeds", "listFeedTokens", "feeds", "Enabled feeds", ok("Feeds", array(ref("FeedToken"))))
+	add("POST", "/settings/feeds/{kind}", "rotateFeedToken", "feeds", "Create or rotate a feed URL", map[string]*openapi.Response{
+		"201": jsonResponse("The only copy of the feed URL", &openapi.Schema{AllOf: []*openapi.Schema{
+			ref("FeedToken"), object(props{"url": str()}, "url"),
+		}}),
+	})
+	add("DELETE", "/settings/feeds/{kind}", "revokeFeedToken", "feeds", "Revoke a feed URL", map[string]*openapi.Response{"200": statusResponse("Revoked")})
+	op = add("PUT", "/settings/feeds/journal/public", "setPublicJournal", "feeds", "Publish or unpublish the journal feed", ok("Setting", object(props{
+		"public_journal": boolean(), "url": str(),
+	}, "public_journal")))
+	op.RequestBody = jsonBody(object(props{"enabled": boolean()}, "enabled"), true)
+
+	// ---------- Testing ----------
+	add("POST", "/test-email", "testEmail", "system", "Send today's email now", ok("Report", ref("TestEmailReport")))
+	add("POST", "/admin/run-cron", "runCron", "system", "Run the daily job for all users", ok("Triggered", object(props{
+		"status": str(), "message": str(),
+	}, "status", "message")))
+
+	for _, item := range doc.Paths {
+		for method, op := range item {
+			op.Responses["429"] = rateLimitedResponse
+			if method == "post" {
+				idempotent(op)
+			}
+			switch op.OperationID {
+			case "listProblems", "getPlanHistory", "getRevisitHistory", "listSolutions", "listProblemFlashcards",
+				"listCatalogLists", "listCollections", "listTrash", "listFeedTokens":
+				revalidated(op)
+			case "getProblem":
+				op.Responses["200"].Headers = map[string]*openapi.Header{"ETag": etagHeader}
+			case "updateProblem", "patchProblem", "deleteProblem":
+				requireIfMatch(op)
+			}
+		}
+	}
+	return doc
+}
+
+var etagHeader = &openapi.Header{Description: "Validator for If-None-Match / If-Match", Schema: openapi.String()}
+
+// revalidated documents a GET answered through respondJSONConditional.
+func revalidated(op *openapi.Operation) {
+	op.Parameters = append(op.Parameters, &openapi.Parameter{Name: "If-None-Match", In: "header",
+		Description: "ETag of the copy the client has", Schema: openapi.String()})
+	ok := op.Responses["200"]
+	if ok.Headers == nil {
+		ok.Headers = map[string]*openapi.Header{}
+	}
+	ok.Headers["ETag"] = etagHeader
+	op.Responses["304"] = notModifiedResponse
+}
+
+// idempotent documents the optional Idempotency-Key of a POST.
+func idempotent(op *openapi.Operation) {
+	op.Parameters = append(op.Parameters, &openapi.Parameter{Name: "Idempotency-Key", In: "header",
+		Description: "Unique key for this request; retries with the same key and body replay the first response " +
+			"(with Idempotent-Replayed: true) for 24 hours. Reusing a key with a different body gets 422.",
+		Schema: openapi.String().Length(1, maxIdempotencyKeyLen)})
+	op.Responses["422"] = jsonResponse("The Idempotency-Key was already used for a different request", openapi.Ref("Error"))
+	if op.Responses["409"] == nil {
+		op.Responses["409"] = jsonResponse("A request with the same Idempotency-Key is still in progress", openapi.Ref("Error"))
+	}
+}
+
+// requireIfMatch documents the If-Match precondition of a problem write.
+func requireIfMatch(op *openapi.Operation) {
+	op.Parameters = append(op.Parameters, &openapi.Parameter{Name: "If-Match", In: "header",
+		Description: `ETag from GET /problems/{id} (e.g. "v3"), or * to skip the check. Requests without it get 428.`,
+		Schema:      openapi.String()})
+	if op.OperationID != "deleteProblem" {
+		op.Responses["200"].Headers = map[string]*openapi.Header{"ETag": etagHeader}
+	}
+	op.Responses["412"] = jsonResponse("The problem changed since the client loaded it", openapi.Ref("Error"))
+	op.Responses["428"] = jsonResponse("If-Match is missing", openapi.Ref("Error"))
+}
+
+// apiSchemas are the component schemas, mirroring the JSON of the Go types.
+func apiSchemas() map[string]*openapi.Schema {
+	var (
+		str, integer, number, boolean = openapi.String, openapi.Integer, openapi.Number, openapi.Boolean
+		array, ref, object, null      = openapi.Array, openapi.Ref, openapi.Object, openapi.Nullable
+	)
+	type props = map[string]*openapi.Schema
+
+	dateTime := func() *openapi.Schema { return str("date-time") }
+	uuid := func() *openapi.Schema { return str("uuid") }
+	status := openapi.Enum("active", "retired", "trashed")
+
+	problem := props{
+		"id": uuid(), "user_id": uuid(), "title": str(), "link": str(),
+		"date_added": dateTime(), "last_revisited_at": null(dateTime()),
+		"times_revisited": integer(), "status": status,
+		"topic": str(), "difficulty": str(), "source": str(), "notes": str(),
+		"snoozed_until": null(dateTime()), "hold_until": null(dateTime()),
+		"pinned": boolean(), "priority_multiplier": number(),
+		"catalog_problem_id": null(uuid()),
+		"version":            integer().Describe(`Bumped on every change; send it back as If-Match: "v<version>"`),
+	}
+	problemRequired := []string{
+		"id", "user_id", "title", "link", "date_added", "last_revisited_at", "times_revisited", "status",
+		"snoozed_until", "hold_until", "pinned", "priority_multiplier", "catalog_problem_id", "version",
+	}
+	problemInput := props{
+		"title":      str().Length(1, problemRules.Title.MaxLength),
+		"link":       str().Length(0, problemRules.Link.MaxLength).Describe("An http or https URL, or empty"),
+		"difficulty": openapi.Enum(append([]string{""}, difficulties...)...),
+		"source":     str().Length(0, problemRules.Source.MaxLength),
+		"topic":      str().Length(0, problemRules.Topic.MaxLength).Describe("Kept on PUT when omitted"),
+		"notes":      str().Length(0, problemRules.Notes.MaxLength),
+	}
+	extend := func(base string, extra props, required ...string) *openapi.Schema {
+		return &openapi.Schema{AllOf: []*openapi.Schema{ref(base), object(extra, required...)}}
+	}
+
+	return map[string]*openapi.Schema{
+		"Status": object(props{"status": str()}, "status"),
+		"Error": object(props{
+			"error": object(props{
+				"code":       str().Describe("Machine-readable code, e.g. not_found or duplicate_problem"),
+				"message":    str().Describe("Human-readable message, safe to show to users"),
+				"details":    (&openapi.Schema{}).Describe("Code-specific details: the invalid fields for invalid_request, the existing problem for duplicate_problem"),
+				"request_id": str(),
+			}, "code", "message"),
+		}, "error"),
+		"FieldError": object(props{"field": str(), "message": str()}, "field", "message"),
+
+		"Problem":      object(problem, problemRequired...),
+		"ProblemInput": object(problemInput, "title"),
+		"ProblemPatch": object(problemInput),
+		"ProblemOverrides": object(props{
+			"snoozed_until":       null(dateTime()),
+			"hold_until":          null(dateTime()),
+			"pinned":              boolean(),
+			"priority_multiplier": number().Between(0.1, 10),
+		}),
+		"ProblemWeight": object(props{
+			"problem_id": uuid(), "weight": number(), "days_since_added": number(), "days_since_last_revisit": number(),
+			"times_revisited": integer(), "revisit_decay": number(), "is_eligible": boolean(), "eligibility_reason": str(),
+			"priority_multiplier": number(), "pinned": boolean(), "priority": openapi.Enum("high", "medium", "low"),
+		}, "problem_id", "weight", "is_eligible", "priority"),
+		"RevisitEntry": object(props{
+			"id": uuid(), "problem_id": uuid(), "revisited_at": dateTime(), "notes": str(),
+		}, "id", "problem_id", "revisited_at"),
+		"ProblemDetail": extend("Problem", props{
+			"revisited_today": boolean(),
+			"revisit_history": array(ref("RevisitEntry")),
+			"weight_info":     ref("ProblemWeight"),
+		}, "revisited_today", "revisit_history", "weight_info"),
+		"RevisitResult": object(props{
+			"status": str(), "revisit_id": uuid(), "solution": ref("Solution"),
+		}, "status", "revisit_id"),
+		"RevisitHistoryItem": object(props{
+			"id": uuid(), "problem_id": uuid(), "revisited_at": dateTime(), "notes": str(),
+			"problem_title": str(), "problem_link": str(), "difficulty": str(), "topic": str(),
+		}, "id", "problem_id", "revisited_at", "problem_title", "problem_link"),
+		"TrashedProblem": extend("Problem", props{"trashed_at": dateTime(), "purge_at": dateTime()}, "trashed_at", "purge_at"),
+
+		"TodaysFocus": object(props{
+			"problems": array(object(props{
+				"problem": ref("Problem"), "weight": ref("ProblemWeight"), "revisited_today": boolean(),
+			}, "problem", "weight", "revisited_today")),
+			"summary": object(props{"total_focus": integer(), "completed": integer(), "remaining": integer()},
+				"total_focus", "completed", "remaining"),
+		}, "problems", "summary"),
+		"PlanDaySummary": object(props{
+			"plan_date": str("date"), "eligible_count": integer(), "total_focus": integer(), "completed": integer(),
+			"problems": array(object(props{"problem_id": null(uuid()).Describe("Null once the problem is purged"), "title": str(), "completed": boolean()},
+				"problem_id", "title", "completed")),
+		}, "plan_date", "eligible_count", "total_focus", "completed", "problems"),
+		"Forecast": object(props{
+			"problems_per_day": integer(), "min_revisit_days": integer(), "max_revisit_days": integer(),
+			"active_problems": integer(), "projected_max_gap_days": number(), "on_track": boolean(),
+			"recommended_problems_per_day": integer(),
+			"recommendation_feasible":      boolean().Describe("False when no budget up to 100/day keeps gaps within max_revisit_days; the recommendation is then that cap"),
+			"days": array(object(props{
+				"date": str("date"), "eligible_count": integer(), "selected": integer(), "backlog": integer(),
+				"overdue_count": integer(), "max_gap_days": number(),
+			}, "date", "eligible_count", "selected", "backlog", "overdue_count", "max_gap_days")),
+		}, "problems_per_day", "active_problems", "on_track", "days"),
+		"SearchResponse": object(props{
+			"query": str(),
+			"results": array(object(props{
+				"problem_id": uuid(), "title": str(), "link": str(), "difficulty": str(), "topic": str(),
+				"status": status, "field": openapi.Enum(searchFieldTitle, searchFieldNotes, searchFieldRevisitNotes),
+				"revisit_id": uuid(), "revisited_at": dateTime(),
+				"snippet": str().Describe("HTML-escaped; matches are wrapped in <mark>"), "rank": number(),
+			}, "problem_id", "title", "field", "snippet", "rank")),
+		}, "query", "results"),
+
+		"Solution": object(props{
+			"id": uuid(), "problem_id": uuid(), "revisit_id": null(uuid()), "version": integer(),
+			"language": str(), "code": str(), "time_complexity": str(), "space_complexity": str(),
+			"approach": str(), "created_at": dateTime(),
+		}, "id", "problem_id", "revisit_id", "version", "language", "code", "created_at"),
+		"SolutionInput": object(props{
+			"revisit_id": null(uuid()), "language": str(), "code": str(),
+			"time_complexity": str(), "space_complexity": str(), "approach": str(),
+		}, "language", "code"),
+		"SolutionDiff": object(props{
+			"from": integer(), "to": integer(), "identical": boolean(), "diff": str(),
+		}, "from", "to", "identical", "diff"),
+
+		"Flashcard": object(props{
+			"id": uuid(), "problem_id": uuid(), "problem_title": str(), "prompt": str(), "answer": str(),
+			"source": openapi.Enum(flashcardSourceManual, flashcardSourceNotes), "created_at": dateTime(),
+			"last_reviewed_at": null(dateTime()), "times_reviewed": integer(), "lapses": integer(),
+		}, "id", "problem_id", "prompt", "answer", "source", "created_at", "last_reviewed_at", "times_reviewed", "lapses"),
+		"CardText": object(props{
+			"prompt": str().Length(1, 1000), "answer": str().Length(1, 4000),
+		}, "prompt", "answer"),
+
+		"CatalogProblem": object(props{
+			"id": uuid(), "platform": str(), "title": str(), "link": str(), "difficulty": str(), "topics": array(str()),
+		}, "id", "platform", "title", "link"),
+		"CatalogList": object(props{
+			"slug": str(), "name": str(), "description": str(), "total_count": integer(), "added_count": integer(),
+		}, "slug", "name", "total_count", "added_count"),
+		"CatalogListDetail": extend("CatalogList", props{
+			"items": array(object(props{
+				"position": integer(), "problem": ref("CatalogProblem"), "problem_id": null(uuid()), "problem_status": status,
+			}, "position", "problem", "problem_id")),
+		}, "items"),
+		"ListProgress": object(props{
+			"total": integer(), "added": integer(), "revisited": integer(), "reviewed_recently": integer(),
+			"mastered": integer(), "max_revisit_days": integer(),
+			"next_suggested": null(object(props{
+				"position": integer(), "title": str(), "link": str(),
+				"problem_id": null(uuid()), "catalog_problem_id": null(uuid()),
+			}, "position", "title", "link", "problem_id")),
+		}, "total", "added", "revisited", "reviewed_recently", "mastered", "next_suggested"),
+
+		"Collection": object(props{
+			"id": uuid(), "name": str(), "description": str(), "problem_count": integer(),
+			"is_focus": boolean(), "created_at": dateTime(),
+		}, "id", "name", "problem_count", "is_focus", "created_at"),
+		"CollectionDetail": extend("Collection", props{"problems": array(ref("Problem"))}, "problems"),
+		"CollectionInput": object(props{
+			"name": str(), "description": str(), "problem_ids": array(uuid()),
+		}),
+
+		"Goal": object(props{
+			"id": uuid(), "target_date": str("date"), "start_date": str("date"), "collection_id": null(uuid()),
+			"topic": str(), "daily_budget": integer(), "min_revisits": integer(), "weak_topics": array(str()),
+			"created_at": dateTime(),
+		}, "id", "target_date", "start_date", "collection_id", "daily_budget", "min_revisits", "weak_topics"),
+		"GoalInput": object(props{
+			"target_date": str("date"), "collection_id": null(uuid()), "topic": str(),
+			"daily_budget": integer().Between(1, 100), "min_revisits": integer().Between(0, 20),
+			"weak_topics": array(str()),
+		}, "target_date", "daily_budget"),
+		"GoalProgress": object(props{
+			"days_left": integer(), "in_scope_count": integer(), "required_revisits": integer(),
+			"completed_revisits": integer(), "expected_by_now": integer(), "required_per_day": number(),
+			"capacity": integer(), "on_track": boolean(),
+			"at_risk": array(object(props{
+				"problem_id": uuid(), "title": str(), "topic": str(), "done": integer(), "remaining": integer(),
+			}, "problem_id", "title", "done", "remaining")),
+			"topic_completion": openapi.Map(number()),
+		}, "days_left", "in_scope_count", "on_track", "at_risk", "topic_completion"),
+
+		"FeedToken": object(props{
+			"kind":       openapi.Enum(feedKindCalendar, feedKindFocus, feedKindJournal),
+			"created_at": dateTime(), "last_used_at": null(dateTime()),
+		}, "kind", "created_at", "last_used_at"),
+
+		"TestEmailReport": object(props{
+			"status": str(), "email_status": openapi.Enum("sent", "error", "no_problems_to_send"),
+			"email_error": str(), "recipient_email": str(), "total_problems": integer(),
+			"eligible_count": integer(), "selected_count": integer(), "problems_per_day": integer(),
+			"min_revisit_days": integer(),
+			"all_problems": array(object(props{
+				"title": str(), "link": str(), "weight": ref("ProblemWeight"), "selected": boolean(),
+			}, "title", "weight", "selected")),
+		}, "status", "email_status", "recipient_email", "all_problems"),
+	}
+}
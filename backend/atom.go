@@ -0,0 +1,368 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Atom feeds of the daily focus and the revisit journal. Both are reached
+// through feed tokens (see feeds.go); the journal can also be published at
+// a public URL when the user opts in.
+
+// feedEntryLimit caps the entries in each feed.
+const feedEntryLimit = 50
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published,omitempty"`
+	Links     []atomLink `xml:"link"`
+	Content   atomText   `xml:"content"`
+
+	updated time.Time
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// buildAtomFeed renders a feed. Its updated time is the latest entry's (or
+// the zero time when empty), which is also returned for Last-Modified.
+func buildAtomFeed(id, title, selfURL string, entries []atomEntry) ([]byte, time.Time, error) {
+	var updated time.Time
+	for i := range entries {
+		if entries[i].updated.After(updated) {
+			updated = entries[i].updated
+		}
+		entries[i].Updated = entries[i].updated.UTC().Format(time.RFC3339)
+	}
+	feed := atomFeed{
+		ID:      id,
+		Title:   title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  "DSA Revisit",
+		Links:   []atomLink{{Href: selfURL, Rel: "self"}},
+		Entries: entries,
+	}
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, updated, err
+	}
+	return append([]byte(xml.Header), body...), updated, nil
+}
+
+// writeConditional serves body with an ETag and Last-Modified (unless zero),
+// answering 304 Not Modified when the client's If-None-Match or
+// If-Modified-Since shows it already has this version. If-None-Match takes
+// precedence.
+func writeConditional(w http.ResponseWriter, r *http.Request, contentType string, body []byte, lastModified time.Time) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etagMatches(inm, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.Truncate(time.Second).After(t) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, using the
+// weak comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// focusDay is one daily plan for the focus feed.
+type focusDay struct {
+	PlanID    uuid.UUID
+	PlanDate  string
+	CreatedAt time.Time
+	Problems  []focusDayProblem
+}
+
+type focusDayProblem struct {
+	Title       string
+	Link        string
+	CompletedAt NullTime
+}
+
+// focusEntry renders a day's plan. The entry is updated whenever one of its
+// problems is revisited, so readers pick up completion.
+func focusEntry(d focusDay) atomEntry {
+	updated := d.CreatedAt
+	var content strings.Builder
+	content.WriteString("<ul>")
+	done := 0
+	for _, p := range d.Problems {
+		mark := ""
+		if p.CompletedAt.Valid {
+			done++
+			mark = " ✓"
+			if p.CompletedAt.Time.After(updated) {
+				updated = p.CompletedAt.Time
+			}
+		}
+		fmt.Fprintf(&content, `<li><a href="%s">%s</a>%s</li>`, html.EscapeString(p.Link), html.EscapeString(p.Title), mark)
+	}
+	content.WriteString("</ul>")
+	if len(d.Problems) == 0 {
+		content.Reset()
+		content.WriteString("<p>Nothing was due.</p>")
+	}
+
+	return atomEntry{
+		ID:        "urn:uuid:" + d.PlanID.String(),
+		Title:     fmt.Sprintf("Focus for %s (%d/%d done)", d.PlanDate, done, len(d.Problems)),
+		Published: d.CreatedAt.UTC().Format(time.RFC3339),
+		Content:   atomText{Type: "html", Body: content.String()},
+		updated:   updated,
+	}
+}
+
+// journalEntry is one revisit journal note.
+type journalEntry struct {
+	RevisitID   uuid.UUID
+	RevisitedAt time.Time
+	Notes       string
+	Title       string
+	Link        string
+}
+
+func (j journalEntry) atom() atomEntry {
+	e := atomEntry{
+		ID:        "urn:uuid:" + j.RevisitID.String(),
+		Title:     "Revisited: " + j.Title,
+		Published: j.RevisitedAt.UTC().Format(time.RFC3339),
+		Content:   atomText{Type: "html", Body: "<p>" + textToHTML(j.Notes) + "</p>"},
+		updated:   j.RevisitedAt,
+	}
+	if j.Link != "" {
+		e.Links = []atomLink{{Href: j.Link, Rel: "related"}}
+	}
+	return e
+}
+
+// loadFocusDays loads the user's most recent daily plans, newest first.
+func loadFocusDays(userID uuid.UUID) ([]focusDay, error) {
+	rows, err := db.Query(`
+		SELECT dp.id, to_char(dp.plan_date, 'YYYY-MM-DD'), dp.created_at,
+		       COALESCE(p.title, dpi.title), COALESCE(p.link, dpi.link),
+		       COALESCE(dpi.completed_at, (SELECT MIN(rh.revisited_at) FROM revisit_history rh
+		        WHERE rh.problem_id = p.id AND rh.revisited_at::date = dp.plan_date))
+		FROM (SELECT * FROM daily_plans WHERE user_id = $1 ORDER BY plan_date DESC LIMIT $2) dp
+		LEFT JOIN daily_plan_items dpi ON dpi.plan_id = dp.id
+		LEFT JOIN problems p ON p.id = dpi.problem_id
+		ORDER BY dp.plan_date DESC, dpi.position ASC`, userID, feedEntryLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []focusDay
+	for rows.Next() {
+		var d focusDay
+		var title, link sql.NullString
+		var completedAt NullTime
+		if err := rows.Scan(&d.PlanID, &d.PlanDate, &d.CreatedAt, &title, &link, &completedAt); err != nil {
+			return nil, err
+		}
+		if len(days) == 0 || days[len(days)-1].PlanID != d.PlanID {
+			days = append(days, d)
+		}
+		// Plans with no selected problems have a single row with NULL problem columns
+		if title.Valid {
+			last := &days[len(days)-1]
+			last.Problems = append(last.Problems, focusDayProblem{Title: title.String, Link: link.String, CompletedAt: completedAt})
+		}
+	}
+	return days, rows.Err()
+}
+
+// loadJournal loads the user's most recent revisit notes, newest first.
+func loadJournal(userID uuid.UUID) ([]journalEntry, error) {
+	rows, err := db.Query(`
+		SELECT rh.id, rh.revisited_at, rh.notes, p.title, p.link
+		FROM revisit_history rh
+		JOIN problems p ON p.id = rh.problem_id
+		WHERE p.user_id = $1 AND p.status <> 'trashed' AND COALESCE(rh.notes, '') <> ''
+		ORDER BY rh.revisited_at DESC
+		LIMIT $2`, userID, feedEntryLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []journalEntry
+	for rows.Next() {
+		var j journalEntry
+		if err := rows.Scan(&j.RevisitID, &j.RevisitedAt, &j.Notes, &j.Title, &j.Link); err != nil {
+			return nil, err
+		}
+		entries = append(entries, j)
+	}
+	return entries, rows.Err()
+}
+
+// serveJournalFeed renders the journal feed for a user.
+func serveJournalFeed(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	journal, err := loadJournal(userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	entries := make([]atomEntry, len(journal))
+	for i, j := range journal {
+		entries[i] = j.atom()
+	}
+
+	body, updated, err := buildAtomFeed("urn:dsa-revisit:journal:"+userID.String(), "Revisit journal",
+		requestBaseURL(r)+r.URL.Path, entries)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	writeConditional(w, r, "application/atom+xml; charset=utf-8", body, updated)
+}
+
+// GetFocusFeed serves the daily focus as an Atom feed, one entry per day.
+func GetFocusFeed(w http.ResponseWriter, r *http.Request) {
+	userID, ok, err := resolveFeedToken(feedKindFocus, chi.URLParam(r, "token"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if !ok {
+		respondError(w, r, notFound("Feed"))
+		return
+	}
+
+	days, err := loadFocusDays(userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	entries := make([]atomEntry, len(days))
+	for i, d := range days {
+		entries[i] = focusEntry(d)
+	}
+
+	body, updated, err := buildAtomFeed("urn:dsa-revisit:focus:"+userID.String(), "Today's Focus",
+		requestBaseURL(r)+r.URL.Path, entries)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	writeConditional(w, r, "application/atom+xml; charset=utf-8", body, updated)
+}
+
+// GetJournalFeed serves the revisit journal as an Atom feed via its token.
+func GetJournalFeed(w http.ResponseWriter, r *http.Request) {
+	userID, ok, err := resolveFeedToken(feedKindJournal, chi.URLParam(r, "token"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if !ok {
+		respondError(w, r, notFound("Feed"))
+		return
+	}
+	serveJournalFeed(w, r, userID)
+}
+
+// GetPublicJournalFeed serves a user's journal as a public learning log.
+// It only exists while the user has public_journal enabled.
+func GetPublicJournalFeed(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondError(w, r, notFound("Feed"))
+		return
+	}
+
+	var prefs UserPreferences
+	if err := db.QueryRow("SELECT preferences FROM users WHERE id = $1", userID).Scan(&prefs); err != nil || !prefs.PublicJournal {
+		respondError(w, r, notFound("Feed"))
+		return
+	}
+	serveJournalFeed(w, r, userID)
+}
+
+// SetPublicJournal turns the public journal feed on or off.
+// Body: enabled (bool). Returns the public URL while enabled.
+func SetPublicJournal(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	var body struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, r, invalidBody(err))
+		return
+	}
+
+	_, err := db.Exec(`
+		UPDATE users SET preferences = jsonb_set(COALESCE(preferences, '{}'), '{public_journal}', to_jsonb($2::boolean))
+		WHERE id = $1`, userID, body.Enabled)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	publishSettingsChanged(userID, "public_journal")
+
+	resp := map[string]interface{}{"public_journal": body.Enabled}
+	if body.Enabled {
+		resp["url"] = fmt.Sprintf("%s/api/feeds/journal/public/%s.atom", requestBaseURL(r), userID)
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
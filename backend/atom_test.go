@@ -0,0 +1,111 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFocusEntry(t *testing.T) {
+	created := time.Date(2026, 5, 1, 6, 0, 0, 0, time.UTC)
+	done := time.Date(2026, 5, 1, 19, 30, 0, 0, time.UTC)
+	e := focusEntry(focusDay{
+		PlanID:    uuid.New(),
+		PlanDate:  "2026-05-01",
+		CreatedAt: created,
+		Problems: []focusDayProblem{
+			{Title: "Two Sum", Link: "https://leetcode.com/problems/two-sum/", CompletedAt: NullTime{sql.NullTime{Time: done, Valid: true}}},
+			{Title: "A & B", Link: "https://example.com/?a=1&b=2"},
+		},
+	})
+
+	if e.Title != "Focus for 2026-05-01 (1/2 done)" {
+		t.Errorf("unexpected title %q", e.Title)
+	}
+	if !e.updated.Equal(done) {
+		t.Errorf("entry should be updated at the last completion, got %v", e.updated)
+	}
+	if !strings.Contains(e.Content.Body, `<a href="https://example.com/?a=1&amp;b=2">A &amp; B</a>`) {
+		t.Errorf("content not escaped: %s", e.Content.Body)
+	}
+}
+
+func TestBuildAtomFeed(t *testing.T) {
+	older := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	newer := older.Add(48 * time.Hour)
+	entries := []atomEntry{
+		journalEntry{RevisitID: uuid.New(), RevisitedAt: newer, Notes: "used <heap>", Title: "Top K"}.atom(),
+		journalEntry{RevisitID: uuid.New(), RevisitedAt: older, Notes: "ok", Title: "Two Sum", Link: "https://x"}.atom(),
+	}
+	body, updated, err := buildAtomFeed("urn:test", "Journal", "https://api/feed.atom", entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated.Equal(newer) {
+		t.Errorf("feed updated = %v, want %v", updated, newer)
+	}
+
+	var parsed struct {
+		Updated string `xml:"updated"`
+		Entries []struct {
+			Title   string `xml:"title"`
+			Updated string `xml:"updated"`
+			Content string `xml:"content"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(body, &parsed); err != nil {
+		t.Fatalf("feed is not valid XML: %v", err)
+	}
+	if parsed.Updated != "2026-05-03T08:00:00Z" || len(parsed.Entries) != 2 {
+		t.Errorf("unexpected feed: %+v", parsed)
+	}
+	if parsed.Entries[0].Content != "<p>used &lt;heap&gt;</p>" {
+		t.Errorf("entry content = %q", parsed.Entries[0].Content)
+	}
+}
+
+func TestWriteConditional(t *testing.T) {
+	body := []byte("<feed/>")
+	modified := time.Date(2026, 5, 3, 8, 0, 0, 500, time.UTC)
+
+	serve := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/feed", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		writeConditional(rec, req, "application/atom+xml", body, modified)
+		return rec
+	}
+
+	first := serve("", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Body.String() != "<feed/>" {
+		t.Fatalf("unexpected first response: %d %q", first.Code, etag)
+	}
+	if got := first.Header().Get("Last-Modified"); got != "Sun, 03 May 2026 08:00:00 GMT" {
+		t.Errorf("Last-Modified = %q", got)
+	}
+
+	tests := []struct {
+		name, header, value string
+		want                int
+	}{
+		{"matching etag", "If-None-Match", etag, http.StatusNotModified},
+		{"weak matching etag in a list", "If-None-Match", `"other", W/` + etag, http.StatusNotModified},
+		{"stale etag", "If-None-Match", `"other"`, http.StatusOK},
+		{"not modified since", "If-Modified-Since", "Sun, 03 May 2026 08:00:00 GMT", http.StatusNotModified},
+		{"modified since", "If-Modified-Since", "Sun, 03 May 2026 07:59:59 GMT", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := serve(tt.header, tt.value); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
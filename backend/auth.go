@@ -6,7 +6,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/big"
 	"net/http"
 	"strings"
@@ -102,7 +102,7 @@ func (c *jwksCache) refresh(issuer string) error {
 		}
 		pubKey, err := parseRSAPublicKey(k.N, k.E)
 		if err != nil {
-			log.Printf("Skipping JWK kid=%s: %v", k.Kid, err)
+			slog.Warn("Skipping JWK", "kid", k.Kid, "error", err)
 			continue
 		}
 		keys[k.Kid] = pubKey
@@ -139,7 +139,7 @@ func ClerkAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			http.Error(w, `{"error":"missing or invalid Authorization header"}`, http.StatusUnauthorized)
+			respondError(w, r, unauthorized("Missing or invalid Authorization header"))
 			return
 		}
 
@@ -149,13 +149,13 @@ func ClerkAuthMiddleware(next http.Handler) http.Handler {
 		parser := jwt.NewParser(jwt.WithoutClaimsValidation())
 		unverified, _, err := parser.ParseUnverified(tokenStr, jwt.MapClaims{})
 		if err != nil {
-			http.Error(w, `{"error":"invalid token format"}`, http.StatusUnauthorized)
+			respondError(w, r, unauthorized("Invalid token format"))
 			return
 		}
 
 		kid, ok := unverified.Header["kid"].(string)
 		if !ok || kid == "" {
-			http.Error(w, `{"error":"token missing kid header"}`, http.StatusUnauthorized)
+			respondError(w, r, unauthorized("Token missing kid header"))
 			return
 		}
 
@@ -163,14 +163,14 @@ func ClerkAuthMiddleware(next http.Handler) http.Handler {
 		unverifiedClaims, _ := unverified.Claims.(jwt.MapClaims)
 		issuer, _ := unverifiedClaims["iss"].(string)
 		if issuer == "" {
-			http.Error(w, `{"error":"token missing iss claim"}`, http.StatusUnauthorized)
+			respondError(w, r, unauthorized("Token missing iss claim"))
 			return
 		}
 
 		pubKey, err := jwks.getKey(kid, issuer)
 		if err != nil {
-			log.Printf("JWKS key lookup failed: %v", err)
-			http.Error(w, `{"error":"unable to verify token"}`, http.StatusUnauthorized)
+			slog.WarnContext(r.Context(), "JWKS key lookup failed", "error", err)
+			respondError(w, r, unauthorized("Unable to verify token"))
 			return
 		}
 
@@ -183,19 +183,19 @@ func ClerkAuthMiddleware(next http.Handler) http.Handler {
 		}, jwt.WithExpirationRequired())
 
 		if err != nil || !token.Valid {
-			http.Error(w, `{"error":"invalid or expired token"}`, http.StatusUnauthorized)
+			respondError(w, r, unauthorized("Invalid or expired token"))
 			return
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			http.Error(w, `{"error":"invalid token claims"}`, http.StatusUnauthorized)
+			respondError(w, r, unauthorized("Invalid token claims"))
 			return
 		}
 
 		clerkUserID, _ := claims["sub"].(string)
 		if clerkUserID == "" {
-			http.Error(w, `{"error":"token missing sub claim"}`, http.StatusUnauthorized)
+			respondError(w, r, unauthorized("Token missing sub claim"))
 			return
 		}
 
@@ -205,12 +205,13 @@ func ClerkAuthMiddleware(next http.Handler) http.Handler {
 		// Auto-provision or find existing user
 		internalID, err := FindOrCreateUserByClerkID(clerkUserID, clerkEmail)
 		if err != nil {
-			log.Printf("User provisioning failed for clerk_id=%s: %v", clerkUserID, err)
-			http.Error(w, `{"error":"user provisioning failed"}`, http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "User provisioning failed", "clerk_id", clerkUserID, "error", err)
+			respondError(w, r, &APIError{Status: http.StatusInternalServerError, Code: codeInternal, Message: "User provisioning failed"})
 			return
 		}
 
-		// Inject into context
+		// Inject into context, and name the user in the request's access log
+		setLogUser(r.Context(), internalID)
 		ctx := context.WithValue(r.Context(), userIDKey, internalID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
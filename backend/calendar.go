@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// calendarDays is how far ahead the calendar feed projects the focus.
+const calendarDays = 14
+
+// calendarEvent is one all-day event in the ICS feed.
+type calendarEvent struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+	URL         string
+}
+
+// focusEvent builds the event for a problem in the focus on a given day.
+// The UID depends only on the day and problem, so a projected event keeps
+// its identity when that day's plan is materialized.
+func focusEvent(p Problem, day time.Time, status string) calendarEvent {
+	description := status
+	if p.Link != "" {
+		description += "\n" + p.Link
+	}
+	if strings.TrimSpace(p.Notes) != "" {
+		description += "\n\n" + strings.TrimSpace(p.Notes)
+	}
+	return calendarEvent{
+		UID:         fmt.Sprintf("focus-%s-%s@dsa-revisit", day.Format("20060102"), p.ID),
+		Date:        day,
+		Summary:     p.Title,
+		Description: description,
+		URL:         p.Link,
+	}
+}
+
+// icsEscape escapes a TEXT value (RFC 5545 section 3.3.11).
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// foldICSLine splits a content line into 75-octet chunks joined by CRLF and
+// a space, without breaking UTF-8 sequences.
+func foldICSLine(line string) string {
+	var b strings.Builder
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // continuation lines start with the space
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// buildICS renders events as a VCALENDAR.
+func buildICS(events []calendarEvent, now time.Time) string {
+	var b strings.Builder
+	write := func(line string) { b.WriteString(foldICSLine(line)) }
+
+	write("BEGIN:VCALENDAR")
+	write("VERSION:2.0")
+	write("PRODID:-//DSA Revisit//Focus Calendar//EN")
+	write("CALSCALE:GREGORIAN")
+	write("METHOD:PUBLISH")
+	write("X-WR-CALNAME:DSA Revisit")
+	write("REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	write("X-PUBLISHED-TTL:PT1H")
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, e := range events {
+		write("BEGIN:VEVENT")
+		write("UID:" + e.UID)
+		write("DTSTAMP:" + stamp)
+		write("DTSTART;VALUE=DATE:" + e.Date.Format("20060102"))
+		write("DTEND;VALUE=DATE:" + e.Date.AddDate(0, 0, 1).Format("20060102"))
+		write("SUMMARY:" + icsEscape(e.Summary))
+		if e.Description != "" {
+			write("DESCRIPTION:" + icsEscape(e.Description))
+		}
+		if e.URL != "" {
+			write("URL:" + e.URL)
+		}
+		write("TRANSP:TRANSPARENT")
+		write("END:VEVENT")
+	}
+
+	write("END:VCALENDAR")
+	return b.String()
+}
+
+// calendarEvents lists today's materialized focus followed by the focus the
+// scheduler projects for the next calendarDays days.
+func calendarEvents(plan []PlanItem, problems []Problem, days []ForecastDay, now time.Time) []calendarEvent {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var events []calendarEvent
+	for _, item := range plan {
+		status := "Today's focus"
+		if item.RevisitedToday {
+			status = "Today's focus (revisited)"
+		}
+		events = append(events, focusEvent(item.Problem, today, status))
+	}
+
+	byID := make(map[uuid.UUID]Problem, len(problems))
+	for _, p := range problems {
+		byID[p.ID] = p
+	}
+	for i, day := range days {
+		date := today.AddDate(0, 0, i+1)
+		for _, id := range day.SelectedIDs {
+			if p, ok := byID[id]; ok {
+				events = append(events, focusEvent(p, date, "Projected focus (may change as you revisit)"))
+			}
+		}
+	}
+	return events
+}
+
+// GetCalendarFeed serves the user's focus as an ICS calendar. It is public
+// and authorized by the secret token in the URL; the feed is rebuilt on
+// every request.
+func GetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	userID, ok, err := resolveFeedToken(feedKindCalendar, chi.URLParam(r, "token"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if !ok {
+		respondError(w, r, notFound("Feed"))
+		return
+	}
+
+	var prefs UserPreferences
+	if err := db.QueryRow("SELECT preferences FROM users WHERE id = $1", userID).Scan(&prefs); err != nil {
+		slog.WarnContext(r.Context(), "Error fetching preferences, using defaults", "user_id", userID, "error", err)
+		prefs = UserPreferences{ProblemsPerDay: 3, MinRevisitDays: 2, MaxRevisitDays: 10}
+	}
+
+	// The feed shows today's plan, so make sure it exists
+	if _, err := EnsureDailyPlan(userID, prefs); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	now := time.Now()
+	problems, plan, err := loadForecastProblems(userID, prefs, now)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	days := SimulateForecast(problems, prefs, now, calendarDays)
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(buildICS(calendarEvents(plan, problems, days, now), now)))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFoldICSLine(t *testing.T) {
+	if got := foldICSLine("SUMMARY:short"); got != "SUMMARY:short\r\n" {
+		t.Errorf("short line changed: %q", got)
+	}
+
+	long := "DESCRIPTION:" + strings.Repeat("é", 60) // 12 + 120 octets
+	folded := foldICSLine(long)
+	lines := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+	if len(lines) < 2 {
+		t.Fatalf("expected the line to be folded, got %q", folded)
+	}
+	var rejoined string
+	for i, line := range lines {
+		if len(line) > 75 {
+			t.Errorf("line %d is %d octets", i, len(line))
+		}
+		if i > 0 {
+			if !strings.HasPrefix(line, " ") {
+				t.Errorf("continuation line %d doesn't start with a space", i)
+			}
+			line = line[1:]
+		}
+		rejoined += line
+	}
+	if rejoined != long {
+		t.Error("unfolding did not restore the original line (split inside a UTF-8 sequence?)")
+	}
+}
+
+func TestICSEscape(t *testing.T) {
+	got := icsEscape("a,b;c\\d\nnext")
+	if want := `a\,b\;c\\d\nnext`; got != want {
+		t.Errorf("icsEscape = %q, want %q", got, want)
+	}
+}
+
+func TestCalendarEvents(t *testing.T) {
+	now := time.Date(2026, 4, 10, 18, 0, 0, 0, time.UTC)
+	a := Problem{ID: uuid.New(), Title: "Two Sum", Link: "https://leetcode.com/problems/two-sum/", Notes: "hash map"}
+	b := Problem{ID: uuid.New(), Title: "LRU Cache"}
+
+	plan := []PlanItem{{Problem: a, RevisitedToday: true}}
+	days := []ForecastDay{{SelectedIDs: []uuid.UUID{b.ID}}, {SelectedIDs: []uuid.UUID{a.ID, b.ID}}}
+	events := calendarEvents(plan, []Problem{a, b}, days, now)
+
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	wantDates := []string{"2026-04-10", "2026-04-11", "2026-04-12", "2026-04-12"}
+	for i, e := range events {
+		if d := e.Date.Format("2006-01-02"); d != wantDates[i] {
+			t.Errorf("event %d on %s, want %s", i, d, wantDates[i])
+		}
+	}
+	if events[0].UID != "focus-20260410-"+a.ID.String()+"@dsa-revisit" {
+		t.Errorf("unexpected UID %s", events[0].UID)
+	}
+	if !strings.Contains(events[0].Description, "revisited") || !strings.Contains(events[0].Description, "hash map") {
+		t.Errorf("unexpected description %q", events[0].Description)
+	}
+
+	// UIDs depend only on day and problem, so regenerating gives the same feed
+	again := calendarEvents(plan, []Problem{a, b}, days, now.Add(time.Hour))
+	for i := range events {
+		if events[i].UID != again[i].UID {
+			t.Errorf("event %d UID changed between builds", i)
+		}
+	}
+
+	ics := buildICS(events, now)
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"DTSTART;VALUE=DATE:20260410\r\n",
+		"DTEND;VALUE=DATE:20260411\r\n",
+		"SUMMARY:LRU Cache\r\n",
+		"URL:https://leetcode.com/problems/two-sum/\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("ICS is missing %q", want)
+		}
+	}
+	if strings.Count(ics, "BEGIN:VEVENT") != 4 {
+		t.Errorf("expected 4 VEVENTs")
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"dsa-revisit/links"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+//go:embed catalog/problems.json catalog/lists.json
+var catalogFiles embed.FS
+
+// catalogSeedProblem is one entry of catalog/problems.json. ID is only used
+// to reference the problem from lists.json; the database keys catalog
+// problems by their canonical link.
+type catalogSeedProblem struct {
+	ID         string   `json:"id"`
+	Title      string   `json:"title"`
+	Link       string   `json:"link"`
+	Difficulty string   `json:"difficulty"`
+	Topics     []string `json:"topics"`
+}
+
+// catalogSeedList is one entry of catalog/lists.json.
+type catalogSeedList struct {
+	Slug        string   `json:"slug"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Items       []string `json:"items"` // catalog problem IDs, in list order
+}
+
+// catalogRecord is a seed problem resolved to its canonical link, in the shape
+// seedCatalog hands to jsonb_to_recordset.
+type catalogRecord struct {
+	Platform   string   `json:"platform"`
+	Key        string   `json:"key"`
+	Title      string   `json:"title"`
+	Link       string   `json:"link"`
+	Difficulty string   `json:"difficulty"`
+	Topics     []string `json:"topics"`
+}
+
+// loadCatalogSeed parses and validates the embedded catalog data. Every link
+// must be recognized by the links package and every list item must exist.
+func loadCatalogSeed() (map[string]catalogRecord, []catalogSeedList, error) {
+	var problems []catalogSeedProblem
+	var lists []catalogSeedList
+	if err := readCatalogFile("catalog/problems.json", &problems); err != nil {
+		return nil, nil, err
+	}
+	if err := readCatalogFile("catalog/lists.json", &lists); err != nil {
+		return nil, nil, err
+	}
+
+	records := make(map[string]catalogRecord, len(problems))
+	for _, p := range problems {
+		if _, dup := records[p.ID]; dup {
+			return nil, nil, fmt.Errorf("catalog: duplicate problem id %q", p.ID)
+		}
+		canon, ok := links.Parse(p.Link)
+		if !ok {
+			return nil, nil, fmt.Errorf("catalog: unrecognized link %q for %q", p.Link, p.ID)
+		}
+		records[p.ID] = catalogRecord{
+			Platform:   canon.Platform,
+			Key:        canon.Key,
+			Title:      p.Title,
+			Link:       canon.URL,
+			Difficulty: p.Difficulty,
+			Topics:     p.Topics,
+		}
+	}
+
+	for _, l := range lists {
+		seen := make(map[string]bool, len(l.Items))
+		for _, id := range l.Items {
+			if _, ok := records[id]; !ok {
+				return nil, nil, fmt.Errorf("catalog: list %q references unknown problem %q", l.Slug, id)
+			}
+			if seen[id] {
+				return nil, nil, fmt.Errorf("catalog: list %q contains %q twice", l.Slug, id)
+			}
+			seen[id] = true
+		}
+	}
+
+	return records, lists, nil
+}
+
+func readCatalogFile(name string, v interface{}) error {
+	b, err := catalogFiles.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("catalog: %s: %w", name, err)
+	}
+	return nil
+}
+
+// seedCatalog upserts the embedded catalog and lists, then links existing user
+// problems to catalog entries with the same canonical link. It runs on every
+// startup, so editing the data files is all it takes to update the catalog.
+func seedCatalog() error {
+	records, lists, err := loadCatalogSeed()
+	if err != nil {
+		return err
+	}
+
+	all := make([]catalogRecord, 0, len(records))
+	for _, rec := range records {
+		all = append(all, rec)
+	}
+	problemsJSON, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO catalog_problems (platform, canonical_key, title, link, difficulty, topics)
+		SELECT x.platform, x.key, x.title, x.link, x.difficulty, COALESCE(x.topics, '[]')
+		FROM jsonb_to_recordset($1::jsonb)
+		     AS x(platform text, key text, title text, link text, difficulty text, topics jsonb)
+		ON CONFLICT (platform, canonical_key) DO UPDATE
+		SET title = EXCLUDED.title, link = EXCLUDED.link,
+		    difficulty = EXCLUDED.difficulty, topics = EXCLUDED.topics`, string(problemsJSON))
+	if err != nil {
+		return err
+	}
+
+	for _, l := range lists {
+		var listID uuid.UUID
+		err := tx.QueryRow(`
+			INSERT INTO catalog_lists (slug, name, description)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (slug) DO UPDATE SET name = EXCLUDED.name, description = EXCLUDED.description
+			RETURNING id`, l.Slug, l.Name, l.Description).Scan(&listID)
+		if err != nil {
+			return err
+		}
+
+		items := make([]catalogRecord, len(l.Items))
+		for i, id := range l.Items {
+			items[i] = records[id]
+		}
+		itemsJSON, err := json.Marshal(items)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM catalog_list_items WHERE list_id = $1`, listID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO catalog_list_items (list_id, catalog_problem_id, position)
+			SELECT $1, c.id, x.ord
+			FROM jsonb_array_elements($2::jsonb) WITH ORDINALITY AS x(item, ord)
+			JOIN catalog_problems c
+			  ON c.platform = x.item->>'platform' AND c.canonical_key = x.item->>'key'`,
+			listID, string(itemsJSON))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Link user problems that were added by hand before they were in the catalog
+	_, err = tx.Exec(`
+		UPDATE problems p
+		SET catalog_problem_id = c.id
+		FROM catalog_problems c
+		WHERE p.catalog_problem_id IS NULL
+		  AND p.platform = c.platform AND p.canonical_key = c.canonical_key`)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CatalogProblem is a shared catalog entry.
+type CatalogProblem struct {
+	ID         uuid.UUID `json:"id"`
+	Platform   string    `json:"platform"`
+	Title      string    `json:"title"`
+	Link       string    `json:"link"`
+	Difficulty string    `json:"difficulty"`
+	Topics     []string  `json:"topics"`
+}
+
+// CatalogList summarizes a curated list and how much of it the user has added.
+type CatalogList struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	TotalCount  int    `json:"total_count"`
+	AddedCount  int    `json:"added_count"`
+}
+
+// CatalogListItem is one problem of a list along with the user's copy of it.
+type CatalogListItem struct {
+	Position int            `json:"position"`
+	Problem  CatalogProblem `json:"problem"`
+	// The user's problem linked to this entry, if they have added it
+	ProblemID     uuid.NullUUID `json:"problem_id"`
+	ProblemStatus string        `json:"problem_status,omitempty"`
+}
+
+// CatalogListDetail is the response for GET /catalog/lists/{slug}.
+type CatalogListDetail struct {
+	CatalogList
+	Items []CatalogListItem `json:"items"`
+}
+
+// GetCatalogLists returns all curated lists with the user's progress counts.
+func GetCatalogLists(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	rows, err := db.Query(`
+		SELECT l.slug, l.name, COALESCE(l.description, ''),
+		       COUNT(i.catalog_problem_id),
+		       COUNT(DISTINCT p.catalog_problem_id)
+		FROM catalog_lists l
+		LEFT JOIN catalog_list_items i ON i.list_id = l.id
+		LEFT JOIN problems p
+		       ON p.catalog_problem_id = i.catalog_problem_id AND p.user_id = $1 AND p.status <> 'trashed'
+		GROUP BY l.id
+		ORDER BY l.name`, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	lists := []CatalogList{}
+	for rows.Next() {
+		var l CatalogList
+		if err := rows.Scan(&l.Slug, &l.Name, &l.Description, &l.TotalCount, &l.AddedCount); err != nil {
+			respondError(w, r, err)
+			return
+		}
+		lists = append(lists, l)
+	}
+
+	respondJSONConditional(w, r, lists)
+}
+
+// GetCatalogList returns a curated list's problems in order, marking the ones
+// the user has already added.
+func GetCatalogList(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	slug := chi.URLParam(r, "slug")
+
+	var detail CatalogListDetail
+	var listID uuid.UUID
+	err := db.QueryRow(`
+		SELECT id, slug, name, COALESCE(description, '')
+		FROM catalog_lists WHERE slug = $1`, slug).Scan(&listID, &detail.Slug, &detail.Name, &detail.Description)
+	if err == sql.ErrNoRows {
+		respondError(w, r, notFound("List"))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT i.position, c.id, c.platform, c.title, c.link, COALESCE(c.difficulty, ''), c.topics,
+		       p.id, COALESCE(p.status, '')
+		FROM catalog_list_items i
+		JOIN catalog_problems c ON c.id = i.catalog_problem_id
+		LEFT JOIN problems p
+		       ON p.catalog_problem_id = c.id AND p.user_id = $2 AND p.status <> 'trashed'
+		WHERE i.list_id = $1
+		ORDER BY i.position`, listID, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	detail.Items = []CatalogListItem{}
+	for rows.Next() {
+		var item CatalogListItem
+		var topics []byte
+		c := &item.Problem
+		if err := rows.Scan(&item.Position, &c.ID, &c.Platform, &c.Title, &c.Link, &c.Difficulty, &topics,
+			&item.ProblemID, &item.ProblemStatus); err != nil {
+			respondError(w, r, err)
+			return
+		}
+		if err := json.Unmarshal(topics, &c.Topics); err != nil {
+			respondError(w, r, fmt.Errorf("catalog problem %s: topics: %w", c.ID, err))
+			return
+		}
+		detail.Items = append(detail.Items, item)
+		detail.TotalCount++
+		if item.ProblemID.Valid {
+			detail.AddedCount++
+		}
+	}
+
+	respondJSON(w, http.StatusOK, detail)
+}
+
+// AddCatalogList adds a list's remaining problems (those the user doesn't
+// already track) to the user's problems, in list order.
+// Query params: limit — only add the next N remaining problems.
+func AddCatalogList(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	slug := chi.URLParam(r, "slug")
+
+	var limit sql.NullInt64 // NULL means LIMIT ALL
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			respondError(w, r, invalidField("query.limit", "must be a positive integer"))
+			return
+		}
+		limit = sql.NullInt64{Int64: int64(n), Valid: true}
+	}
+
+	var listID uuid.UUID
+	err := db.QueryRow(`SELECT id FROM catalog_lists WHERE slug = $1`, slug).Scan(&listID)
+	if err == sql.ErrNoRows {
+		respondError(w, r, notFound("List"))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	// Problems the user already tracks — linked to the catalog or added by hand
+	// with the same canonical link — are skipped, as is any concurrent insert.
+	rows, err := db.Query(`
+		INSERT INTO problems (user_id, title, link, status, times_revisited, date_added,
+		                      difficulty, topic, source, platform, canonical_key, catalog_problem_id)
+		SELECT $1, c.title, c.link, 'active', 0, NOW(),
+		       c.difficulty, c.topics->>0, c.platform, c.platform, c.canonical_key, c.id
+		FROM catalog_list_items i
+		JOIN catalog_problems c ON c.id = i.catalog_problem_id
+		WHERE i.list_id = $2
+		  AND NOT EXISTS (
+		      SELECT 1 FROM problems p
+		      WHERE p.user_id = $1 AND p.status <> 'trashed'
+		        AND (p.catalog_problem_id = c.id
+		             OR (p.platform = c.platform AND p.canonical_key = c.canonical_key))
+		  )
+		ORDER BY i.position
+		LIMIT $3
+		ON CONFLICT DO NOTHING
+		RETURNING `+problemColumns, userID, listID, limit)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	added := []Problem{}
+	for rows.Next() {
+		var p Problem
+		if err := scanProblem(rows, &p); err != nil {
+			respondError(w, r, err)
+			return
+		}
+		added = append(added, p)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	for _, p := range added {
+		publishProblemEvent(userID, EventProblemCreated, p.ID)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"added":    len(added),
+		"problems": added,
+	})
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestLoadCatalogSeed(t *testing.T) {
+	records, lists, err := loadCatalogSeed()
+	if err != nil {
+		t.Fatalf("embedded catalog is invalid: %v", err)
+	}
+
+	want := map[string]int{"blind-75": 75, "neetcode-150": 150, "grind-169": 169}
+	for _, l := range lists {
+		if n, ok := want[l.Slug]; ok && len(l.Items) != n {
+			t.Errorf("%s has %d items, want %d", l.Slug, len(l.Items), n)
+		}
+		delete(want, l.Slug)
+	}
+	for slug := range want {
+		t.Errorf("list %s missing from catalog", slug)
+	}
+
+	two := records["two-sum"]
+	if two.Platform != "LeetCode" || two.Key != "two-sum" || two.Link != "https://leetcode.com/problems/two-sum/" {
+		t.Errorf("two-sum record = %+v", two)
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Collection is a user-defined ordered group of problems, e.g. "Google onsite prep".
+type Collection struct {
+	ID           uuid.UUID `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	ProblemCount int       `json:"problem_count"`
+	IsFocus      bool      `json:"is_focus"` // Today's Focus is scoped to this collection
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CollectionDetail is a collection with its problems in order.
+type CollectionDetail struct {
+	Collection
+	Problems []Problem `json:"problems"`
+}
+
+// collectionRequest is the body of POST and PUT /collections. On PUT, omitted
+// fields are left unchanged and problem_ids replaces the whole ordered list.
+type collectionRequest struct {
+	Name        *string      `json:"name"`
+	Description *string      `json:"description"`
+	ProblemIDs  *[]uuid.UUID `json:"problem_ids"`
+}
+
+// errUnknownProblems is returned when a collection update references problems
+// the user doesn't own (or that are trashed).
+var errUnknownProblems = invalidField("body.problem_ids", "contains unknown problems")
+
+// setCollectionItems replaces a collection's items with problemIDs, in order.
+// Repeated IDs keep their first position. Returns the number of items stored.
+func setCollectionItems(tx *sql.Tx, userID, collectionID uuid.UUID, problemIDs []uuid.UUID) (int, error) {
+	seen := make(map[uuid.UUID]bool, len(problemIDs))
+	ids := make([]string, 0, len(problemIDs))
+	for _, id := range problemIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id.String())
+		}
+	}
+	idsJSON, _ := json.Marshal(ids)
+
+	if _, err := tx.Exec(`DELETE FROM collection_items WHERE collection_id = $1`, collectionID); err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec(`
+		INSERT INTO collection_items (collection_id, problem_id, position)
+		SELECT $1, p.id, x.ord
+		FROM jsonb_array_elements_text($2::jsonb) WITH ORDINALITY AS x(id, ord)
+		JOIN problems p ON p.id = x.id::uuid AND p.user_id = $3 AND p.status <> 'trashed'`,
+		collectionID, string(idsJSON), userID)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := result.RowsAffected(); int(n) != len(ids) {
+		return 0, errUnknownProblems
+	}
+	return len(ids), nil
+}
+
+// loadCollectionProblems returns a collection's non-trashed problems in order.
+// Returns sql.ErrNoRows if the collection doesn't exist or isn't the user's.
+func loadCollectionProblems(userID, collectionID uuid.UUID) ([]Problem, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM collections WHERE id = $1 AND user_id = $2)`,
+		collectionID, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := db.Query(`
+		SELECT `+problemColumnsFor("p")+`
+		FROM collection_items ci
+		JOIN problems p ON p.id = ci.problem_id
+		WHERE ci.collection_id = $1 AND p.status <> 'trashed'
+		ORDER BY ci.position`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	problems := []Problem{}
+	for rows.Next() {
+		var p Problem
+		if err := scanProblem(rows, &p); err != nil {
+			return nil, err
+		}
+		problems = append(problems, p)
+	}
+	return problems, rows.Err()
+}
+
+// GetCollections lists the user's collections.
+func GetCollections(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	rows, err := db.Query(`
+		SELECT c.id, c.name, COALESCE(c.description, ''), c.created_at,
+		       COUNT(p.id),
+		       COALESCE(u.preferences->>'focus_collection_id', '') = c.id::text
+		FROM collections c
+		JOIN users u ON u.id = c.user_id
+		LEFT JOIN collection_items ci ON ci.collection_id = c.id
+		LEFT JOIN problems p ON p.id = ci.problem_id AND p.status <> 'trashed'
+		WHERE c.user_id = $1
+		GROUP BY c.id, u.id
+		ORDER BY c.name`, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	collections := []Collection{}
+	for rows.Next() {
+		var c Collection
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.ProblemCount, &c.IsFocus); err != nil {
+			respondError(w, r, err)
+			return
+		}
+		collections = append(collections, c)
+	}
+
+	respondJSONConditional(w, r, collections)
+}
+
+// GetCollection returns a collection with its problems in order.
+func GetCollection(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return
+	}
+
+	var detail CollectionDetail
+	err = db.QueryRow(`
+		SELECT c.id, c.name, COALESCE(c.description, ''), c.created_at,
+		       COALESCE(u.preferences->>'focus_collection_id', '') = c.id::text
+		FROM collections c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.id = $1 AND c.user_id = $2`, id, userID).Scan(
+		&detail.ID, &detail.Name, &detail.Description, &detail.CreatedAt, &detail.IsFocus)
+	if err == sql.ErrNoRows {
+		respondError(w, r, notFound("Collection"))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	detail.Problems, err = loadCollectionProblems(userID, id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	detail.ProblemCount = len(detail.Problems)
+
+	respondJSON(w, http.StatusOK, detail)
+}
+
+// CreateCollection creates a collection, optionally with an initial ordered list of problems.
+func CreateCollection(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	var body collectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, r, invalidBody(err))
+		return
+	}
+	if body.Name == nil || strings.TrimSpace(*body.Name) == "" {
+		respondError(w, r, invalidField("body.name", "is required"))
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var c Collection
+	c.Name = strings.TrimSpace(*body.Name)
+	if body.Description != nil {
+		c.Description = *body.Description
+	}
+	err = tx.QueryRow(`
+		INSERT INTO collections (user_id, name, description)
+		VALUES ($1, $2, NULLIF($3, ''))
+		RETURNING id, created_at`, userID, c.Name, c.Description).Scan(&c.ID, &c.CreatedAt)
+	if isUniqueViolation(err) {
+		respondError(w, r, conflict(codeConflict, "A collection with that name already exists", nil))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if body.ProblemIDs != nil {
+		count, err := setCollectionItems(tx, userID, c.ID, *body.ProblemIDs)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		c.ProblemCount = count
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, c)
+}
+
+// UpdateCollection renames a collection, changes its description and/or
+// replaces its ordered problem list.
+func UpdateCollection(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return
+	}
+
+	var body collectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, r, invalidBody(err))
+		return
+	}
+	if body.Name != nil && strings.TrimSpace(*body.Name) == "" {
+		respondError(w, r, invalidField("body.name", "cannot be empty"))
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var name, description sql.NullString
+	if body.Name != nil {
+		name = sql.NullString{String: strings.TrimSpace(*body.Name), Valid: true}
+	}
+	if body.Description != nil {
+		description = sql.NullString{String: *body.Description, Valid: true}
+	}
+	result, err := tx.Exec(`
+		UPDATE collections
+		SET name = COALESCE($1, name),
+		    description = CASE WHEN $2::text IS NULL THEN description ELSE NULLIF($2, '') END
+		WHERE id = $3 AND user_id = $4`, name, description, id, userID)
+	if isUniqueViolation(err) {
+		respondError(w, r, conflict(codeConflict, "A collection with that name already exists", nil))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		respondError(w, r, notFound("Collection"))
+		return
+	}
+
+	if body.ProblemIDs != nil {
+		if _, err := setCollectionItems(tx, userID, id, *body.ProblemIDs); err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+// DeleteCollection deletes a collection (its problems are kept). If Today's
+// Focus was scoped to it, the scope is cleared.
+func DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return
+	}
+
+	result, err := db.Exec(`DELETE FROM collections WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		respondError(w, r, notFound("Collection"))
+		return
+	}
+
+	_, err = db.Exec(`
+		UPDATE users SET preferences = preferences - 'focus_collection_id'
+		WHERE id = $1 AND preferences->>'focus_collection_id' = $2`, userID, id.String())
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// SetFocusScope scopes Today's Focus to a collection (body: {"collection_id": "..."})
+// or back to all problems (collection_id null). Today's plan is regenerated
+// with the new scope unless something in it was already revisited, in which
+// case the scope applies from tomorrow's plan.
+func SetFocusScope(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	var body struct {
+		CollectionID uuid.NullUUID `json:"collection_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, r, invalidBody(err))
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if body.CollectionID.Valid {
+		var exists bool
+		err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM collections WHERE id = $1 AND user_id = $2)`,
+			body.CollectionID.UUID, userID).Scan(&exists)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		if !exists {
+			respondError(w, r, notFound("Collection"))
+			return
+		}
+		_, err = tx.Exec(`
+			UPDATE users SET preferences = jsonb_set(COALESCE(preferences, '{}'), '{focus_collection_id}', to_jsonb($2::text))
+			WHERE id = $1`, userID, body.CollectionID.UUID.String())
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+	} else {
+		if _, err := tx.Exec(`UPDATE users SET preferences = preferences - 'focus_collection_id' WHERE id = $1`, userID); err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+
+	// Rebuild today's plan with the new scope unless the user has started on it
+	reset, err := resetTodaysPlanIfUnstarted(tx, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	publishSettingsChanged(userID, "focus_scope")
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"focus_collection_id": body.CollectionID,
+		"applies_today":       reset,
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// problemETag is the entity tag of a problem version. Clients send it back in
+// If-Match when they write the problem.
+func problemETag(version int) string {
+	return fmt.Sprintf(`"v%d"`, version)
+}
+
+// preconditionFailed reports a write based on a stale copy of a problem. The
+// details carry the current ETag when it is known (current > 0).
+func preconditionFailed(current int) *APIError {
+	e := &APIError{Status: http.StatusPreconditionFailed, Code: "precondition_failed",
+		Message: "The problem was changed since you loaded it. Reload it and try again."}
+	if current > 0 {
+		e.Details = map[string]string{"current_etag": problemETag(current)}
+	}
+	return e
+}
+
+// checkIfMatch enforces the If-Match precondition of a write to a problem at
+// version current: the header is required and must list the current ETag
+// (or be "*"). Weak tags never match, as RFC 9110 requires for If-Match.
+func checkIfMatch(r *http.Request, current int) error {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return &APIError{Status: http.StatusPreconditionRequired, Code: "precondition_required",
+			Message: "If-Match is required; send the ETag from GET /problems/{id}"}
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == problemETag(current) {
+			return nil
+		}
+	}
+	return preconditionFailed(current)
+}
+
+// respondJSONConditional writes a 200 JSON response with an ETag over the
+// body, so clients revalidating with If-None-Match get 304 Not Modified when
+// nothing changed.
+func respondJSONConditional(w http.ResponseWriter, r *http.Request, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Add("Vary", "Authorization")
+	writeConditional(w, r, "application/json", body, time.Time{})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	cases := []struct {
+		header string
+		status int // 0 when the precondition holds
+	}{
+		{"", http.StatusPreconditionRequired},
+		{`"v3"`, 0},
+		{`"v1", "v3"`, 0},
+		{"*", 0},
+		{`"v2"`, http.StatusPreconditionFailed},
+		{`W/"v3"`, http.StatusPreconditionFailed},
+		{`v3`, http.StatusPreconditionFailed},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("PUT", "/api/problems/x", nil)
+		if c.header != "" {
+			req.Header.Set("If-Match", c.header)
+		}
+		err := checkIfMatch(req, 3)
+		if c.status == 0 {
+			if err != nil {
+				t.Errorf("If-Match %s: unexpected %v", c.header, err)
+			}
+			continue
+		}
+		if apiErr, ok := err.(*APIError); !ok || apiErr.Status != c.status {
+			t.Errorf("If-Match %s: got %v, want status %d", c.header, err, c.status)
+		}
+	}
+}
+
+func TestRespondJSONConditional(t *testing.T) {
+	serve := func(ifNoneMatch string, payload interface{}) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/problems", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		respondJSONConditional(rec, req, payload)
+		return rec
+	}
+
+	first := serve("", []string{"a", "b"})
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected first response: %d %v", first.Code, first.Header())
+	}
+	if cc := first.Header().Get("Cache-Control"); cc != "private, no-cache" {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+	if again := serve(etag, []string{"a", "b"}); again.Code != http.StatusNotModified || again.Body.Len() != 0 {
+		t.Errorf("unchanged list: %d %q", again.Code, again.Body)
+	}
+	if changed := serve(etag, []string{"a", "b", "c"}); changed.Code != http.StatusOK {
+		t.Errorf("changed list: status %d", changed.Code)
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// testAuth authenticates every request as userID.
+func testAuth(userID uuid.UUID) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDKey, userID)))
+		})
+	}
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	routed := map[string]bool{}
+	err := chi.Walk(newRouter(testAuth(uuid.New())), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routed[method+" "+strings.TrimPrefix(route, apiBasePath)] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented := map[string]bool{}
+	for _, op := range apiSpec.Operations() {
+		documented[op] = true
+		if !routed[op] {
+			t.Errorf("%s is documented but not routed", op)
+		}
+	}
+	for op := range routed {
+		if !documented[op] {
+			t.Errorf("%s is routed but not documented", op)
+		}
+	}
+
+	if missing := apiSpec.CheckRefs(); len(missing) > 0 {
+		t.Errorf("unresolved references: %v", missing)
+	}
+	ids := map[string]string{}
+	for path, item := range apiSpec.Paths {
+		for method, op := range item {
+			if prev, dup := ids[op.OperationID]; dup {
+				t.Errorf("operationId %s used by %s and %s %s", op.OperationID, prev, method, path)
+			}
+			ids[op.OperationID] = method + " " + path
+		}
+	}
+}
+
+func TestValidateRequestMiddleware(t *testing.T) {
+	router := newRouter(testAuth(uuid.New()))
+	id := uuid.New().String()
+
+	cases := []struct {
+		method, target, body, field string
+	}{
+		{"POST", "/api/problems", `{"link":"https://leetcode.com/problems/two-sum/"}`, "body.title"},
+		{"POST", "/api/problems", `{"title":42}`, "body.title"},
+		{"POST", "/api/problems?on_duplicate=overwrite", `{"title":"Two Sum"}`, "query.on_duplicate"},
+		{"PUT", "/api/problems/not-a-uuid", `{"title":"Two Sum"}`, "path.id"},
+		{"PATCH", "/api/problems/" + id, `{"title":""}`, "body.title"},
+		{"PATCH", "/api/problems/" + id, `{"difficulty":"Insane"}`, "body.difficulty"},
+		{"POST", "/api/problems", `{"title":"` + strings.Repeat("x", 256) + `"}`, "body.title"},
+		{"GET", "/api/plans?days=abc", ``, "query.days"},
+		{"GET", "/api/problems?limit=1000", ``, "query.limit"},
+		{"GET", "/api/search", ``, "query.q"},
+		{"PUT", "/api/problems/" + id + "/overrides", `{"priority_multiplier":20}`, "body.priority_multiplier"},
+		{"POST", "/api/flashcards/" + id + "/review", `{}`, "body.remembered"},
+		{"PUT", "/api/goal", `{"target_date":"next week","daily_budget":3}`, "body.target_date"},
+		{"POST", "/api/settings/feeds/rss", ``, "path.kind"},
+		{"GET", "/api/problems/" + id + "/solutions/v2", ``, "path.version"},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(c.method, c.target, strings.NewReader(c.body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: status %d, want 400", c.method, c.target, rec.Code)
+			continue
+		}
+		var resp struct {
+			Error struct {
+				Code    string `json:"code"`
+				Details []struct {
+					Field string `json:"field"`
+				} `json:"details"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error.Code != codeInvalidRequest ||
+			len(resp.Error.Details) == 0 || resp.Error.Details[0].Field != c.field {
+			t.Errorf("%s %s: unexpected body %s (want an error on %s)", c.method, c.target, rec.Body, c.field)
+		}
+	}
+
+	// Valid requests reach the handler
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("PUT", "/api/settings", strings.NewReader(`{"problems_per_day":3}`)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("valid request rejected: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newRouter(testAuth(uuid.New())).ServeHTTP(rec, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	op, _, _ := apiSpec.FindOperation("GET", "/openapi.json")
+	if err := apiSpec.ValidateResponse(op, rec.Code, rec.Header(), rec.Body.Bytes()); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %v", rec.Code, err)
+	}
+	var doc struct {
+		OpenAPI string                            `json:"openapi"`
+		Paths   map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil || doc.OpenAPI != "3.1.0" || len(doc.Paths) == 0 {
+		t.Errorf("unexpected document: %v", err)
+	}
+}
+
+// contractClient calls the API through the router and checks every response
+// against the OpenAPI document.
+type contractClient struct {
+	t       *testing.T
+	router  http.Handler
+	covered map[string]bool
+}
+
+func (c *contractClient) do(method, target string, header http.Header, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	c.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		reader = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, apiBasePath+target, reader)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	c.router.ServeHTTP(rec, req)
+
+	path := strings.SplitN(target, "?", 2)[0]
+	op, _, ok := apiSpec.FindOperation(method, path)
+	if !ok {
+		c.t.Fatalf("%s %s is not documented", method, target)
+	}
+	for template, item := range apiSpec.Paths {
+		if item[strings.ToLower(method)] == op {
+			c.covered[method+" "+template] = true
+		}
+	}
+	if rec.Code >= 500 {
+		c.t.Errorf("%s %s: status %d: %s", method, target, rec.Code, rec.Body)
+	}
+	if err := apiSpec.ValidateResponse(op, rec.Code, rec.Header(), rec.Body.Bytes()); err != nil {
+		c.t.Errorf("%s %s: response doesn't match the schema: %v", method, target, err)
+	}
+
+	var decoded map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &decoded)
+	return rec, decoded
+}
+
+// expect calls the API and fails unless the status is want.
+func (c *contractClient) expect(want int, method, target string, body interface{}) map[string]interface{} {
+	c.t.Helper()
+	_, decoded := c.expectWith(want, method, target, nil, body)
+	return decoded
+}
+
+// expectWith is expect with request headers; it also returns the response.
+func (c *contractClient) expectWith(want int, method, target string, header http.Header, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	c.t.Helper()
+	rec, decoded := c.do(method, target, header, body)
+	if rec.Code != want {
+		c.t.Fatalf("%s %s: status %d, want %d: %s", method, target, rec.Code, want, rec.Body)
+	}
+	return rec, decoded
+}
+
+var initTestDB sync.Once
+
+// testUser connects to the scratch database in TEST_DATABASE_URL (skipping
+// the test when it isn't set) and creates a user that is deleted, with all
+// of its data, when the test ends.
+func testUser(t *testing.T) uuid.UUID {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	t.Setenv("DATABASE_URL", dsn)
+	t.Setenv("SMTP_HOST", "")
+	initTestDB.Do(InitDB)
+
+	var userID uuid.UUID
+	email := fmt.Sprintf("test-%s@example.com", uuid.NewString())
+	if err := db.QueryRow(`INSERT INTO users (email, name) VALUES ($1, 'Test') RETURNING id`, email).Scan(&userID); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE id = $1`, userID) })
+	return userID
+}
+
+// testProblem inserts an active problem for userID and returns its ID.
+func testProblem(t *testing.T, userID uuid.UUID, title, link string) uuid.UUID {
+	t.Helper()
+	var id uuid.UUID
+	err := db.QueryRow(`INSERT INTO problems (user_id, title, link) VALUES ($1, $2, $3) RETURNING id`,
+		userID, title, link).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// TestAPIContract runs every operation against a real database and checks
+// the responses against the OpenAPI document. It needs a scratch database:
+// set TEST_DATABASE_URL to run it.
+func TestAPIContract(t *testing.T) {
+	userID := testUser(t)
+
+	c := &contractClient{t: t, router: newRouter(testAuth(userID)), covered: map[string]bool{}}
+
+	// Problems
+	problem := c.expect(201, "POST", "/problems", map[string]string{
+		"title": "Two Sum", "link": "https://leetcode.com/problems/two-sum/", "difficulty": "Easy",
+		"notes": "Q: Which structure gives O(1) lookups?\nA: A hash map",
+	})
+	id := problem["id"].(string)
+	c.expect(409, "POST", "/problems", map[string]string{"title": "Two Sum", "link": "https://leetcode.com/problems/two-sum"})
+	c.expect(200, "POST", "/problems?on_duplicate=merge", map[string]string{"title": "Two Sum", "link": "https://leetcode.com/problems/two-sum", "notes": "merged"})
+	list, _ := c.expectWith(200, "GET", "/problems", nil, nil)
+	c.expectWith(304, "GET", "/problems", http.Header{"If-None-Match": {list.Header().Get("ETag")}}, nil)
+	c.expect(200, "GET", "/problems?limit=1&sort=-title", nil)
+	detail, _ := c.expectWith(200, "GET", "/problems/"+id, nil, nil)
+	etag := detail.Header().Get("ETag")
+	c.expect(200, "GET", "/problems/"+id+"/weight", nil)
+	c.expect(200, "GET", "/problems/weights", nil)
+
+	// Writes need the current ETag
+	edit := map[string]string{"title": "Two Sum", "link": "https://leetcode.com/problems/two-sum/", "difficulty": "Easy"}
+	c.expect(428, "PUT", "/problems/"+id, edit)
+	updated, _ := c.expectWith(200, "PUT", "/problems/"+id, http.Header{"If-Match": {etag}}, edit)
+	c.expectWith(412, "PUT", "/problems/"+id, http.Header{"If-Match": {etag}}, edit)
+	etag = updated.Header().Get("ETag")
+	patchedRec, patched := c.expectWith(200, "PATCH", "/problems/"+id, http.Header{"If-Match": {etag}}, map[string]string{"topic": "Arrays"})
+	if patched["title"] != "Two Sum" || patched["difficulty"] != "Easy" || patched["topic"] != "Arrays" {
+		t.Errorf("PATCH changed omitted fields: %v", patched)
+	}
+	if patchedRec.Header().Get("ETag") == etag {
+		t.Error("PATCH didn't change the ETag")
+	}
+	c.expectWith(400, "PUT", "/problems/"+id, http.Header{"If-Match": {"*"}}, map[string]string{"title": "Two Sum", "link": "ftp://example.com/two-sum"})
+	c.expect(200, "PUT", "/problems/"+id+"/overrides", map[string]interface{}{"pinned": true, "priority_multiplier": 1.5})
+	c.expect(200, "GET", "/problems/today", nil)
+	c.expect(200, "POST", "/problems/"+id+"/focus", nil)
+	c.expect(200, "GET", "/plans", nil)
+	c.expect(200, "GET", "/problems/forecast?days=7", nil)
+	c.expect(200, "GET", "/search?q=two", nil)
+
+	// Revisits and solutions
+	revisit := c.expect(200, "POST", "/problems/"+id+"/revisit", map[string]interface{}{
+		"notes": "used a map", "solution": map[string]string{"language": "go", "code": "package main\n"},
+	})
+	revisitID := revisit["revisit_id"].(string)
+	c.expect(409, "POST", "/problems/"+id+"/revisit", nil)
+	c.expect(200, "GET", "/history", nil)
+	c.expect(200, "GET", "/history?problem_id="+id+"&limit=5", nil)
+	c.expect(200, "PUT", "/history/"+revisitID, map[string]string{"notes": "edited"})
+	c.expect(201, "POST", "/problems/"+id+"/solutions", map[string]string{"language": "go", "code": "package main\n\nfunc main() {}\n"})
+	c.expect(200, "GET", "/problems/"+id+"/solutions", nil)
+	c.expect(200, "GET", "/problems/"+id+"/solutions/latest", nil)
+	c.expect(200, "GET", "/problems/"+id+"/solutions/diff", nil)
+	c.expect(200, "GET", "/problems/"+id+"/solutions/diff?format=text", nil)
+
+	// Flashcards
+	c.expect(200, "GET", "/problems/"+id+"/flashcards", nil)
+	card := c.expect(201, "POST", "/problems/"+id+"/flashcards", map[string]string{"prompt": "Complexity?", "answer": "O(n)"})
+	cardID := card["id"].(string)
+	c.expect(200, "GET", "/flashcards/queue", nil)
+	c.expect(200, "GET", "/flashcards/drill?limit=5", nil)
+	c.expect(200, "PUT", "/flashcards/"+cardID, map[string]string{"prompt": "Time complexity?", "answer": "O(n)"})
+	c.expect(200, "POST", "/flashcards/"+cardID+"/review", map[string]bool{"remembered": true})
+	c.expect(409, "POST", "/flashcards/"+cardID+"/review", map[string]bool{"remembered": true})
+	c.expect(200, "DELETE", "/flashcards/"+cardID, nil)
+
+	// Catalog
+	c.expect(200, "GET", "/catalog/lists", nil)
+	c.expect(200, "GET", "/catalog/lists/blind-75", nil)
+	c.expect(200, "POST", "/catalog/lists/blind-75/add?limit=2", nil)
+	c.expect(200, "GET", "/catalog/lists/blind-75/progress", nil)
+
+	// Collections and focus scope
+	newCollection := map[string]interface{}{"name": "Arrays", "problem_ids": []string{id}}
+	keyed := http.Header{"Idempotency-Key": {uuid.NewString()}}
+	_, collection := c.expectWith(201, "POST", "/collections", keyed, newCollection)
+	collectionID := collection["id"].(string)
+	replayed, again := c.expectWith(201, "POST", "/collections", keyed, newCollection)
+	if again["id"] != collectionID || replayed.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry with the same Idempotency-Key wasn't replayed: %v", again)
+	}
+	c.expectWith(422, "POST", "/collections", keyed, map[string]interface{}{"name": "Graphs"})
+	c.expect(200, "GET", "/collections", nil)
+	c.expect(200, "GET", "/collections/"+collectionID, nil)
+	c.expect(200, "PUT", "/collections/"+collectionID, map[string]string{"description": "warm-ups"})
+	c.expect(200, "GET", "/collections/"+collectionID+"/progress", nil)
+	c.expect(200, "PUT", "/focus-scope", map[string]string{"collection_id": collectionID})
+	c.expect(200, "PUT", "/focus-scope", map[string]interface{}{"collection_id": nil})
+
+	// Goal
+	c.expect(200, "GET", "/goal", nil)
+	c.expect(200, "PUT", "/goal", map[string]interface{}{"target_date": time.Now().AddDate(0, 0, 30).Format("2006-01-02"), "daily_budget": 3})
+	c.expect(200, "GET", "/goal/progress", nil)
+	c.expect(200, "DELETE", "/goal", nil)
+	c.expect(404, "GET", "/goal/progress", nil)
+
+	// Exports
+	c.expect(200, "GET", "/export/anki.apkg", nil)
+	c.expect(200, "GET", "/export/anki.tsv", nil)
+
+	// Settings and feeds
+	c.expect(200, "GET", "/settings", nil)
+	c.expect(200, "PUT", "/settings", map[string]int{"problems_per_day": 3})
+	feedToken := regexp.MustCompile(`/([A-Za-z0-9_-]+)\.(ics|atom)$`)
+	for kind, feed := range map[string]string{feedKindCalendar: "/feeds/calendar/%s.ics", feedKindFocus: "/feeds/focus/%s.atom", feedKindJournal: "/feeds/journal/%s.atom"} {
+		created := c.expect(201, "POST", "/settings/feeds/"+kind, nil)
+		token := feedToken.FindStringSubmatch(created["url"].(string))[1]
+		c.expect(200, "GET", fmt.Sprintf(feed, token), nil)
+	}
+	c.expect(200, "GET", "/settings/feeds", nil)
+	c.expect(200, "DELETE", "/settings/feeds/calendar", nil)
+	c.expect(200, "PUT", "/settings/feeds/journal/public", map[string]bool{"enabled": true})
+	c.expect(200, "GET", "/feeds/journal/public/"+userID.String()+".atom", nil)
+	c.expect(200, "GET", "/health", nil)
+	c.expect(200, "GET", "/openapi.json", nil)
+
+	// Events: read the stream's preamble, then disconnect
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	rec := httptest.NewRecorder()
+	c.router.ServeHTTP(rec, httptest.NewRequest("GET", apiBasePath+"/events", nil).WithContext(ctx))
+	op, _, _ := apiSpec.FindOperation("GET", "/events")
+	if err := apiSpec.ValidateResponse(op, rec.Code, rec.Header(), rec.Body.Bytes()); err != nil || !strings.HasPrefix(rec.Body.String(), "retry:") {
+		t.Errorf("GET /events: %d %v %q", rec.Code, err, rec.Body)
+	}
+	c.covered["GET /events"] = true
+
+	// Jobs
+	c.expect(200, "POST", "/test-email", nil)
+	c.expect(200, "POST", "/admin/run-cron", nil)
+
+	// Archive, trash and purge
+	c.expect(200, "POST", "/problems/"+id+"/archive", nil)
+	c.expect(200, "POST", "/problems/"+id+"/unarchive", nil)
+	c.expect(200, "DELETE", "/history/"+revisitID, nil)
+	c.expect(200, "DELETE", "/collections/"+collectionID, nil)
+	c.expectWith(200, "DELETE", "/problems/"+id, http.Header{"If-Match": {"*"}}, nil)
+	c.expect(200, "GET", "/trash", nil)
+	c.expect(200, "POST", "/problems/"+id+"/restore", nil)
+	c.expectWith(200, "DELETE", "/problems/"+id, http.Header{"If-Match": {"*"}}, nil)
+	c.expect(200, "DELETE", "/trash/"+id, nil)
+
+	for _, op := range apiSpec.Operations() {
+		if !c.covered[op] {
+			t.Errorf("%s was not exercised", op)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cram mode: while an interview goal is active, in-scope problems are
+// scheduled so each gets at least MinRevisits revisits before the target date.
+//
+//   - Intervals compress: a problem with r revisits still needed and d days
+//     left becomes eligible every d/r days, even if min_revisit_days is longer.
+//   - Weight follows the required revisit rate (r/d), how overdue the problem
+//     is against its compressed interval, and how weak its topic is.
+//   - In-scope problems fill the daily budget first; anything left over goes to
+//     the normal scheduler over the rest of the pool.
+
+// weakTopicBoost multiplies the cram weight of topics the user marked as weak.
+const weakTopicBoost = 1.5
+
+// CramGoal holds the goal fields the scheduler needs.
+type CramGoal struct {
+	StartDate   time.Time
+	TargetDate  time.Time
+	DailyBudget int
+	MinRevisits int
+	WeakTopics  []string
+}
+
+// DaysLeft is the number of planning days from now until the target date,
+// counting today but not the target date itself.
+func (g CramGoal) DaysLeft(now time.Time) int {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	target := time.Date(g.TargetDate.Year(), g.TargetDate.Month(), g.TargetDate.Day(), 0, 0, 0, 0, now.Location())
+	days := int(math.Round(target.Sub(today).Hours() / 24))
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
+// isWeakTopic reports whether the user marked topic as weak (case-insensitive).
+func (g CramGoal) isWeakTopic(topic string) bool {
+	for _, t := range g.WeakTopics {
+		if strings.EqualFold(t, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// CramInterval is the compressed revisit interval in days for a problem that
+// still needs `remaining` revisits with `daysLeft` days to go. Never below 1.
+func CramInterval(daysLeft, remaining int) int {
+	if remaining <= 0 {
+		return 0
+	}
+	interval := daysLeft / remaining
+	if interval < 1 {
+		return 1
+	}
+	return interval
+}
+
+// TopicCompletion returns, per topic, the share of required goal revisits done
+// so far (0..1). Topics with low completion are treated as weak.
+func TopicCompletion(inScope []Problem, done map[uuid.UUID]int, minRevisits int) map[string]float64 {
+	required := make(map[string]int)
+	completed := make(map[string]int)
+	for _, p := range inScope {
+		required[p.Topic] += minRevisits
+		completed[p.Topic] += minInt(done[p.ID], minRevisits)
+	}
+	completion := make(map[string]float64, len(required))
+	for topic, req := range required {
+		if req > 0 {
+			completion[topic] = float64(completed[topic]) / float64(req)
+		}
+	}
+	return completion
+}
+
+// CramWeight is the selection weight of an in-scope problem that still needs
+// `remaining` revisits.
+func CramWeight(p Problem, remaining int, goal CramGoal, topicCompletion map[string]float64, now time.Time) float64 {
+	daysLeft := goal.DaysLeft(now)
+	if daysLeft < 1 {
+		daysLeft = 1
+	}
+	interval := float64(CramInterval(daysLeft, remaining))
+
+	// Required rate: 1.0 means it must be revisited every remaining day
+	rate := float64(remaining) / float64(daysLeft)
+
+	// Overdue factor against the compressed interval
+	var sinceLast float64
+	if p.LastRevisitedAt.Valid {
+		sinceLast = now.Sub(p.LastRevisitedAt.Time).Hours() / 24
+	} else {
+		sinceLast = now.Sub(p.DateAdded).Hours() / 24
+	}
+	overdue := 1 + math.Max(0, sinceLast/interval)
+
+	// Weak topics: up to 2x for a topic with nothing done, plus the user's own weak list
+	topicFactor := 2 - topicCompletion[p.Topic]
+	if goal.isWeakTopic(p.Topic) {
+		topicFactor *= weakTopicBoost
+	}
+
+	weight := rate * overdue * topicFactor
+	if p.PriorityMultiplier > 0 {
+		weight *= p.PriorityMultiplier
+	}
+	return weight
+}
+
+// CramCandidates adds the goal's problems from goalPool to the focus-scoped
+// pool, so a goal on one collection still gets its problems scheduled while
+// the focus scope is set to another.
+func CramCandidates(pool, goalPool []Problem, inScope map[uuid.UUID]bool) []Problem {
+	seen := make(map[uuid.UUID]bool, len(pool))
+	for _, p := range pool {
+		seen[p.ID] = true
+	}
+	for _, p := range goalPool {
+		if inScope[p.ID] && !seen[p.ID] {
+			pool = append(pool, p)
+		}
+	}
+	return pool
+}
+
+// SelectCramFocus builds a day's focus while a goal is active. candidates is
+// the start-of-day pool, inScope marks the goal's problems and done holds each
+// problem's revisits since the goal started. Returns the selection and the
+// number of eligible problems.
+func SelectCramFocus(candidates []Problem, inScope map[uuid.UUID]bool, done map[uuid.UUID]int,
+	goal CramGoal, minRevisitDays int, seed int64, now time.Time) ([]Problem, int) {
+
+	daysLeft := goal.DaysLeft(now)
+
+	var scoped []Problem
+	for _, p := range candidates {
+		if inScope[p.ID] {
+			scoped = append(scoped, p)
+		}
+	}
+	completion := TopicCompletion(scoped, done, goal.MinRevisits)
+
+	var pinned, cram, rest []Problem
+	remaining := make(map[uuid.UUID]int)
+	for _, p := range candidates {
+		minDays := minRevisitDays
+		need := 0
+		if inScope[p.ID] {
+			need = goal.MinRevisits - done[p.ID]
+			if need > 0 {
+				minDays = minInt(minDays, CramInterval(daysLeft, need))
+			}
+		}
+		if ok, _ := CheckEligibility(p, minDays, now); !ok {
+			continue
+		}
+		switch {
+		case p.Pinned:
+			pinned = append(pinned, p)
+		case need > 0:
+			remaining[p.ID] = need
+			cram = append(cram, p)
+		default:
+			rest = append(rest, p)
+		}
+	}
+	eligibleCount := len(pinned) + len(cram) + len(rest)
+
+	selected := pinned
+	if n := goal.DailyBudget - len(selected); n > 0 {
+		selected = append(selected, SelectProblemsWeighted(cram, n, seed, func(p Problem) float64 {
+			return CramWeight(p, remaining[p.ID], goal, completion, now)
+		})...)
+	}
+	if n := goal.DailyBudget - len(selected); n > 0 {
+		selected = append(selected, SelectProblemsAt(rest, n, seed, now)...)
+	}
+	return selected, eligibleCount
+}
+
+// GoalProblemStatus is an in-scope problem's standing against the goal.
+type GoalProblemStatus struct {
+	ProblemID uuid.UUID `json:"problem_id"`
+	Title     string    `json:"title"`
+	Topic     string    `json:"topic"`
+	Done      int       `json:"done"`
+	Remaining int       `json:"remaining"`
+}
+
+// GoalProgress reports whether the user is on track to finish the goal.
+type GoalProgress struct {
+	DaysLeft          int                 `json:"days_left"`
+	InScopeCount      int                 `json:"in_scope_count"`
+	RequiredRevisits  int                 `json:"required_revisits"`  // in-scope problems × min_revisits
+	CompletedRevisits int                 `json:"completed_revisits"` // counting at most min_revisits per problem
+	ExpectedByNow     int                 `json:"expected_by_now"`    // completed revisits a linear pace would have reached
+	RequiredPerDay    float64             `json:"required_per_day"`
+	Capacity          int                 `json:"capacity"` // daily_budget × days_left
+	OnTrack           bool                `json:"on_track"`
+	AtRisk            []GoalProblemStatus `json:"at_risk"` // need more revisits than days left
+	TopicCompletion   map[string]float64  `json:"topic_completion"`
+}
+
+// EvaluateGoalProgress computes GoalProgress. The user is on track when the
+// remaining revisits fit in the remaining daily budget and no single problem
+// needs more revisits than there are days left (one revisit per day).
+func EvaluateGoalProgress(goal CramGoal, inScope []Problem, done map[uuid.UUID]int, now time.Time) GoalProgress {
+	progress := GoalProgress{
+		DaysLeft:        goal.DaysLeft(now),
+		InScopeCount:    len(inScope),
+		AtRisk:          []GoalProblemStatus{},
+		TopicCompletion: TopicCompletion(inScope, done, goal.MinRevisits),
+	}
+	progress.RequiredRevisits = len(inScope) * goal.MinRevisits
+	progress.Capacity = goal.DailyBudget * progress.DaysLeft
+
+	for _, p := range inScope {
+		d := minInt(done[p.ID], goal.MinRevisits)
+		progress.CompletedRevisits += d
+		if need := goal.MinRevisits - d; need > progress.DaysLeft {
+			progress.AtRisk = append(progress.AtRisk, GoalProblemStatus{
+				ProblemID: p.ID, Title: p.Title, Topic: p.Topic, Done: d, Remaining: need,
+			})
+		}
+	}
+
+	remaining := progress.RequiredRevisits - progress.CompletedRevisits
+	if progress.DaysLeft > 0 {
+		progress.RequiredPerDay = math.Round(float64(remaining)/float64(progress.DaysLeft)*100) / 100
+	}
+
+	totalDays := CramGoal{TargetDate: goal.TargetDate}.DaysLeft(goal.StartDate)
+	if totalDays > 0 {
+		elapsed := float64(totalDays-progress.DaysLeft) / float64(totalDays)
+		progress.ExpectedByNow = int(math.Floor(elapsed * float64(progress.RequiredRevisits)))
+	}
+
+	progress.OnTrack = remaining <= progress.Capacity && len(progress.AtRisk) == 0
+	return progress
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCramInterval(t *testing.T) {
+	cases := []struct{ daysLeft, remaining, want int }{
+		{14, 3, 4},
+		{10, 5, 2},
+		{2, 3, 1}, // can't fit, but never below 1
+		{14, 0, 0},
+	}
+	for _, c := range cases {
+		if got := CramInterval(c.daysLeft, c.remaining); got != c.want {
+			t.Errorf("CramInterval(%d, %d) = %d, want %d", c.daysLeft, c.remaining, got, c.want)
+		}
+	}
+}
+
+func TestCramGoalDaysLeft(t *testing.T) {
+	now := time.Date(2025, 3, 10, 18, 30, 0, 0, time.Local)
+	goal := CramGoal{TargetDate: time.Date(2025, 3, 17, 0, 0, 0, 0, time.Local)}
+	if got := goal.DaysLeft(now); got != 7 {
+		t.Errorf("DaysLeft = %d, want 7", got)
+	}
+	if got := goal.DaysLeft(now.AddDate(0, 0, 10)); got != 0 {
+		t.Errorf("DaysLeft after target = %d, want 0", got)
+	}
+}
+
+func TestSelectCramFocusCompressesIntervals(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.Local)
+	goal := CramGoal{
+		StartDate:   now.AddDate(0, 0, -2),
+		TargetDate:  now.AddDate(0, 0, 4),
+		DailyBudget: 2,
+		MinRevisits: 3,
+	}
+	revisitedYesterday := func(topic string) Problem {
+		return Problem{
+			ID: uuid.New(), Topic: topic, DateAdded: now.AddDate(0, 0, -30),
+			LastRevisitedAt: NullTime{sql.NullTime{Time: now.AddDate(0, 0, -1), Valid: true}},
+		}
+	}
+
+	scoped := revisitedYesterday("Graphs")     // 3 revisits needed in 4 days → interval 1, eligible
+	outOfScope := revisitedYesterday("Arrays") // min_revisit_days 5 applies, not eligible
+	inScope := map[uuid.UUID]bool{scoped.ID: true}
+
+	selected, eligible := SelectCramFocus([]Problem{scoped, outOfScope}, inScope, map[uuid.UUID]int{}, goal, 5, 1, now)
+	if eligible != 1 || len(selected) != 1 || selected[0].ID != scoped.ID {
+		t.Errorf("selected %v (eligible %d), want only the in-scope problem", selected, eligible)
+	}
+
+	// Once it has K revisits it is scheduled normally again
+	_, eligible = SelectCramFocus([]Problem{scoped}, inScope, map[uuid.UUID]int{scoped.ID: 3}, goal, 5, 1, now)
+	if eligible != 0 {
+		t.Errorf("problem with K revisits should follow min_revisit_days, got %d eligible", eligible)
+	}
+}
+
+func TestCramCandidates(t *testing.T) {
+	focused, shared, goalOnly, neither := Problem{ID: uuid.New()}, Problem{ID: uuid.New()}, Problem{ID: uuid.New()}, Problem{ID: uuid.New()}
+	inScope := map[uuid.UUID]bool{shared.ID: true, goalOnly.ID: true}
+
+	got := CramCandidates([]Problem{focused, shared}, []Problem{shared, goalOnly, neither}, inScope)
+	if len(got) != 3 || got[0].ID != focused.ID || got[1].ID != shared.ID || got[2].ID != goalOnly.ID {
+		t.Errorf("CramCandidates = %v, want the focus pool plus the goal-only problem", got)
+	}
+}
+
+func TestCramWeightFavorsWeakTopics(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.Local)
+	goal := CramGoal{TargetDate: now.AddDate(0, 0, 10), MinRevisits: 3, WeakTopics: []string{"dp"}}
+	p := Problem{DateAdded: now.AddDate(0, 0, -5), Topic: "Graphs"}
+	weak := p
+	weak.Topic = "DP"
+
+	completion := map[string]float64{"Graphs": 0.5, "DP": 0.5}
+	if CramWeight(weak, 3, goal, completion, now) <= CramWeight(p, 3, goal, completion, now) {
+		t.Error("marked weak topic should weigh more")
+	}
+
+	completion = map[string]float64{"Graphs": 0.9, "Trees": 0.1}
+	lagging := p
+	lagging.Topic = "Trees"
+	if CramWeight(lagging, 3, goal, completion, now) <= CramWeight(p, 3, goal, completion, now) {
+		t.Error("topic with lower completion should weigh more")
+	}
+}
+
+func TestEvaluateGoalProgress(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.Local)
+	goal := CramGoal{
+		StartDate:   now.AddDate(0, 0, -5),
+		TargetDate:  now.AddDate(0, 0, 5),
+		DailyBudget: 2,
+		MinRevisits: 3,
+	}
+	a, b := Problem{ID: uuid.New(), Title: "a"}, Problem{ID: uuid.New(), Title: "b"}
+
+	got := EvaluateGoalProgress(goal, []Problem{a, b}, map[uuid.UUID]int{a.ID: 3, b.ID: 1}, now)
+	if got.RequiredRevisits != 6 || got.CompletedRevisits != 4 || got.Capacity != 10 || !got.OnTrack {
+		t.Errorf("progress = %+v, want 4/6 done, capacity 10, on track", got)
+	}
+	if got.ExpectedByNow != 3 {
+		t.Errorf("ExpectedByNow = %d, want 3 (half way)", got.ExpectedByNow)
+	}
+
+	// One day left and b still needs 2 revisits: at risk
+	got = EvaluateGoalProgress(goal, []Problem{a, b}, map[uuid.UUID]int{a.ID: 3, b.ID: 1}, now.AddDate(0, 0, 4))
+	if got.OnTrack || len(got.AtRisk) != 1 || got.AtRisk[0].ProblemID != b.ID {
+		t.Errorf("progress = %+v, want off track with b at risk", got)
+	}
+}
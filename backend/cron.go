@@ -1,7 +1,8 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"time"
 )
 
@@ -15,17 +16,29 @@ func StartCron() {
 			RunDailyJob(false)
 		}
 	}()
+
+	// Purge expired trash, idempotency keys and rate limit buckets hourly
+	go func() {
+		purgeTicker := time.NewTicker(time.Hour)
+		defer purgeTicker.Stop()
+		for range purgeTicker.C {
+			RunPurgeTrashJob()
+			RunPurgeIdempotencyKeysJob()
+			RunSweepRateLimitsJob()
+		}
+	}()
 }
 
 // RunDailyJob is the main logic for the cron.
 // If force is true, it skips the check for last_email_sent_at.
 func RunDailyJob(force bool) {
-	log.Printf("[Cron] Starting daily job check (force=%v)...", force)
+	ctx := startJobRun(context.Background(), "daily")
+	slog.DebugContext(ctx, "Starting daily job check", "force", force)
 
 	// 1. Fetch all users
 	rows, err := db.Query("SELECT id, email, preferences, last_email_sent_at FROM users")
 	if err != nil {
-		log.Printf("[Cron] Error fetching users: %v", err)
+		slog.ErrorContext(ctx, "Error fetching users", "error", err)
 		return
 	}
 	defer rows.Close()
@@ -37,13 +50,13 @@ func RunDailyJob(force bool) {
 		var u User
 		var lastSent NullTime
 		if err := rows.Scan(&u.ID, &u.Email, &u.Preferences, &lastSent); err != nil {
-			log.Printf("[Cron] Error scanning user: %v", err)
+			slog.ErrorContext(ctx, "Error scanning user", "error", err)
 			continue
 		}
 
 		// 1.5. Skip if already sent today (unless forced)
 		if !force && lastSent.Valid && lastSent.Time.Format("2006-01-02") == today {
-			log.Printf("[Cron] Skipping user %s: Already sent today", u.Email)
+			slog.DebugContext(ctx, "Skipping user: already sent today", "user_id", u.ID)
 			continue
 		}
 
@@ -52,7 +65,7 @@ func RunDailyJob(force bool) {
 		if !force && u.Preferences.EmailTime != "" {
 			preferredTime, err := time.Parse("15:04", u.Preferences.EmailTime)
 			if err != nil {
-				log.Printf("[Cron] Invalid EmailTime for user %s: %s", u.Email, u.Preferences.EmailTime)
+				slog.WarnContext(ctx, "Invalid email time", "user_id", u.ID, "email_time", u.Preferences.EmailTime)
 				// Continue anyway or skip? Let's skip and log error
 				continue
 			}
@@ -64,62 +77,42 @@ func RunDailyJob(force bool) {
 
 			// Only send if current time is at or after preferred time
 			if currentHour < prefHour || (currentHour == prefHour && currentMinute < prefMin) {
-				log.Printf("[Cron] Skipping user %s: Too early for preferred time %s", u.Email, u.Preferences.EmailTime)
+				slog.DebugContext(ctx, "Skipping user: too early for preferred time", "user_id", u.ID, "email_time", u.Preferences.EmailTime)
 				continue
 			}
 		}
 
-		log.Printf("[Cron] Processing user %s...", u.Email)
+		slog.InfoContext(ctx, "Processing user", "user_id", u.ID)
 
-		// 3. Fetch eligible problems
-		probRows, err := db.Query(`
-			SELECT id, user_id, title, link, date_added, last_revisited_at, times_revisited, status 
-			FROM problems 
-			WHERE user_id = $1 AND status = 'active'
-			ORDER BY date_added ASC`, u.ID)
+		// 3. Load (or generate) today's plan — the same selection the dashboard shows
+		plan, err := GetTodaysPlan(u.ID, u.Preferences)
 		if err != nil {
-			log.Printf("[Cron] Error fetching problems for user %s: %v", u.ID, err)
+			slog.ErrorContext(ctx, "Error building daily plan", "user_id", u.ID, "error", err)
 			continue
 		}
 
-		var eligibleProblems []Problem
-		for probRows.Next() {
-			var p Problem
-			probRows.Scan(&p.ID, &p.UserID, &p.Title, &p.Link, &p.DateAdded, &p.LastRevisitedAt, &p.TimesRevisited, &p.Status)
-
-			daysSinceLast := 9999.0
-			if p.LastRevisitedAt.Valid {
-				daysSinceLast = time.Since(p.LastRevisitedAt.Time).Hours() / 24
-			}
+		// 4. Only email problems that haven't been revisited yet today
+		toSend := pendingProblems(plan)
 
-			if daysSinceLast >= float64(u.Preferences.MinRevisitDays) {
-				eligibleProblems = append(eligibleProblems, p)
-			}
-		}
-		probRows.Close()
-
-		if len(eligibleProblems) == 0 {
-			log.Printf("[Cron] No eligible problems for user %s", u.Email)
+		if len(toSend) == 0 {
+			slog.InfoContext(ctx, "No planned problems to send", "user_id", u.ID)
 			continue
 		}
 
-		// 4. Select problems (Deterministic per day)
-		toSend := SelectProblemsSeeded(eligibleProblems, u.Preferences.ProblemsPerDay, DaySeed())
-
 		// 5. Send Email
 		if len(toSend) > 0 {
 			err := SendEmail(u.Email, toSend)
 			if err != nil {
-				log.Printf("[Cron] Error sending email to %s: %v", u.Email, err)
+				slog.ErrorContext(ctx, "Error sending daily email", "user_id", u.ID, "error", err)
 				continue
 			}
 
 			// 6. Mark as sent in DB
 			_, err = db.Exec("UPDATE users SET last_email_sent_at = NOW() WHERE id = $1", u.ID)
 			if err != nil {
-				log.Printf("[Cron] Error updating last_email_sent_at for user %s: %v", u.Email, err)
+				slog.ErrorContext(ctx, "Error updating last_email_sent_at", "user_id", u.ID, "error", err)
 			}
-			log.Printf("[Cron] Successfully sent daily email to %s with %d problems", u.Email, len(toSend))
+			slog.InfoContext(ctx, "Sent daily email", "user_id", u.ID, "problems", len(toSend))
 		}
 	}
 }
@@ -2,7 +2,8 @@ package main
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
 	"strings"
 	"time"
@@ -17,7 +18,8 @@ func InitDB() {
 	var err error
 	connStr := os.Getenv("DATABASE_URL")
 	if connStr == "" {
-		log.Fatal("DATABASE_URL environment variable is not set")
+		slog.Error("DATABASE_URL environment variable is not set")
+		os.Exit(1)
 	}
 
 	// Supabase Pooler (Transaction Mode) Fix:
@@ -29,13 +31,14 @@ func InitDB() {
 			} else {
 				connStr += "?default_query_exec_mode=simple_protocol"
 			}
-			log.Println("Database: Detected Supabase Pooler, enabling simple_protocol mode")
+			slog.Info("Detected Supabase Pooler, enabling simple_protocol mode")
 		}
 	}
 
 	db, err = sql.Open("pgx", connStr)
 	if err != nil {
-		log.Fatalf("Unable to opening database driver: %v\n", err)
+		slog.Error("Unable to open database driver", "error", err)
+		os.Exit(1)
 	}
 
 	// Retry ping with backoff — Postgres may still be starting
@@ -43,14 +46,15 @@ func InitDB() {
 		if err = db.Ping(); err == nil {
 			break
 		}
-		log.Printf("Waiting for database (attempt %d/10): %v", attempt, err)
+		slog.Warn("Waiting for database", "attempt", attempt, "max_attempts", 10, "error", err)
 		time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
 	}
 	if err != nil {
-		log.Fatalf("Unable to ping database after retries: %v\n", err)
+		slog.Error("Unable to ping database after retries", "error", err)
+		os.Exit(1)
 	}
 
-	log.Println("Successfully connected to the database")
+	slog.Info("Connected to the database")
 
 	// Run migrations
 	runMigrations()
@@ -60,28 +64,343 @@ func InitDB() {
 func runMigrations() {
 	_, err := db.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS clerk_id VARCHAR(255) UNIQUE`)
 	if err != nil {
-		log.Printf("Migration warning (clerk_id column): %v", err)
+		slog.Warn("Migration failed", "migration", "clerk_id column", "error", err)
 	} else {
-		log.Println("Migration: clerk_id column ensured")
+		slog.Info("Migration ensured", "migration", "clerk_id column")
 	}
 
 	_, err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_clerk_id ON users(clerk_id)`)
 	if err != nil {
-		log.Printf("Migration warning (clerk_id index): %v", err)
+		slog.Warn("Migration failed", "migration", "clerk_id index", "error", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS last_email_sent_at TIMESTAMP WITH TIME ZONE`)
 	if err != nil {
-		log.Printf("Migration warning (last_email_sent_at column): %v", err)
+		slog.Warn("Migration failed", "migration", "last_email_sent_at column", "error", err)
 	} else {
-		log.Println("Migration: last_email_sent_at column ensured")
+		slog.Info("Migration ensured", "migration", "last_email_sent_at column")
 	}
 
 	_, err = db.Exec(`ALTER TABLE problems ADD COLUMN IF NOT EXISTS notes TEXT`)
 	if err != nil {
-		log.Printf("Migration warning (notes column): %v", err)
+		slog.Warn("Migration failed", "migration", "notes column", "error", err)
 	} else {
-		log.Println("Migration: notes column ensured")
+		slog.Info("Migration ensured", "migration", "notes column")
+	}
+
+	_, err = db.Exec(`
+		ALTER TABLE problems
+			ADD COLUMN IF NOT EXISTS trashed_at TIMESTAMP WITH TIME ZONE,
+			ADD COLUMN IF NOT EXISTS previous_status VARCHAR(50)`)
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "trash columns", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "trash columns")
+	}
+
+	_, err = db.Exec(`
+		ALTER TABLE problems
+			ADD COLUMN IF NOT EXISTS snoozed_until TIMESTAMP WITH TIME ZONE,
+			ADD COLUMN IF NOT EXISTS hold_until TIMESTAMP WITH TIME ZONE,
+			ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE,
+			ADD COLUMN IF NOT EXISTS priority_multiplier DOUBLE PRECISION NOT NULL DEFAULT 1.0`)
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "scheduling override columns", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "scheduling override columns")
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS daily_plans (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			plan_date DATE NOT NULL,
+			eligible_count INT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (user_id, plan_date)
+		)`)
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "daily_plans table", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "daily_plans table")
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS daily_plan_items (
+			plan_id UUID NOT NULL REFERENCES daily_plans(id) ON DELETE CASCADE,
+			problem_id UUID NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+			position INT NOT NULL,
+			PRIMARY KEY (plan_id, problem_id)
+		)`)
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "daily_plan_items table", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "daily_plan_items table")
+	}
+
+	// Purging a problem must not rewrite past plans: its items lose the
+	// reference but keep a snapshot of the title, link and completion.
+	_, err = db.Exec(`
+		ALTER TABLE daily_plan_items
+			ADD COLUMN IF NOT EXISTS title VARCHAR(255),
+			ADD COLUMN IF NOT EXISTS link TEXT,
+			ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP WITH TIME ZONE,
+			DROP CONSTRAINT IF EXISTS daily_plan_items_pkey,
+			ALTER COLUMN problem_id DROP NOT NULL,
+			DROP CONSTRAINT IF EXISTS daily_plan_items_problem_id_fkey,
+			ADD CONSTRAINT daily_plan_items_problem_id_fkey
+				FOREIGN KEY (problem_id) REFERENCES problems(id) ON DELETE SET NULL;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_daily_plan_items_plan_problem ON daily_plan_items(plan_id, problem_id)`)
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "daily_plan_items purge snapshot", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "daily_plan_items purge snapshot")
+	}
+
+	// Full-text search: generated tsvector columns kept per field so a hit can
+	// report whether it matched the title, the problem notes or a revisit journal.
+	_, err = db.Exec(`
+		ALTER TABLE problems
+			ADD COLUMN IF NOT EXISTS title_tsv tsvector
+				GENERATED ALWAYS AS (to_tsvector('english', COALESCE(title, ''))) STORED,
+			ADD COLUMN IF NOT EXISTS notes_tsv tsvector
+				GENERATED ALWAYS AS (to_tsvector('english', COALESCE(notes, ''))) STORED`)
+	if err == nil {
+		_, err = db.Exec(`
+			ALTER TABLE revisit_history
+				ADD COLUMN IF NOT EXISTS notes_tsv tsvector
+					GENERATED ALWAYS AS (to_tsvector('english', COALESCE(notes, ''))) STORED`)
+	}
+	if err == nil {
+		_, err = db.Exec(`
+			CREATE INDEX IF NOT EXISTS idx_problems_title_tsv ON problems USING GIN (title_tsv);
+			CREATE INDEX IF NOT EXISTS idx_problems_notes_tsv ON problems USING GIN (notes_tsv);
+			CREATE INDEX IF NOT EXISTS idx_revisit_history_notes_tsv ON revisit_history USING GIN (notes_tsv)`)
+	}
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "full-text search columns", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "full-text search columns")
+	}
+
+	// Canonical links for duplicate detection. platform is '' for links we
+	// don't recognize; NULL means the row hasn't been backfilled yet.
+	_, err = db.Exec(`
+		ALTER TABLE problems
+			ADD COLUMN IF NOT EXISTS platform VARCHAR(50),
+			ADD COLUMN IF NOT EXISTS canonical_key VARCHAR(255)`)
+	if err == nil {
+		err = backfillCanonicalLinks()
+	}
+	if err == nil {
+		_, err = db.Exec(`
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_problems_user_canonical_link
+			ON problems(user_id, platform, canonical_key)
+			WHERE canonical_key IS NOT NULL AND status <> 'trashed'`)
+	}
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "canonical link columns", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "canonical link columns")
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS catalog_problems (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			platform VARCHAR(50) NOT NULL,
+			canonical_key VARCHAR(255) NOT NULL,
+			title VARCHAR(255) NOT NULL,
+			link TEXT NOT NULL,
+			difficulty VARCHAR(50),
+			topics JSONB NOT NULL DEFAULT '[]',
+			UNIQUE (platform, canonical_key)
+		);
+		CREATE TABLE IF NOT EXISTS catalog_lists (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			slug VARCHAR(100) UNIQUE NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			description TEXT
+		);
+		CREATE TABLE IF NOT EXISTS catalog_list_items (
+			list_id UUID NOT NULL REFERENCES catalog_lists(id) ON DELETE CASCADE,
+			catalog_problem_id UUID NOT NULL REFERENCES catalog_problems(id) ON DELETE CASCADE,
+			position INT NOT NULL,
+			PRIMARY KEY (list_id, catalog_problem_id)
+		);
+		ALTER TABLE problems
+			ADD COLUMN IF NOT EXISTS catalog_problem_id UUID REFERENCES catalog_problems(id) ON DELETE SET NULL;
+		CREATE INDEX IF NOT EXISTS idx_problems_catalog ON problems(catalog_problem_id)`)
+	if err == nil {
+		err = seedCatalog()
+	}
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "problem catalog", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "problem catalog")
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS collections (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			description TEXT,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (user_id, name)
+		);
+		CREATE TABLE IF NOT EXISTS collection_items (
+			collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+			problem_id UUID NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+			position INT NOT NULL,
+			PRIMARY KEY (collection_id, problem_id)
+		)`)
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "collections tables", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "collections tables")
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS goals (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			target_date DATE NOT NULL,
+			start_date DATE NOT NULL DEFAULT CURRENT_DATE,
+			collection_id UUID REFERENCES collections(id) ON DELETE SET NULL,
+			topic VARCHAR(255),
+			daily_budget INT NOT NULL,
+			min_revisits INT NOT NULL DEFAULT 3,
+			weak_topics JSONB NOT NULL DEFAULT '[]',
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_goals_one_active ON goals(user_id) WHERE active`)
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "goals table", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "goals table")
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS solutions (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			problem_id UUID NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+			revisit_id UUID REFERENCES revisit_history(id) ON DELETE SET NULL,
+			version INT NOT NULL,
+			language VARCHAR(50) NOT NULL,
+			code TEXT NOT NULL,
+			time_complexity VARCHAR(100),
+			space_complexity VARCHAR(100),
+			approach VARCHAR(255),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (problem_id, version)
+		);
+		CREATE INDEX IF NOT EXISTS idx_solutions_revisit ON solutions(revisit_id)`)
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "solutions table", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "solutions table")
+	}
+
+	// Flashcards, plus the cards already written as Q:/A: lines in notes
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS flashcards (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			problem_id UUID NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+			prompt TEXT NOT NULL,
+			answer TEXT NOT NULL,
+			source VARCHAR(10) NOT NULL DEFAULT 'manual',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			last_reviewed_at TIMESTAMP WITH TIME ZONE,
+			times_reviewed INT NOT NULL DEFAULT 0,
+			lapses INT NOT NULL DEFAULT 0
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_flashcards_notes_prompt ON flashcards(problem_id, prompt) WHERE source = 'notes';
+		CREATE TABLE IF NOT EXISTS flashcard_reviews (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			flashcard_id UUID NOT NULL REFERENCES flashcards(id) ON DELETE CASCADE,
+			reviewed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			remembered BOOLEAN NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_flashcard_reviews_card ON flashcard_reviews(flashcard_id, reviewed_at DESC)`)
+	if err == nil {
+		err = backfillNoteFlashcards()
+	}
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "flashcards tables", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "flashcards tables")
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS feed_tokens (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			kind VARCHAR(20) NOT NULL,
+			token_hash VARCHAR(64) NOT NULL UNIQUE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			last_used_at TIMESTAMP WITH TIME ZONE,
+			UNIQUE (user_id, kind)
+		)`)
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "feed_tokens table", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "feed_tokens table")
+	}
+
+	// Problem versions back the ETag/If-Match checks on problem writes. The
+	// trigger bumps the version on every update, whichever code path makes it.
+	_, err = db.Exec(`
+		ALTER TABLE problems ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+		CREATE OR REPLACE FUNCTION bump_problem_version() RETURNS trigger AS $$
+		BEGIN
+			NEW.version := OLD.version + 1;
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;
+		DROP TRIGGER IF EXISTS problems_bump_version ON problems;
+		CREATE TRIGGER problems_bump_version BEFORE UPDATE ON problems
+			FOR EACH ROW EXECUTE FUNCTION bump_problem_version()`)
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "problem versions", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "problem versions")
+	}
+
+	// Idempotency keys remember the first response to a POST so a retry with
+	// the same Idempotency-Key replays it. A NULL status marks a request that
+	// is still running.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			key VARCHAR(255) NOT NULL,
+			request_hash VARCHAR(64) NOT NULL,
+			status INTEGER,
+			content_type TEXT NOT NULL DEFAULT '',
+			body BYTEA,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, key)
+		);
+		CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at)`)
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "idempotency_keys table", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "idempotency_keys table")
+	}
+
+	// Rate limit buckets, shared by all instances when RATE_LIMIT_STORE=postgres
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+			key VARCHAR(255) PRIMARY KEY,
+			tokens DOUBLE PRECISION NOT NULL,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_expires ON rate_limit_buckets(expires_at)`)
+	if err != nil {
+		slog.Warn("Migration failed", "migration", "rate_limit_buckets table", "error", err)
+	} else {
+		slog.Info("Migration ensured", "migration", "rate_limit_buckets table")
 	}
 }
 
@@ -99,9 +418,9 @@ func FindOrCreateUserByClerkID(clerkID string, email string) (uuid.UUID, error)
 		if email != "" && email != storedEmail {
 			_, updateErr := db.Exec(`UPDATE users SET email = $1 WHERE id = $2`, email, userID)
 			if updateErr != nil {
-				log.Printf("Warning: failed to sync email for user %s: %v", userID, updateErr)
+				slog.Warn("Failed to sync user email", "user_id", userID, "error", updateErr)
 			} else {
-				log.Printf("Synced email for user %s: %s -> %s", userID, storedEmail, email)
+				slog.Info("Synced user email", "user_id", userID)
 			}
 		}
 		return userID, nil
@@ -135,6 +454,51 @@ func FindOrCreateUserByClerkID(clerkID string, email string) (uuid.UUID, error)
 		return uuid.Nil, err
 	}
 
-	log.Printf("Auto-provisioned new user: clerk_id=%s, email=%s, internal_id=%s", clerkID, provisionEmail, userID)
+	slog.Info("Auto-provisioned new user", "clerk_id", clerkID, "user_id", userID)
 	return userID, nil
 }
+
+// problemColumnList is the standard set of problem columns, in the order scanProblem expects.
+var problemColumnList = []string{
+	"id", "user_id", "title", "link", "date_added", "last_revisited_at",
+	"times_revisited", "status", "COALESCE(%stopic, '')", "COALESCE(%sdifficulty, '')", "COALESCE(%ssource, 'LeetCode')", "COALESCE(%snotes, '')",
+	"snoozed_until", "hold_until", "COALESCE(%spinned, FALSE)", "COALESCE(%spriority_multiplier, 1.0)",
+	"catalog_problem_id", "COALESCE(%sversion, 1)",
+}
+
+// problemColumns selects a full Problem from an unaliased problems table.
+var problemColumns = problemColumnsFor("")
+
+// problemColumnsFor returns the standard problem column list qualified with a
+// table alias (e.g. "p"), for queries that join problems with other tables.
+func problemColumnsFor(alias string) string {
+	prefix := ""
+	if alias != "" {
+		prefix = alias + "."
+	}
+	cols := make([]string, len(problemColumnList))
+	for i, c := range problemColumnList {
+		if strings.Contains(c, "%s") {
+			cols[i] = fmt.Sprintf(c, prefix)
+		} else {
+			cols[i] = prefix + c
+		}
+	}
+	return strings.Join(cols, ", ")
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProblem scans a row selected with problemColumns into p. Any extra
+// destinations are scanned from the columns that follow.
+func scanProblem(row rowScanner, p *Problem, extra ...interface{}) error {
+	dest := []interface{}{&p.ID, &p.UserID, &p.Title, &p.Link, &p.DateAdded,
+		&p.LastRevisitedAt, &p.TimesRevisited, &p.Status,
+		&p.Topic, &p.Difficulty, &p.Source, &p.Notes,
+		&p.SnoozedUntil, &p.HoldUntil, &p.Pinned, &p.PriorityMultiplier,
+		&p.CatalogProblemID, &p.Version}
+	return row.Scan(append(dest, extra...)...)
+}
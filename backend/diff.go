@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContextLines is how many unchanged lines surround each hunk.
+const diffContextLines = 3
+
+// maxDiffCells bounds the LCS table (lines × lines, after trimming the common
+// prefix and suffix) so a pathological pair of inputs can't exhaust memory.
+const maxDiffCells = 16 << 20
+
+var errDiffTooLarge = fmt.Errorf("inputs are too different to diff")
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+	a, b int // 0-based line index in each side, valid for the sides the op touches
+}
+
+// splitLines splits text into lines, ignoring a single trailing newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n"), "\n")
+}
+
+// diffLines returns the line-level edit script turning a into b.
+func diffLines(a, b []string) ([]diffOp, error) {
+	// Common prefix and suffix need no table
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	midA, midB := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	n, m := len(midA), len(midB)
+	if (n+1)*(m+1) > maxDiffCells {
+		return nil, errDiffTooLarge
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i := 0; i < pre; i++ {
+		ops = append(ops, diffOp{' ', a[i], i, i})
+	}
+
+	// lcs[i][j] is the LCS length of midA[i:] and midB[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && midA[i] == midB[j]:
+			ops = append(ops, diffOp{' ', midA[i], pre + i, pre + j})
+			i++
+			j++
+		case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', midA[i], pre + i, pre + j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', midB[j], pre + i, pre + j})
+			j++
+		}
+	}
+
+	for k := 0; k < suf; k++ {
+		ops = append(ops, diffOp{' ', a[len(a)-suf+k], len(a) - suf + k, len(b) - suf + k})
+	}
+	return ops, nil
+}
+
+// UnifiedDiff returns a unified diff (as produced by `diff -u`) between two
+// texts, labelled fromName and toName. Identical inputs give an empty string.
+func UnifiedDiff(fromText, toText, fromName, toName string) (string, error) {
+	a, b := splitLines(fromText), splitLines(toText)
+	ops, err := diffLines(a, b)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk while changes are within 2×context of each other
+		hunkStart := maxInt(start-diffContextLines, 0)
+		end := start
+		for k := start; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				end = k
+			} else if k-end > 2*diffContextLines {
+				break
+			}
+		}
+		hunkEnd := minInt(end+diffContextLines+1, len(ops))
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		first := ops[hunkStart]
+		var countA, countB int
+		for _, op := range ops[hunkStart:hunkEnd] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(first.a, countA), hunkRange(first.b, countB))
+		for _, op := range ops[hunkStart:hunkEnd] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+		start = hunkEnd
+	}
+	return out.String(), nil
+}
+
+// hunkRange formats a hunk's "start,count" for one side. An empty range
+// points at the line before it, so a hunk inserting at the top is "-0,0".
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiffIdentical(t *testing.T) {
+	got, err := UnifiedDiff("a\nb\n", "a\nb", "v1", "v2")
+	if err != nil || got != "" {
+		t.Errorf("UnifiedDiff of identical texts = %q, %v; want empty", got, err)
+	}
+}
+
+func TestUnifiedDiffSingleHunk(t *testing.T) {
+	from := "def two_sum(nums, target):\n    for i in range(len(nums)):\n        for j in range(i):\n            if nums[i] + nums[j] == target:\n                return [j, i]\n"
+	to := "def two_sum(nums, target):\n    seen = {}\n    for i, x in enumerate(nums):\n        if target - x in seen:\n            return [seen[target - x], i]\n        seen[x] = i\n"
+
+	got, err := UnifiedDiff(from, to, "v1", "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `--- v1
++++ v2
+@@ -1,5 +1,6 @@
+ def two_sum(nums, target):
+-    for i in range(len(nums)):
+-        for j in range(i):
+-            if nums[i] + nums[j] == target:
+-                return [j, i]
++    seen = {}
++    for i, x in enumerate(nums):
++        if target - x in seen:
++            return [seen[target - x], i]
++        seen[x] = i
+`
+	if got != want {
+		t.Errorf("UnifiedDiff =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUnifiedDiffSeparateHunks(t *testing.T) {
+	var a, b []string
+	for i := 1; i <= 20; i++ {
+		line := string(rune('a' + i - 1))
+		a = append(a, line)
+		switch i {
+		case 2:
+			b = append(b, "B")
+		case 18:
+			// deleted
+		default:
+			b = append(b, line)
+		}
+	}
+	got, err := UnifiedDiff(strings.Join(a, "\n"), strings.Join(b, "\n"), "x", "y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `--- x
++++ y
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -15,6 +15,5 @@
+ o
+ p
+ q
+-r
+ s
+ t
+`
+	if got != want {
+		t.Errorf("UnifiedDiff =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUnifiedDiffFromEmpty(t *testing.T) {
+	got, err := UnifiedDiff("", "x\ny\n", "v1", "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- v1\n+++ v2\n@@ -0,0 +1,2 @@\n+x\n+y\n"
+	if got != want {
+		t.Errorf("UnifiedDiff = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"dsa-revisit/links"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// canonicalLink recognizes p.Link and fills in p.Source from the platform when
+// the client left it empty or at the form's "LeetCode" default. It returns the
+// platform and canonical key to store; both are empty for unrecognized links.
+func canonicalLink(p *Problem) (platform string, key sql.NullString) {
+	canon, ok := links.Parse(p.Link)
+	if !ok {
+		if p.Source == "" {
+			p.Source = "LeetCode"
+		}
+		return "", sql.NullString{}
+	}
+	if p.Source == "" || p.Source == links.LeetCode {
+		p.Source = canon.Platform
+	}
+	return canon.Platform, sql.NullString{String: canon.Key, Valid: true}
+}
+
+// findDuplicateProblem returns the user's non-trashed problem with the same
+// canonical link, ignoring excludeID (the problem being updated).
+func findDuplicateProblem(q querier, userID uuid.UUID, platform string, key sql.NullString, excludeID uuid.UUID) (*Problem, error) {
+	if !key.Valid {
+		return nil, nil
+	}
+	var p Problem
+	err := scanProblem(q.QueryRow(`
+		SELECT `+problemColumns+`
+		FROM problems
+		WHERE user_id = $1 AND platform = $2 AND canonical_key = $3
+		  AND status <> 'trashed' AND id <> $4`, userID, platform, key.String, excludeID), &p)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// respondDuplicate writes the 409 returned when a link points at a problem the
+// user already tracks.
+func respondDuplicate(w http.ResponseWriter, r *http.Request, existing *Problem) {
+	var details map[string]string
+	if existing != nil {
+		details = map[string]string{
+			"existing_id":    existing.ID.String(),
+			"existing_title": existing.Title,
+		}
+	}
+	respondError(w, r, conflict("duplicate_problem", "You are already tracking this problem.", details))
+}
+
+// mergeIntoProblem folds a duplicate submission into the existing problem:
+// empty difficulty/topic are filled in and new notes are appended.
+func mergeIntoProblem(existingID uuid.UUID, incoming Problem) (Problem, error) {
+	var p Problem
+	err := scanProblem(db.QueryRow(`
+		UPDATE problems
+		SET difficulty = COALESCE(NULLIF(difficulty, ''), NULLIF($2, '')),
+		    topic = COALESCE(NULLIF(topic, ''), NULLIF($3, '')),
+		    notes = CASE
+		        WHEN $4 = '' OR position($4 in COALESCE(notes, '')) > 0 THEN notes
+		        WHEN COALESCE(notes, '') = '' THEN $4
+		        ELSE notes || E'\n\n' || $4
+		    END
+		WHERE id = $1
+		RETURNING `+problemColumns, existingID, incoming.Difficulty, incoming.Topic, incoming.Notes), &p)
+	return p, err
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint error,
+// e.g. two concurrent creates of the same canonical link.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
+
+// backfillCanonicalLinks computes platform/canonical_key for problems created
+// before link normalization. When a user already has duplicates, only the
+// oldest copy gets the key so the unique index can be built; the others are
+// left for the user to clean up.
+func backfillCanonicalLinks() error {
+	rows, err := db.Query(`
+		SELECT id, user_id, link, status
+		FROM problems
+		WHERE platform IS NULL
+		ORDER BY date_added`)
+	if err != nil {
+		return err
+	}
+
+	type update struct {
+		id       uuid.UUID
+		platform string
+		key      sql.NullString
+	}
+	var updates []update
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var id, userID uuid.UUID
+		var link, status string
+		if err := rows.Scan(&id, &userID, &link, &status); err != nil {
+			rows.Close()
+			return err
+		}
+		u := update{id: id}
+		if canon, ok := links.Parse(link); ok {
+			u.platform = canon.Platform
+			dedupeKey := userID.String() + "|" + canon.Platform + "|" + canon.Key
+			if status == "trashed" || !seen[dedupeKey] {
+				u.key = sql.NullString{String: canon.Key, Valid: true}
+				if status != "trashed" {
+					seen[dedupeKey] = true
+				}
+			} else {
+				slog.Warn("Migration: problem duplicates another, leaving it unlinked", "problem_id", id, "platform", canon.Platform, "key", canon.Key)
+			}
+		}
+		updates = append(updates, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, u := range updates {
+		if _, err := db.Exec(`UPDATE problems SET platform = $1, canonical_key = $2 WHERE id = $3`,
+			u.platform, u.key, u.id); err != nil {
+			return err
+		}
+	}
+	if len(updates) > 0 {
+		slog.Info("Migration: canonicalized links", "problems", len(updates))
+	}
+	return nil
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/smtp"
 	"os"
 	"strings"
@@ -17,6 +17,12 @@ func SendEmail(to string, problems []Problem) error {
 
 	for i, p := range problems {
 		bodyBuilder.WriteString(fmt.Sprintf("%d. %s - %s\n", i+1, p.Title, p.Link))
+		if p.Topic != "" || p.Difficulty != "" {
+			bodyBuilder.WriteString(fmt.Sprintf("   %s\n", strings.Trim(p.Difficulty+" · "+p.Topic, " ·")))
+		}
+		if p.Notes != "" {
+			bodyBuilder.WriteString(fmt.Sprintf("   Notes: %s\n", p.Notes))
+		}
 	}
 
 	bodyBuilder.WriteString("\nKeep going!\n")
@@ -28,12 +34,9 @@ func SendEmail(to string, problems []Problem) error {
 	emailFrom := os.Getenv("EMAIL_FROM")
 
 	if smtpHost == "" {
-		// Development mode: Log email
-		log.Println("=== EMAIL SIMULATION ===")
-		log.Printf("To: %s\n", to)
-		log.Printf("Subject: %s\n", subject)
-		log.Println(bodyBuilder.String())
-		log.Println("========================")
+		// Development mode: log the email instead (the body only at debug level)
+		slog.Info("Email simulated (SMTP_HOST not set)", "to", to, "subject", subject, "problems", len(problems))
+		slog.Debug("Simulated email body", "body", bodyBuilder.String())
 		return nil
 	}
 
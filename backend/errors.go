@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"dsa-revisit/openapi"
+)
+
+// FieldError names one invalid field of a request.
+type FieldError = openapi.FieldError
+
+// APIError is an error a handler can return to the client as is: a status,
+// a stable machine-readable code and a message safe to show to users.
+// Details carries code-specific data such as the invalid fields.
+type APIError struct {
+	Status     int
+	Code       string
+	Message    string
+	Details    interface{}
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string { return e.Code + ": " + e.Message }
+
+// errorEnvelope is the body of every error response.
+type errorEnvelope struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// Error codes clients can rely on.
+const (
+	codeInvalidRequest  = "invalid_request"
+	codeUnauthorized    = "unauthorized"
+	codeForbidden       = "forbidden"
+	codeNotFound        = "not_found"
+	codeConflict        = "conflict"
+	codeUnprocessable   = "unprocessable"
+	codePayloadTooLarge = "payload_too_large"
+	codeRateLimited     = "rate_limited"
+	codeUnavailable     = "unavailable"
+	codeInternal        = "internal_error"
+)
+
+// invalid reports a malformed request, optionally naming the bad fields.
+func invalid(message string, fields ...FieldError) *APIError {
+	e := &APIError{Status: http.StatusBadRequest, Code: codeInvalidRequest, Message: message}
+	if len(fields) > 0 {
+		e.Details = fields
+	}
+	return e
+}
+
+// invalidField reports one bad field; the message reads "<field> <problem>".
+func invalidField(field, problem string) *APIError {
+	return invalid(field+" "+problem, FieldError{Field: field, Message: problem})
+}
+
+// invalidBody reports a request body that doesn't decode. Type mismatches
+// name the field; other decoder messages are not passed on.
+func invalidBody(err error) *APIError {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return payloadTooLarge(tooLarge.Limit)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return invalidField("body."+typeErr.Field, "must be "+jsonTypeName(typeErr.Type.Kind().String()))
+	}
+	return invalid("Request body is not valid JSON")
+}
+
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "string":
+		return "a string"
+	case "bool":
+		return "a boolean"
+	case "slice", "array":
+		return "an array"
+	case "struct", "map":
+		return "an object"
+	default:
+		return "a number"
+	}
+}
+
+func unauthorized(message string) *APIError {
+	return &APIError{Status: http.StatusUnauthorized, Code: codeUnauthorized, Message: message}
+}
+
+func forbidden(message string) *APIError {
+	return &APIError{Status: http.StatusForbidden, Code: codeForbidden, Message: message}
+}
+
+// notFound reports a missing resource, e.g. notFound("Problem").
+func notFound(resource string) *APIError {
+	return &APIError{Status: http.StatusNotFound, Code: codeNotFound, Message: resource + " not found"}
+}
+
+// conflict reports a request that clashes with the current state. code is
+// more specific than "conflict" when clients handle the case, e.g.
+// "duplicate_problem".
+func conflict(code, message string, details interface{}) *APIError {
+	return &APIError{Status: http.StatusConflict, Code: code, Message: message, Details: details}
+}
+
+// unprocessable reports a well-formed request the server can't act on.
+func unprocessable(code, message string) *APIError {
+	return &APIError{Status: http.StatusUnprocessableEntity, Code: code, Message: message}
+}
+
+// payloadTooLarge reports a request body over the size limit.
+func payloadTooLarge(limit int64) *APIError {
+	return &APIError{Status: http.StatusRequestEntityTooLarge, Code: codePayloadTooLarge,
+		Message: fmt.Sprintf("Request body must be at most %d KiB", limit>>10)}
+}
+
+// rateLimited reports a client that exceeded its budget and when to retry.
+func rateLimited(retryAfter time.Duration) *APIError {
+	return &APIError{Status: http.StatusTooManyRequests, Code: codeRateLimited,
+		Message: "Too many requests, please slow down", RetryAfter: retryAfter}
+}
+
+// PostgreSQL error codes mapped to client errors.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgNotNullViolation    = "23502"
+	pgCheckViolation      = "23514"
+	pgStringTooLong       = "22001"
+	pgInvalidText         = "22P02"
+	pgSerialization       = "40001"
+	pgDeadlock            = "40P01"
+	pgQueryCanceled       = "57014"
+)
+
+// asAPIError maps err to the error the client sees. Errors that aren't
+// APIErrors or known database conditions become an opaque 500.
+func asAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return payloadTooLarge(tooLarge.Limit)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound("Resource")
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return &APIError{Status: http.StatusServiceUnavailable, Code: codeUnavailable, Message: "The request was cancelled or timed out"}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return conflict(codeConflict, "A record with these values already exists", nil)
+		case pgForeignKeyViolation:
+			return conflict(codeConflict, "The request refers to a record that doesn't exist or is still in use", nil)
+		case pgNotNullViolation, pgCheckViolation, pgStringTooLong, pgInvalidText:
+			return invalid("The request contains an invalid value")
+		case pgSerialization, pgDeadlock, pgQueryCanceled:
+			return &APIError{Status: http.StatusServiceUnavailable, Code: codeUnavailable,
+				Message: "The server is busy, please retry", RetryAfter: time.Second}
+		}
+	}
+	return &APIError{Status: http.StatusInternalServerError, Code: codeInternal, Message: "Something went wrong on our side"}
+}
+
+// respondError writes err in the JSON error envelope. Only APIError messages
+// reach the client; anything else is logged with the request ID and replaced
+// by a generic message.
+func respondError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := asAPIError(err)
+	requestID := middleware.GetReqID(r.Context())
+
+	var known *APIError
+	if !errors.As(err, &known) && apiErr.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "Request failed", "method", r.Method, "route", routePattern(r), "error", err)
+	}
+	if apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+	}
+	respondJSON(w, apiErr.Status, errorEnvelope{Error: errorBody{
+		Code:      apiErr.Code,
+		Message:   apiErr.Message,
+		Details:   apiErr.Details,
+		RequestID: requestID,
+	}})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestAsAPIError(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{notFound("Problem"), http.StatusNotFound, codeNotFound},
+		{fmt.Errorf("loading: %w", conflict("duplicate_problem", "dup", nil)), http.StatusConflict, "duplicate_problem"},
+		{sql.ErrNoRows, http.StatusNotFound, codeNotFound},
+		{fmt.Errorf("insert: %w", &pgconn.PgError{Code: pgUniqueViolation, Message: `duplicate key value violates unique constraint "problems_pkey"`}), http.StatusConflict, codeConflict},
+		{&pgconn.PgError{Code: pgForeignKeyViolation}, http.StatusConflict, codeConflict},
+		{&pgconn.PgError{Code: pgInvalidText}, http.StatusBadRequest, codeInvalidRequest},
+		{&pgconn.PgError{Code: pgDeadlock}, http.StatusServiceUnavailable, codeUnavailable},
+		{&pgconn.PgError{Code: "42P01", Message: `relation "problems" does not exist`}, http.StatusInternalServerError, codeInternal},
+		{errors.New("dial tcp 10.0.0.5:5432: connection refused"), http.StatusInternalServerError, codeInternal},
+	}
+	for _, c := range cases {
+		got := asAPIError(c.err)
+		if got.Status != c.status || got.Code != c.code {
+			t.Errorf("%v: got %d %s, want %d %s", c.err, got.Status, got.Code, c.status, c.code)
+		}
+	}
+}
+
+func TestRespondError(t *testing.T) {
+	decode := func(rec *httptest.ResponseRecorder) errorBody {
+		t.Helper()
+		var env errorEnvelope
+		if err := json.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+			t.Fatalf("body is not an error envelope: %s", rec.Body)
+		}
+		return env.Error
+	}
+
+	// Internal messages never reach the client
+	rec := httptest.NewRecorder()
+	respondError(rec, httptest.NewRequest("GET", "/api/problems", nil),
+		&pgconn.PgError{Code: "42703", Message: `column "secret_column" does not exist`})
+	body := decode(rec)
+	if rec.Code != http.StatusInternalServerError || body.Code != codeInternal || strings.Contains(rec.Body.String(), "secret_column") {
+		t.Errorf("internal error leaked: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	respondError(rec, httptest.NewRequest("POST", "/api/problems", nil), invalidField("body.title", "is required"))
+	body = decode(rec)
+	if rec.Code != http.StatusBadRequest || body.Code != codeInvalidRequest || body.Message != "body.title is required" {
+		t.Errorf("unexpected validation error: %d %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	rec = httptest.NewRecorder()
+	respondError(rec, httptest.NewRequest("GET", "/api/problems", nil), rateLimited(1500*time.Millisecond))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Errorf("unexpected rate limit response: %d Retry-After=%q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	rec = httptest.NewRecorder()
+	respondError(rec, httptest.NewRequest("POST", "/api/problems", nil), invalidBody(json.Unmarshal([]byte(`{"title":5}`), &Problem{})))
+	if body := decode(rec); len(rec.Body.String()) == 0 || body.Message != "body.title must be a string" {
+		t.Errorf("unexpected decode error: %s", rec.Body)
+	}
+}
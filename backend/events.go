@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Live updates: handlers publish an event after their change commits, and
+// GET /api/events streams the user's events to each of their open clients as
+// Server-Sent Events so other tabs and devices can refresh. Every instance
+// also forwards its events through Postgres NOTIFY and relays the ones it
+// hears from other instances (and from cron jobs) to its own subscribers.
+
+const (
+	EventProblemCreated  = "problem.created"
+	EventProblemUpdated  = "problem.updated"
+	EventProblemArchived = "problem.archived"
+	EventProblemDeleted  = "problem.deleted"
+	EventRevisitRecorded = "revisit.recorded"
+	EventPlanGenerated   = "plan.generated"
+	EventSettingsChanged = "settings.changed"
+
+	eventsChannel      = "dsa_events"
+	maxNotifyPayload   = 7900 // Postgres rejects NOTIFY payloads of 8000 bytes or more
+	subscriberBuffer   = 32
+	sseHeartbeat       = 25 * time.Second
+	sseRetryMillis     = 5000
+	listenRetryBackoff = 5 * time.Second
+)
+
+// Event is a change to one user's data.
+type Event struct {
+	Type   string                 `json:"type"`
+	UserID uuid.UUID              `json:"-"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+	At     time.Time              `json:"at"`
+}
+
+// eventNotification is the NOTIFY payload that carries an event between instances.
+type eventNotification struct {
+	Origin uuid.UUID `json:"origin"`
+	UserID uuid.UUID `json:"user_id"`
+	Event  Event     `json:"event"`
+}
+
+// Broker fans events out to the subscribers of each user in this process.
+// Publishing never blocks: a subscriber whose buffer is full misses the event.
+type Broker struct {
+	mu     sync.RWMutex
+	subs   map[uuid.UUID]map[chan Event]struct{}
+	origin uuid.UUID
+}
+
+// NewBroker returns an empty broker with a fresh instance ID.
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[uuid.UUID]map[chan Event]struct{}), origin: uuid.New()}
+}
+
+var broker = NewBroker()
+
+// Subscribe registers a channel for a user's events. Call the returned
+// function to unsubscribe.
+func (b *Broker) Subscribe(userID uuid.UUID) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[chan Event]struct{})
+	}
+	b.subs[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs[userID], ch)
+			if len(b.subs[userID]) == 0 {
+				delete(b.subs, userID)
+			}
+			b.mu.Unlock()
+		})
+	}
+}
+
+// deliver sends an event to this process's subscribers of its user.
+func (b *Broker) deliver(ev Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs[ev.UserID] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// notification encodes an event for NOTIFY, dropping its data if the payload
+// would be too large.
+func (b *Broker) notification(ev Event) (string, error) {
+	payload, err := json.Marshal(eventNotification{Origin: b.origin, UserID: ev.UserID, Event: ev})
+	if err != nil {
+		return "", err
+	}
+	if len(payload) > maxNotifyPayload {
+		ev.Data = nil
+		if payload, err = json.Marshal(eventNotification{Origin: b.origin, UserID: ev.UserID, Event: ev}); err != nil {
+			return "", err
+		}
+	}
+	return string(payload), nil
+}
+
+// handleNotification relays an event published by another instance. Our
+// own notifications are ignored since they were delivered when published.
+func (b *Broker) handleNotification(payload string) {
+	var n eventNotification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		slog.Warn("Ignoring malformed event notification", "error", err)
+		return
+	}
+	if n.Origin == b.origin {
+		return
+	}
+	n.Event.UserID = n.UserID
+	b.deliver(n.Event)
+}
+
+// publishEvent delivers an event to the user's connected clients on every
+// instance. Failing to notify other instances is logged, never returned: the
+// change it describes has already been committed.
+func publishEvent(userID uuid.UUID, eventType string, data map[string]interface{}) {
+	ev := Event{Type: eventType, UserID: userID, Data: data, At: time.Now().UTC()}
+	broker.deliver(ev)
+
+	if db == nil {
+		return
+	}
+	payload, err := broker.notification(ev)
+	if err == nil {
+		_, err = db.Exec(`SELECT pg_notify($1, $2)`, eventsChannel, payload)
+	}
+	if err != nil {
+		slog.Error("Failed to notify event", "event", eventType, "user_id", userID, "error", err)
+	}
+}
+
+// publishProblemEvent publishes an event about a single problem.
+func publishProblemEvent(userID uuid.UUID, eventType string, problemID uuid.UUID) {
+	publishEvent(userID, eventType, map[string]interface{}{"problem_id": problemID})
+}
+
+// publishSettingsChanged publishes a settings.changed event naming what changed.
+func publishSettingsChanged(userID uuid.UUID, setting string) {
+	publishEvent(userID, EventSettingsChanged, map[string]interface{}{"setting": setting})
+}
+
+// ListenForEvents relays notifications from other instances until ctx is
+// cancelled, reconnecting after errors. It holds one dedicated connection.
+func ListenForEvents(ctx context.Context) {
+	for {
+		err := listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("Event listener stopped, reconnecting", "error", err, "backoff", listenRetryBackoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryBackoff):
+		}
+	}
+}
+
+func listenOnce(ctx context.Context) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pgConn := driverConn.(*stdlib.Conn).Conn()
+		if _, err := pgConn.Exec(ctx, "LISTEN "+eventsChannel); err != nil {
+			return err
+		}
+		slog.Info("Listening for events", "channel", eventsChannel)
+		for {
+			n, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				// Never hand a LISTENing connection back to the pool
+				slog.Warn("Waiting for event notifications failed", "error", err)
+				return driver.ErrBadConn
+			}
+			broker.handleNotification(n.Payload)
+		}
+	})
+}
+
+// writeSSE writes one event as a Server-Sent Events frame.
+func writeSSE(w io.Writer, ev Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+	return err
+}
+
+// StreamEvents streams the user's events as text/event-stream until the
+// client disconnects. Comment lines keep idle proxies from closing it.
+func StreamEvents(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, r, errors.New("response writer does not support streaming"))
+		return
+	}
+
+	events, unsubscribe := broker.Subscribe(userID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n: connected\n\n", sseRetryMillis)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-events:
+			if err := writeSSE(w, ev); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func receive(t *testing.T, ch <-chan Event) (Event, bool) {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev, true
+	case <-time.After(50 * time.Millisecond):
+		return Event{}, false
+	}
+}
+
+func TestBrokerDeliversPerUser(t *testing.T) {
+	b := NewBroker()
+	alice, bob := uuid.New(), uuid.New()
+
+	tab1, unsub1 := b.Subscribe(alice)
+	tab2, unsub2 := b.Subscribe(alice)
+	other, unsubOther := b.Subscribe(bob)
+	defer unsub2()
+	defer unsubOther()
+
+	b.deliver(Event{Type: EventProblemCreated, UserID: alice})
+	for i, ch := range []<-chan Event{tab1, tab2} {
+		if ev, ok := receive(t, ch); !ok || ev.Type != EventProblemCreated {
+			t.Errorf("subscriber %d did not receive the event", i)
+		}
+	}
+	if _, ok := receive(t, other); ok {
+		t.Error("another user's subscriber received the event")
+	}
+
+	unsub1()
+	unsub1() // safe to call twice
+	b.deliver(Event{Type: EventProblemDeleted, UserID: alice})
+	if _, ok := receive(t, tab1); ok {
+		t.Error("unsubscribed channel received an event")
+	}
+	if _, ok := receive(t, tab2); !ok {
+		t.Error("remaining subscriber missed the event")
+	}
+}
+
+func TestBrokerDoesNotBlockOnSlowSubscriber(t *testing.T) {
+	b := NewBroker()
+	user := uuid.New()
+	_, unsub := b.Subscribe(user)
+	defer unsub()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBuffer*2; i++ {
+			b.deliver(Event{Type: EventProblemUpdated, UserID: user})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("deliver blocked on a full subscriber")
+	}
+}
+
+func TestBrokerNotifications(t *testing.T) {
+	sender, receiver := NewBroker(), NewBroker()
+	user := uuid.New()
+	problemID := uuid.New()
+
+	payload, err := sender.notification(Event{Type: EventProblemUpdated, UserID: user, Data: map[string]interface{}{"problem_id": problemID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch, unsub := receiver.Subscribe(user)
+	defer unsub()
+	receiver.handleNotification(payload)
+	ev, ok := receive(t, ch)
+	if !ok || ev.Type != EventProblemUpdated || ev.UserID != user || ev.Data["problem_id"] != problemID.String() {
+		t.Errorf("unexpected relayed event %+v", ev)
+	}
+
+	// An instance ignores its own notifications
+	own, unsubOwn := sender.Subscribe(user)
+	defer unsubOwn()
+	sender.handleNotification(payload)
+	if _, ok := receive(t, own); ok {
+		t.Error("instance relayed its own notification")
+	}
+
+	// Oversized data is dropped to stay under the NOTIFY limit
+	big, err := sender.notification(Event{Type: EventProblemUpdated, UserID: user, Data: map[string]interface{}{"x": strings.Repeat("a", 9000)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(big) > maxNotifyPayload || strings.Contains(big, `"data"`) {
+		t.Errorf("oversized payload not trimmed (%d bytes)", len(big))
+	}
+}
+
+func TestWriteSSE(t *testing.T) {
+	var b strings.Builder
+	at := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	if err := writeSSE(&b, Event{Type: EventPlanGenerated, UserID: uuid.New(), At: at}); err != nil {
+		t.Fatal(err)
+	}
+
+	frame := b.String()
+	if !strings.HasPrefix(frame, "event: plan.generated\ndata: ") || !strings.HasSuffix(frame, "\n\n") {
+		t.Fatalf("unexpected frame %q", frame)
+	}
+	var decoded map[string]interface{}
+	data := strings.TrimSuffix(strings.TrimPrefix(frame, "event: plan.generated\ndata: "), "\n\n")
+	if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if _, leaked := decoded["user_id"]; leaked {
+		t.Error("user ID should not be sent to the client")
+	}
+	if decoded["at"] != "2026-05-01T09:00:00Z" {
+		t.Errorf("unexpected data %v", decoded)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Feeds are read by calendar apps and feed readers that can't sign in, so
+// each one is reached through a secret token in its URL. Only a SHA-256 of
+// the token is stored: the URL is shown once when the token is created, and
+// rotating or revoking the token breaks the old URL.
+
+const (
+	feedKindCalendar = "calendar"
+	feedKindFocus    = "focus"
+	feedKindJournal  = "journal"
+)
+
+// feedPaths maps each feed kind to its public URL path (token substituted).
+var feedPaths = map[string]string{
+	feedKindCalendar: "/api/feeds/calendar/%s.ics",
+	feedKindFocus:    "/api/feeds/focus/%s.atom",
+	feedKindJournal:  "/api/feeds/journal/%s.atom",
+}
+
+// FeedToken describes a user's feed token without revealing it.
+type FeedToken struct {
+	Kind       string    `json:"kind"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt NullTime  `json:"last_used_at"`
+}
+
+// hashFeedToken returns the stored form of a feed token.
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newFeedToken returns a random URL-safe token.
+func newFeedToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// requestBaseURL is the scheme and host the client used to reach the API,
+// honoring the proxy's X-Forwarded-Proto.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	}
+	return scheme + "://" + r.Host
+}
+
+// resolveFeedToken returns the user a feed token belongs to and records the
+// access. ok is false for unknown or revoked tokens.
+func resolveFeedToken(kind, token string) (uuid.UUID, bool, error) {
+	var userID uuid.UUID
+	err := db.QueryRow(`
+		UPDATE feed_tokens SET last_used_at = NOW()
+		WHERE kind = $1 AND token_hash = $2
+		RETURNING user_id`, kind, hashFeedToken(token)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, false, nil
+	}
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	return userID, true, nil
+}
+
+// GetFeedTokens lists the user's active feed tokens.
+func GetFeedTokens(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	rows, err := db.Query(`
+		SELECT kind, created_at, last_used_at FROM feed_tokens
+		WHERE user_id = $1 ORDER BY kind`, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	tokens := []FeedToken{}
+	for rows.Next() {
+		var t FeedToken
+		if err := rows.Scan(&t.Kind, &t.CreatedAt, &t.LastUsedAt); err != nil {
+			respondError(w, r, err)
+			return
+		}
+		tokens = append(tokens, t)
+	}
+	respondJSONConditional(w, r, tokens)
+}
+
+// RotateFeedToken creates the user's token for a feed kind, replacing (and
+// so revoking) any existing one. The response carries the only copy of the
+// feed URL.
+func RotateFeedToken(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	kind := chi.URLParam(r, "kind")
+	path, ok := feedPaths[kind]
+	if !ok {
+		respondError(w, r, notFound("Feed"))
+		return
+	}
+
+	token, err := newFeedToken()
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var t FeedToken
+	err = db.QueryRow(`
+		INSERT INTO feed_tokens (user_id, kind, token_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, kind)
+		DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = NOW(), last_used_at = NULL
+		RETURNING kind, created_at, last_used_at`, userID, kind, hashFeedToken(token)).Scan(
+		&t.Kind, &t.CreatedAt, &t.LastUsedAt)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	publishSettingsChanged(userID, "feeds")
+	respondJSON(w, http.StatusCreated, struct {
+		FeedToken
+		URL string `json:"url"`
+	}{t, requestBaseURL(r) + fmt.Sprintf(path, token)})
+}
+
+// RevokeFeedToken deletes the user's token for a feed kind.
+func RevokeFeedToken(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	kind := chi.URLParam(r, "kind")
+	if _, ok := feedPaths[kind]; !ok {
+		respondError(w, r, notFound("Feed"))
+		return
+	}
+
+	result, err := db.Exec(`DELETE FROM feed_tokens WHERE user_id = $1 AND kind = $2`, userID, kind)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		respondError(w, r, &APIError{Status: http.StatusNotFound, Code: codeNotFound, Message: "Feed is not enabled"})
+		return
+	}
+	publishSettingsChanged(userID, "feeds")
+	respondJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
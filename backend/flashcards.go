@@ -0,0 +1,526 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Flashcards hold the one-line insights worth drilling on their own
+// ("sort + two pointers"). Each belongs to a problem and is either written
+// by hand or extracted from the problem's notes:
+//
+//	Q: How do you find a cycle start in a linked list?
+//	A: Floyd: after slow/fast meet, restart one from head; they meet at the start.
+//
+// Cards are scheduled with the problem scheduler (weights and eligibility),
+// using an interval that doubles with each successful review.
+
+const (
+	flashcardSourceManual = "manual"
+	flashcardSourceNotes  = "notes"
+
+	defaultDrillSize         = 10
+	maxDrillSize             = 50
+	maxFlashcardIntervalDays = 30
+)
+
+// Flashcard is a prompt/answer pair attached to a problem.
+type Flashcard struct {
+	ID             uuid.UUID `json:"id"`
+	ProblemID      uuid.UUID `json:"problem_id"`
+	ProblemTitle   string    `json:"problem_title"`
+	Prompt         string    `json:"prompt"`
+	Answer         string    `json:"answer"`
+	Source         string    `json:"source"` // manual or notes
+	CreatedAt      time.Time `json:"created_at"`
+	LastReviewedAt NullTime  `json:"last_reviewed_at"`
+	TimesReviewed  int       `json:"times_reviewed"` // successful reviews in a row
+	Lapses         int       `json:"lapses"`
+}
+
+// CardText is a prompt/answer pair before it is stored.
+type CardText struct {
+	Prompt string `json:"prompt"`
+	Answer string `json:"answer"`
+}
+
+// flashcardLineRe matches a "Q:" or "A:" line, allowing a list bullet and
+// markdown bold around the marker ("- **Q:** ...").
+var flashcardLineRe = regexp.MustCompile(`(?i)^(?:[-*+]\s+)?(?:\*\*)?([QA]):(?:\*\*)?\s*(.*)$`)
+
+// ExtractFlashcards parses Q:/A: cards from markdown notes. A card's prompt
+// and answer may continue over several lines; a blank line or the next Q:
+// ends it. Cards without an answer are skipped, as are repeated prompts.
+func ExtractFlashcards(notes string) []CardText {
+	var cards []CardText
+	seen := make(map[string]bool)
+	var prompt, answer []string
+	inAnswer := false
+
+	flush := func() {
+		p := strings.TrimSpace(strings.Join(prompt, "\n"))
+		a := strings.TrimSpace(strings.Join(answer, "\n"))
+		if p != "" && a != "" && !seen[p] {
+			seen[p] = true
+			cards = append(cards, CardText{Prompt: p, Answer: a})
+		}
+		prompt, answer, inAnswer = nil, nil, false
+	}
+
+	for _, raw := range strings.Split(strings.ReplaceAll(notes, "\r\n", "\n"), "\n") {
+		line := strings.TrimSpace(raw)
+		if m := flashcardLineRe.FindStringSubmatch(line); m != nil {
+			if strings.EqualFold(m[1], "Q") {
+				flush()
+				prompt = []string{m[2]}
+				continue
+			}
+			if prompt != nil && !inAnswer {
+				inAnswer = true
+				answer = []string{m[2]}
+				continue
+			}
+		}
+		switch {
+		case line == "":
+			if inAnswer || prompt != nil {
+				flush()
+			}
+		case inAnswer:
+			answer = append(answer, line)
+		case prompt != nil:
+			prompt = append(prompt, line)
+		}
+	}
+	flush()
+	return cards
+}
+
+// FlashcardInterval is the minimum days between reviews of a card that has
+// been recalled timesReviewed times in a row: 1, 2, 4, 8, ... capped at 30.
+func FlashcardInterval(timesReviewed int) int {
+	if timesReviewed <= 0 {
+		return 1
+	}
+	if timesReviewed >= 5 {
+		return maxFlashcardIntervalDays
+	}
+	return minInt(1<<timesReviewed, maxFlashcardIntervalDays)
+}
+
+// schedulingView maps a card onto the fields the problem scheduler uses.
+func (c Flashcard) schedulingView() Problem {
+	return Problem{
+		ID:                 c.ID,
+		DateAdded:          c.CreatedAt,
+		LastRevisitedAt:    c.LastReviewedAt,
+		TimesRevisited:     c.TimesReviewed,
+		PriorityMultiplier: 1,
+	}
+}
+
+// DueFlashcards returns the cards whose review interval has passed, highest
+// scheduling weight first.
+func DueFlashcards(cards []Flashcard, now time.Time) []Flashcard {
+	due := []Flashcard{}
+	for _, c := range cards {
+		if ok, _ := CheckEligibility(c.schedulingView(), FlashcardInterval(c.TimesReviewed), now); ok {
+			due = append(due, c)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return CalculateWeightAt(due[i].schedulingView(), now) > CalculateWeightAt(due[j].schedulingView(), now)
+	})
+	return due
+}
+
+// SelectFlashcardDrill picks up to n due cards by weighted sampling, the
+// same way the daily focus picks problems.
+func SelectFlashcardDrill(cards []Flashcard, n int, seed int64, now time.Time) []Flashcard {
+	due := DueFlashcards(cards, now)
+	byID := make(map[uuid.UUID]Flashcard, len(due))
+	views := make([]Problem, len(due))
+	for i, c := range due {
+		byID[c.ID] = c
+		views[i] = c.schedulingView()
+	}
+
+	drill := []Flashcard{}
+	for _, p := range SelectProblemsAt(views, n, seed, now) {
+		drill = append(drill, byID[p.ID])
+	}
+	return drill
+}
+
+const flashcardColumns = `f.id, f.problem_id, p.title, f.prompt, f.answer, f.source, f.created_at,
+	f.last_reviewed_at, f.times_reviewed, f.lapses`
+
+func scanFlashcard(row rowScanner, c *Flashcard) error {
+	return row.Scan(&c.ID, &c.ProblemID, &c.ProblemTitle, &c.Prompt, &c.Answer, &c.Source, &c.CreatedAt,
+		&c.LastReviewedAt, &c.TimesReviewed, &c.Lapses)
+}
+
+// queryFlashcards loads the user's cards on non-trashed problems matching
+// the extra condition (which may use $2 onwards).
+func queryFlashcards(userID uuid.UUID, cond string, args ...interface{}) ([]Flashcard, error) {
+	rows, err := db.Query(`
+		SELECT `+flashcardColumns+`
+		FROM flashcards f
+		JOIN problems p ON p.id = f.problem_id
+		WHERE p.user_id = $1 AND p.status <> 'trashed' AND `+cond+`
+		ORDER BY f.created_at, f.id`, append([]interface{}{userID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cards := []Flashcard{}
+	for rows.Next() {
+		var c Flashcard
+		if err := scanFlashcard(rows, &c); err != nil {
+			return nil, err
+		}
+		cards = append(cards, c)
+	}
+	return cards, rows.Err()
+}
+
+// syncNoteFlashcards makes the problem's notes-sourced cards match its notes.
+// Cards whose prompt is unchanged keep their review history.
+func syncNoteFlashcards(q querier, problemID uuid.UUID, notes string) error {
+	cards := ExtractFlashcards(notes)
+	if cards == nil {
+		cards = []CardText{}
+	}
+	cardsJSON, _ := json.Marshal(cards)
+
+	_, err := q.Exec(`
+		DELETE FROM flashcards
+		WHERE problem_id = $1 AND source = 'notes'
+		  AND prompt NOT IN (SELECT c->>'prompt' FROM jsonb_array_elements($2::jsonb) c)`,
+		problemID, string(cardsJSON))
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(`
+		INSERT INTO flashcards (problem_id, prompt, answer, source)
+		SELECT $1, c.prompt, c.answer, 'notes'
+		FROM jsonb_to_recordset($2::jsonb) AS c(prompt TEXT, answer TEXT)
+		ON CONFLICT (problem_id, prompt) WHERE source = 'notes'
+		DO UPDATE SET answer = EXCLUDED.answer`,
+		problemID, string(cardsJSON))
+	return err
+}
+
+// syncNoteFlashcardsAfterWrite runs syncNoteFlashcards once a problem's notes
+// have been saved. The problem write already succeeded, so failures are logged.
+func syncNoteFlashcardsAfterWrite(problemID uuid.UUID, notes string) {
+	if err := syncNoteFlashcards(db, problemID, notes); err != nil {
+		slog.Error("Error syncing flashcards", "problem_id", problemID, "error", err)
+	}
+}
+
+// backfillNoteFlashcards extracts cards from notes written before flashcards
+// existed. Problems that already have notes cards are skipped.
+func backfillNoteFlashcards() error {
+	rows, err := db.Query(`
+		SELECT id, notes FROM problems p
+		WHERE notes ~* '(^|\n)\s*([-*+]\s+)?(\*\*)?q:'
+		  AND NOT EXISTS (SELECT 1 FROM flashcards f WHERE f.problem_id = p.id AND f.source = 'notes')`)
+	if err != nil {
+		return err
+	}
+	type pending struct {
+		id    uuid.UUID
+		notes string
+	}
+	var todo []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.notes); err != nil {
+			rows.Close()
+			return err
+		}
+		todo = append(todo, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range todo {
+		if err := syncNoteFlashcards(db, p.id, p.notes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseCardText decodes and validates a manual card body.
+func parseCardText(r *http.Request) (CardText, error) {
+	var body CardText
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return body, invalidBody(err)
+	}
+	body.Prompt = strings.TrimSpace(body.Prompt)
+	body.Answer = strings.TrimSpace(body.Answer)
+	var fields []FieldError
+	if body.Prompt == "" {
+		fields = append(fields, FieldError{Field: "body.prompt", Message: "is required"})
+	} else if len(body.Prompt) > 1000 {
+		fields = append(fields, FieldError{Field: "body.prompt", Message: "must be at most 1000 characters"})
+	}
+	if body.Answer == "" {
+		fields = append(fields, FieldError{Field: "body.answer", Message: "is required"})
+	} else if len(body.Answer) > 4000 {
+		fields = append(fields, FieldError{Field: "body.answer", Message: "must be at most 4000 characters"})
+	}
+	if len(fields) > 0 {
+		return body, invalid("Invalid flashcard text", fields...)
+	}
+	return body, nil
+}
+
+// GetProblemFlashcards lists a problem's flashcards.
+func GetProblemFlashcards(w http.ResponseWriter, r *http.Request) {
+	problemID, ok := ownedProblemID(w, r)
+	if !ok {
+		return
+	}
+
+	cards, err := queryFlashcards(GetUserIDFromContext(r), "f.problem_id = $2", problemID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSONConditional(w, r, cards)
+}
+
+// CreateFlashcard adds a manual flashcard to a problem. Body: prompt, answer.
+func CreateFlashcard(w http.ResponseWriter, r *http.Request) {
+	problemID, ok := ownedProblemID(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := parseCardText(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var c Flashcard
+	err = scanFlashcard(db.QueryRow(`
+		WITH f AS (
+			INSERT INTO flashcards (problem_id, prompt, answer, source)
+			VALUES ($1, $2, $3, 'manual')
+			RETURNING *
+		)
+		SELECT `+flashcardColumns+` FROM f JOIN problems p ON p.id = f.problem_id`,
+		problemID, body.Prompt, body.Answer), &c)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, c)
+}
+
+// loadOwnedFlashcard parses the {id} URL param and loads the user's card,
+// writing the error response if it can't.
+func loadOwnedFlashcard(w http.ResponseWriter, r *http.Request) (Flashcard, bool) {
+	var c Flashcard
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return c, false
+	}
+	cards, err := queryFlashcards(GetUserIDFromContext(r), "f.id = $2", id)
+	if err != nil {
+		respondError(w, r, err)
+		return c, false
+	}
+	if len(cards) == 0 {
+		respondError(w, r, notFound("Flashcard"))
+		return c, false
+	}
+	return cards[0], true
+}
+
+// respondNotesCard rejects edits to a card that comes from the problem's
+// notes; those are changed by editing the notes.
+func respondNotesCard(w http.ResponseWriter, r *http.Request) {
+	respondError(w, r, conflict("notes_flashcard", "This card comes from the problem's notes. Edit the notes to change or remove it.", nil))
+}
+
+// UpdateFlashcard edits a manual flashcard. Body: prompt, answer.
+func UpdateFlashcard(w http.ResponseWriter, r *http.Request) {
+	c, ok := loadOwnedFlashcard(w, r)
+	if !ok {
+		return
+	}
+	if c.Source == flashcardSourceNotes {
+		respondNotesCard(w, r)
+		return
+	}
+
+	body, err := parseCardText(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if _, err := db.Exec(`UPDATE flashcards SET prompt = $1, answer = $2 WHERE id = $3`,
+		body.Prompt, body.Answer, c.ID); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	c.Prompt, c.Answer = body.Prompt, body.Answer
+	respondJSON(w, http.StatusOK, c)
+}
+
+// DeleteFlashcard removes a manual flashcard.
+func DeleteFlashcard(w http.ResponseWriter, r *http.Request) {
+	c, ok := loadOwnedFlashcard(w, r)
+	if !ok {
+		return
+	}
+	if c.Source == flashcardSourceNotes {
+		respondNotesCard(w, r)
+		return
+	}
+
+	if _, err := db.Exec(`DELETE FROM flashcards WHERE id = $1`, c.ID); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// ReviewFlashcard records a drill answer. Body: remembered (bool).
+// Remembering a card doubles its interval; forgetting it starts over at one day.
+func ReviewFlashcard(w http.ResponseWriter, r *http.Request) {
+	c, ok := loadOwnedFlashcard(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Remembered *bool `json:"remembered"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Remembered == nil {
+		respondError(w, r, invalidField("body.remembered", "is required (true or false)"))
+		return
+	}
+
+	if c.LastReviewedAt.Valid && sameDay(c.LastReviewedAt.Time, time.Now()) {
+		respondError(w, r, conflict("already_reviewed_today", "This card has already been reviewed today.", nil))
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO flashcard_reviews (flashcard_id, remembered) VALUES ($1, $2)`, c.ID, *body.Remembered)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	err = tx.QueryRow(`
+		UPDATE flashcards
+		SET last_reviewed_at = NOW(),
+		    times_reviewed = CASE WHEN $2 THEN times_reviewed + 1 ELSE 0 END,
+		    lapses = lapses + CASE WHEN $2 THEN 0 ELSE 1 END
+		WHERE id = $1
+		RETURNING last_reviewed_at, times_reviewed, lapses`, c.ID, *body.Remembered).Scan(
+		&c.LastReviewedAt, &c.TimesReviewed, &c.Lapses)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"flashcard":     c,
+		"next_due_days": FlashcardInterval(c.TimesReviewed),
+	})
+}
+
+// GetFlashcardQueue lists every card due for review, highest weight first.
+func GetFlashcardQueue(w http.ResponseWriter, r *http.Request) {
+	cards, err := queryFlashcards(GetUserIDFromContext(r), "TRUE")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	due := DueFlashcards(cards, time.Now())
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"due_count":  len(due),
+		"total":      len(cards),
+		"flashcards": due,
+	})
+}
+
+// GetFlashcardDrill returns today's quick drill: up to ?limit= (default 10)
+// due cards, sampled by weight with a per-day seed. Reviewed cards drop out
+// of the drill until they are due again.
+func GetFlashcardDrill(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	limit := defaultDrillSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDrillSize {
+			respondError(w, r, invalidField("query.limit", "must be between 1 and 50"))
+			return
+		}
+		limit = n
+	}
+
+	cards, err := queryFlashcards(userID, "TRUE")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var reviewedToday int
+	err = db.QueryRow(`
+		SELECT COUNT(*) FROM flashcard_reviews fr
+		JOIN flashcards f ON f.id = fr.flashcard_id
+		JOIN problems p ON p.id = f.problem_id
+		WHERE p.user_id = $1 AND p.status <> 'trashed' AND fr.reviewed_at::date = CURRENT_DATE`, userID).Scan(&reviewedToday)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	drill := SelectFlashcardDrill(cards, limit, DaySeed(), now)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"date":           now.Format("2006-01-02"),
+		"reviewed_today": reviewedToday,
+		"flashcards":     drill,
+	})
+}
+
+// sameDay reports whether a and b fall on the same local calendar day.
+func sameDay(a, b time.Time) bool {
+	a, b = a.Local(), b.Local()
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
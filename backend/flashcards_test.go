@@ -0,0 +1,87 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestExtractFlashcards(t *testing.T) {
+	notes := `Brute force is O(n^2).
+
+Q: Key insight?
+A: Sort, then two pointers from both ends.
+
+- **Q:** Why does moving the smaller side work?
+  It's the limiting height.
+- **A:** The area can only grow
+  by replacing the shorter line.
+Q: No answer here
+
+q: Duplicate?
+a: first
+Q: Duplicate?
+A: second
+A: stray answer`
+
+	want := []CardText{
+		{Prompt: "Key insight?", Answer: "Sort, then two pointers from both ends."},
+		{Prompt: "Why does moving the smaller side work?\nIt's the limiting height.", Answer: "The area can only grow\nby replacing the shorter line."},
+		{Prompt: "Duplicate?", Answer: "first"},
+	}
+	got := ExtractFlashcards(notes)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractFlashcards =\n%#v\nwant\n%#v", got, want)
+	}
+
+	if got := ExtractFlashcards("plain notes, no cards"); len(got) != 0 {
+		t.Errorf("expected no cards, got %v", got)
+	}
+}
+
+func TestFlashcardInterval(t *testing.T) {
+	for times, want := range map[int]int{0: 1, 1: 2, 2: 4, 3: 8, 4: 16, 5: 30, 50: 30} {
+		if got := FlashcardInterval(times); got != want {
+			t.Errorf("FlashcardInterval(%d) = %d, want %d", times, got, want)
+		}
+	}
+}
+
+func TestDueAndDrillFlashcards(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	reviewed := func(daysAgo int) NullTime {
+		return NullTime{sql.NullTime{Time: now.AddDate(0, 0, -daysAgo), Valid: true}}
+	}
+	card := func(lastReviewed NullTime, times int) Flashcard {
+		return Flashcard{ID: uuid.New(), CreatedAt: now.AddDate(0, 0, -60), LastReviewedAt: lastReviewed, TimesReviewed: times}
+	}
+
+	fresh := card(NullTime{}, 0)       // never reviewed: due
+	dueAgain := card(reviewed(4), 2)   // interval 4: due
+	notYet := card(reviewed(3), 2)     // interval 4: not due
+	forgotten := card(reviewed(1), 0)  // lapsed yesterday: due
+	longTerm := card(reviewed(20), 10) // interval 30: not due
+
+	due := DueFlashcards([]Flashcard{fresh, dueAgain, notYet, forgotten, longTerm}, now)
+	if len(due) != 3 {
+		t.Fatalf("expected 3 due cards, got %d", len(due))
+	}
+	for _, c := range due {
+		if c.ID == notYet.ID || c.ID == longTerm.ID {
+			t.Errorf("card %v should not be due", c.ID)
+		}
+	}
+
+	cards := []Flashcard{fresh, dueAgain, notYet, forgotten, longTerm}
+	drill := SelectFlashcardDrill(cards, 2, 42, now)
+	if len(drill) != 2 {
+		t.Fatalf("expected a drill of 2, got %d", len(drill))
+	}
+	again := SelectFlashcardDrill(cards, 2, 42, now)
+	if !reflect.DeepEqual(drill, again) {
+		t.Error("drill should be deterministic for a given seed")
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ForecastDay is the projected scheduler state for one simulated day.
+type ForecastDay struct {
+	Date          string  `json:"date"`
+	EligibleCount int     `json:"eligible_count"` // problems past min_revisit_days at the start of the day
+	Selected      int     `json:"selected"`       // problems in that day's focus
+	Backlog       int     `json:"backlog"`        // eligible problems left unselected
+	OverdueCount  int     `json:"overdue_count"`  // problems whose gap exceeds max_revisit_days at the end of the day
+	MaxGapDays    float64 `json:"max_gap_days"`   // longest time any problem has gone without a revisit at the end of the day
+
+	SelectedIDs []uuid.UUID `json:"-"` // the day's projected focus, in selection order
+}
+
+// Forecast is the result of simulating the scheduler forward.
+type Forecast struct {
+	ProblemsPerDay            int           `json:"problems_per_day"`
+	MinRevisitDays            int           `json:"min_revisit_days"`
+	MaxRevisitDays            int           `json:"max_revisit_days"`
+	ActiveProblems            int           `json:"active_problems"`
+	ProjectedMaxGapDays       float64       `json:"projected_max_gap_days"`
+	OnTrack                   bool          `json:"on_track"`
+	RecommendedProblemsPerDay int           `json:"recommended_problems_per_day"`
+	RecommendationFeasible    bool          `json:"recommendation_feasible"` // false if even the recommendation exceeds max_revisit_days
+	Days                      []ForecastDay `json:"days"`
+}
+
+// SimulateForecast runs the scheduler forward for `days` days starting the day
+// after `start`, assuming every focus item is completed on the day it is picked.
+// The input problems are not modified.
+func SimulateForecast(problems []Problem, prefs UserPreferences, start time.Time, days int) []ForecastDay {
+	state := make([]Problem, len(problems))
+	copy(state, problems)
+
+	index := make(map[uuid.UUID]int, len(state))
+	for i, p := range state {
+		index[p.ID] = i
+	}
+
+	result := make([]ForecastDay, 0, days)
+	for d := 1; d <= days; d++ {
+		now := start.AddDate(0, 0, d)
+
+		day := ForecastDay{Date: now.Format("2006-01-02")}
+		eligible := FilterEligible(state, prefs.MinRevisitDays, now)
+		selected := SelectFocus(eligible, prefs.ProblemsPerDay, DaySeedFor(now), now)
+
+		day.EligibleCount = len(eligible)
+		day.Selected = len(selected)
+		for _, p := range selected {
+			day.SelectedIDs = append(day.SelectedIDs, p.ID)
+		}
+		day.Backlog = len(eligible) - len(selected)
+
+		// Complete the day's focus (revisiting a pinned problem unpins it)
+		for _, p := range selected {
+			i := index[p.ID]
+			state[i].Pinned = false
+			state[i].TimesRevisited++
+			state[i].LastRevisitedAt = NullTime{sql.NullTime{Time: now, Valid: true}}
+		}
+
+		// Gaps are measured after the day's revisits, so a budget that clears
+		// an existing backlog on day one is not penalized for it
+		for _, p := range state {
+			gap := daysWithoutRevisit(p, now)
+			if gap > day.MaxGapDays {
+				day.MaxGapDays = gap
+			}
+			if gap > float64(prefs.MaxRevisitDays) {
+				day.OverdueCount++
+			}
+		}
+
+		result = append(result, day)
+	}
+
+	return result
+}
+
+// daysWithoutRevisit returns how long a problem has gone without a revisit at `now`,
+// counting from the date it was added if it was never revisited.
+func daysWithoutRevisit(p Problem, now time.Time) float64 {
+	since := p.DateAdded
+	if p.LastRevisitedAt.Valid {
+		since = p.LastRevisitedAt.Time
+	}
+	return now.Sub(since).Hours() / 24
+}
+
+// maxGap returns the largest MaxGapDays across a forecast.
+func maxGap(days []ForecastDay) float64 {
+	max := 0.0
+	for _, d := range days {
+		if d.MaxGapDays > max {
+			max = d.MaxGapDays
+		}
+	}
+	return max
+}
+
+// RecommendProblemsPerDay finds the smallest daily budget that keeps every
+// problem's gap at or under prefs.MaxRevisitDays over the simulated horizon.
+// The search is capped at 100 problems per day; if no budget up to the cap is
+// enough, it returns the cap and false.
+func RecommendProblemsPerDay(problems []Problem, prefs UserPreferences, start time.Time, days int) (int, bool) {
+	if len(problems) == 0 {
+		return 0, true
+	}
+
+	lo, hi := 1, len(problems)
+	if hi > 100 {
+		hi = 100
+	}
+	prefs.ProblemsPerDay = hi
+	if maxGap(SimulateForecast(problems, prefs, start, days)) > float64(prefs.MaxRevisitDays) {
+		return hi, false
+	}
+	for lo < hi {
+		mid := (lo + hi) / 2
+		prefs.ProblemsPerDay = mid
+		forecast := SimulateForecast(problems, prefs, start, days)
+		if maxGap(forecast) <= float64(prefs.MaxRevisitDays) {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo, true
+}
+
+// loadForecastProblems returns the active problems in the user's focus scope
+// as the forecast should see them: the rest of today's plan is assumed done
+// by `now`. Today's plan is returned too. It only reads the plan; a user
+// without one yet has nothing pending today.
+func loadForecastProblems(userID uuid.UUID, prefs UserPreferences, now time.Time) ([]Problem, []PlanItem, error) {
+	rows, err := db.Query(`
+		SELECT `+problemColumns+`
+		FROM problems
+		WHERE user_id = $1 AND status = 'active' AND `+focusScopeClause("id", "$2"), userID, prefs.FocusCollectionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var problems []Problem
+	for rows.Next() {
+		var p Problem
+		if err := scanProblem(rows, &p); err != nil {
+			return nil, nil, err
+		}
+		problems = append(problems, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	plan, err := LoadDailyPlan(userID, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	pending := make(map[uuid.UUID]bool)
+	for _, item := range plan {
+		if !item.RevisitedToday {
+			pending[item.Problem.ID] = true
+		}
+	}
+	for i := range problems {
+		if pending[problems[i].ID] {
+			problems[i].TimesRevisited++
+			problems[i].LastRevisitedAt = NullTime{sql.NullTime{Time: now, Valid: true}}
+		}
+	}
+	return problems, plan, nil
+}
+
+// GetForecast projects the user's workload for the next N days.
+// Accepts ?days=N (default 14, max 90) and an optional ?problems_per_day=N
+// to try a different budget than the saved preference.
+func GetForecast(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	days := 14
+	if d := r.URL.Query().Get("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 || n > 90 {
+			respondError(w, r, invalidField("query.days", "must be between 1 and 90"))
+			return
+		}
+		days = n
+	}
+
+	var prefs UserPreferences
+	if err := db.QueryRow("SELECT preferences FROM users WHERE id = $1", userID).Scan(&prefs); err != nil {
+		slog.WarnContext(r.Context(), "Error fetching preferences, using defaults", "error", err)
+		prefs = UserPreferences{ProblemsPerDay: 3, MinRevisitDays: 2, MaxRevisitDays: 10}
+	}
+
+	budget := 0
+	if v := r.URL.Query().Get("problems_per_day"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			respondError(w, r, invalidField("query.problems_per_day", "must be between 1 and 100"))
+			return
+		}
+		budget = n
+	}
+
+	now := time.Now()
+	problems, _, err := loadForecastProblems(userID, prefs, now)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	// The what-if budget only applies to the simulation, never to a plan
+	if budget > 0 {
+		prefs.ProblemsPerDay = budget
+	}
+
+	forecastDays := SimulateForecast(problems, prefs, now, days)
+
+	projectedMax := maxGap(forecastDays)
+	recommended, feasible := RecommendProblemsPerDay(problems, prefs, now, days)
+	forecast := Forecast{
+		ProblemsPerDay:            prefs.ProblemsPerDay,
+		MinRevisitDays:            prefs.MinRevisitDays,
+		MaxRevisitDays:            prefs.MaxRevisitDays,
+		ActiveProblems:            len(problems),
+		ProjectedMaxGapDays:       math.Round(projectedMax*10) / 10,
+		OnTrack:                   projectedMax <= float64(prefs.MaxRevisitDays),
+		RecommendedProblemsPerDay: recommended,
+		RecommendationFeasible:    feasible,
+		Days:                      forecastDays,
+	}
+	for i := range forecast.Days {
+		forecast.Days[i].MaxGapDays = math.Round(forecast.Days[i].MaxGapDays*10) / 10
+	}
+
+	respondJSON(w, http.StatusOK, forecast)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// helper to build n problems of the same age that were never revisited
+func makeBacklog(n int, daysAgo float64) []Problem {
+	problems := make([]Problem, n)
+	for i := range problems {
+		problems[i] = makeProblem(daysAgo, -1, 0)
+		problems[i].ID = uuid.New()
+	}
+	return problems
+}
+
+func TestSimulateForecast_DoesNotMutateInput(t *testing.T) {
+	problems := makeBacklog(5, 10)
+	prefs := UserPreferences{ProblemsPerDay: 2, MinRevisitDays: 2, MaxRevisitDays: 10}
+
+	SimulateForecast(problems, prefs, time.Now(), 7)
+
+	for _, p := range problems {
+		if p.LastRevisitedAt.Valid || p.TimesRevisited != 0 {
+			t.Fatalf("input problems should not be modified by the simulation")
+		}
+	}
+}
+
+func TestSimulateForecast_SelectsBudgetPerDay(t *testing.T) {
+	problems := makeBacklog(10, 30)
+	prefs := UserPreferences{ProblemsPerDay: 3, MinRevisitDays: 2, MaxRevisitDays: 10}
+
+	days := SimulateForecast(problems, prefs, time.Now(), 5)
+	if len(days) != 5 {
+		t.Fatalf("expected 5 forecast days, got %d", len(days))
+	}
+	if days[0].Selected != 3 || days[0].Backlog != 7 {
+		t.Errorf("day 1: expected 3 selected and 7 backlog, got %d and %d", days[0].Selected, days[0].Backlog)
+	}
+}
+
+func TestRecommendProblemsPerDay_KeepsGapUnderMax(t *testing.T) {
+	problems := makeBacklog(30, 5)
+	prefs := UserPreferences{ProblemsPerDay: 1, MinRevisitDays: 1, MaxRevisitDays: 7}
+	start := time.Now()
+
+	recommended, feasible := RecommendProblemsPerDay(problems, prefs, start, 21)
+	if !feasible || recommended <= prefs.ProblemsPerDay {
+		t.Fatalf("1/day cannot cover 30 problems within 7 days, got recommendation %d", recommended)
+	}
+
+	prefs.ProblemsPerDay = recommended
+	if gap := maxGap(SimulateForecast(problems, prefs, start, 21)); gap > float64(prefs.MaxRevisitDays) {
+		t.Errorf("recommended budget %d still leaves a %.1f-day gap", recommended, gap)
+	}
+}
+
+func TestRecommendProblemsPerDay_Infeasible(t *testing.T) {
+	// A problem can be revisited at most every min_revisit_days, so a 3-day
+	// maximum can't be met with a 5-day minimum at any budget
+	problems := makeBacklog(5, 10)
+	prefs := UserPreferences{ProblemsPerDay: 1, MinRevisitDays: 5, MaxRevisitDays: 3}
+
+	recommended, feasible := RecommendProblemsPerDay(problems, prefs, time.Now(), 14)
+	if feasible || recommended != len(problems) {
+		t.Errorf("got %d (feasible %v), want the cap of %d and infeasible", recommended, feasible, len(problems))
+	}
+}
+
+// A what-if budget must not leak into the stored plan: the forecast only
+// reads today's plan.
+func TestGetForecastBudgetOverrideIsReadOnly(t *testing.T) {
+	userID := testUser(t)
+	_, err := db.Exec(`UPDATE users SET preferences = '{"problems_per_day": 1, "min_revisit_days": 2, "max_revisit_days": 10}' WHERE id = $1`, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		id := testProblem(t, userID, fmt.Sprintf("Problem %d", i), fmt.Sprintf("https://example.com/%d", i))
+		if _, err := db.Exec(`UPDATE problems SET date_added = NOW() - INTERVAL '10 days' WHERE id = $1`, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	router := newRouter(testAuth(userID))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/problems/forecast?problems_per_day=3", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("forecast: %d %s", rec.Code, rec.Body)
+	}
+	var plans int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM daily_plans WHERE user_id = $1`, userID).Scan(&plans); err != nil {
+		t.Fatal(err)
+	}
+	if plans != 0 {
+		t.Fatalf("the forecast created %d daily plans", plans)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/problems/today", nil))
+	var items int
+	err = db.QueryRow(`
+		SELECT COUNT(*) FROM daily_plan_items dpi JOIN daily_plans dp ON dp.id = dpi.plan_id
+		WHERE dp.user_id = $1 AND dp.plan_date = CURRENT_DATE`, userID).Scan(&items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || items != 1 {
+		t.Errorf("today's plan has %d problems, want the stored budget of 1 (status %d)", items, rec.Code)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defaultGoalMinRevisits is K, the revisits each in-scope problem should get
+// before the target date, when the goal doesn't set it.
+const defaultGoalMinRevisits = 3
+
+// Goal is an interview goal. While active and before its target date, it puts
+// the daily plan into cram mode (see cram.go).
+type Goal struct {
+	ID           uuid.UUID     `json:"id"`
+	TargetDate   string        `json:"target_date"` // YYYY-MM-DD
+	StartDate    string        `json:"start_date"`  // YYYY-MM-DD, revisits count from this day
+	CollectionID uuid.NullUUID `json:"collection_id"`
+	Topic        string        `json:"topic,omitempty"`
+	DailyBudget  int           `json:"daily_budget"`
+	MinRevisits  int           `json:"min_revisits"`
+	WeakTopics   []string      `json:"weak_topics"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+// cram converts the goal to the scheduler's representation.
+func (g Goal) cram() CramGoal {
+	start, _ := time.ParseInLocation("2006-01-02", g.StartDate, time.Local)
+	target, _ := time.ParseInLocation("2006-01-02", g.TargetDate, time.Local)
+	return CramGoal{
+		StartDate:   start,
+		TargetDate:  target,
+		DailyBudget: g.DailyBudget,
+		MinRevisits: g.MinRevisits,
+		WeakTopics:  g.WeakTopics,
+	}
+}
+
+// loadActiveGoal returns the user's active goal, or nil if there is none or
+// its target date has arrived.
+func loadActiveGoal(q querier, userID uuid.UUID) (*Goal, error) {
+	var g Goal
+	var weakTopics []byte
+	err := q.QueryRow(`
+		SELECT id, to_char(target_date, 'YYYY-MM-DD'), to_char(start_date, 'YYYY-MM-DD'),
+		       collection_id, COALESCE(topic, ''), daily_budget, min_revisits, weak_topics, created_at
+		FROM goals
+		WHERE user_id = $1 AND active AND target_date > CURRENT_DATE`, userID).Scan(
+		&g.ID, &g.TargetDate, &g.StartDate, &g.CollectionID, &g.Topic,
+		&g.DailyBudget, &g.MinRevisits, &weakTopics, &g.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.WeakTopics = []string{}
+	if err := json.Unmarshal(weakTopics, &g.WeakTopics); err != nil {
+		return nil, fmt.Errorf("goal %s: weak_topics: %w", g.ID, err)
+	}
+	return &g, nil
+}
+
+// loadGoalProblems returns the goal's in-scope active problems and how many
+// times each has been revisited since the goal started. Today's revisits are
+// left out when building a plan (start-of-day view) and included for progress.
+func loadGoalProblems(q querier, userID uuid.UUID, g *Goal, includeToday bool) ([]Problem, map[uuid.UUID]int, error) {
+	rows, err := q.Query(`
+		SELECT `+problemColumnsFor("p")+`, COUNT(rh.id)
+		FROM problems p
+		LEFT JOIN revisit_history rh
+		       ON rh.problem_id = p.id AND rh.revisited_at::date >= $2::date
+		      AND ($3 OR rh.revisited_at::date < CURRENT_DATE)
+		WHERE p.user_id = $1 AND p.status = 'active'
+		  AND `+focusScopeClause("p.id", "$4")+`
+		  AND ($5 = '' OR LOWER(p.topic) = LOWER($5))
+		GROUP BY p.id
+		ORDER BY p.date_added`, userID, g.StartDate, includeToday, g.CollectionID, g.Topic)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var problems []Problem
+	done := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var p Problem
+		var count int
+		if err := scanProblem(rows, &p, &count); err != nil {
+			return nil, nil, err
+		}
+		problems = append(problems, p)
+		done[p.ID] = count
+	}
+	return problems, done, rows.Err()
+}
+
+// GetGoal returns the user's active goal, or null.
+func GetGoal(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	goal, err := loadActiveGoal(db, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"goal": goal})
+}
+
+// SetGoal creates the user's interview goal, replacing any active one.
+// Body: target_date (YYYY-MM-DD, required), daily_budget (required),
+// min_revisits (default 3), collection_id, topic, weak_topics.
+// Today's plan switches to cram mode right away unless it was already started.
+func SetGoal(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	var body struct {
+		TargetDate   string        `json:"target_date"`
+		CollectionID uuid.NullUUID `json:"collection_id"`
+		Topic        string        `json:"topic"`
+		DailyBudget  int           `json:"daily_budget"`
+		MinRevisits  int           `json:"min_revisits"`
+		WeakTopics   []string      `json:"weak_topics"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, r, invalidBody(err))
+		return
+	}
+
+	target, err := time.ParseInLocation("2006-01-02", body.TargetDate, time.Local)
+	if err != nil {
+		respondError(w, r, invalidField("body.target_date", "must be YYYY-MM-DD"))
+		return
+	}
+	if (CramGoal{TargetDate: target}).DaysLeft(time.Now()) < 1 {
+		respondError(w, r, invalidField("body.target_date", "must be in the future"))
+		return
+	}
+	if body.DailyBudget < 1 || body.DailyBudget > 100 {
+		respondError(w, r, invalidField("body.daily_budget", "must be between 1 and 100"))
+		return
+	}
+	if body.MinRevisits == 0 {
+		body.MinRevisits = defaultGoalMinRevisits
+	}
+	if body.MinRevisits < 1 || body.MinRevisits > 20 {
+		respondError(w, r, invalidField("body.min_revisits", "must be between 1 and 20"))
+		return
+	}
+	if body.WeakTopics == nil {
+		body.WeakTopics = []string{}
+	}
+	weakTopics, _ := json.Marshal(body.WeakTopics)
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if body.CollectionID.Valid {
+		var exists bool
+		err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM collections WHERE id = $1 AND user_id = $2)`,
+			body.CollectionID.UUID, userID).Scan(&exists)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		if !exists {
+			respondError(w, r, notFound("Collection"))
+			return
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE goals SET active = FALSE WHERE user_id = $1 AND active`, userID); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO goals (user_id, target_date, start_date, collection_id, topic, daily_budget, min_revisits, weak_topics)
+		VALUES ($1, $2::date, CURRENT_DATE, $3, NULLIF($4, ''), $5, $6, $7::jsonb)`,
+		userID, body.TargetDate, body.CollectionID, strings.TrimSpace(body.Topic),
+		body.DailyBudget, body.MinRevisits, string(weakTopics))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if _, err := resetTodaysPlanIfUnstarted(tx, userID); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	goal, err := loadActiveGoal(tx, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	publishSettingsChanged(userID, "goal")
+	respondJSON(w, http.StatusOK, map[string]interface{}{"goal": goal})
+}
+
+// DeleteGoal ends the active goal and returns scheduling to normal.
+func DeleteGoal(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE goals SET active = FALSE WHERE user_id = $1 AND active`, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		respondError(w, r, notFound("Active goal"))
+		return
+	}
+
+	if _, err := resetTodaysPlanIfUnstarted(tx, userID); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	publishSettingsChanged(userID, "goal")
+	respondJSON(w, http.StatusOK, map[string]string{"status": "ended"})
+}
+
+// GetGoalProgress reports progress toward the active goal and whether the
+// user is on track to get every in-scope problem to min_revisits in time.
+func GetGoalProgress(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	goal, err := loadActiveGoal(db, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if goal == nil {
+		respondError(w, r, notFound("Active goal"))
+		return
+	}
+
+	problems, done, err := loadGoalProblems(db, userID, goal, true)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	progress := EvaluateGoalProgress(goal.cram(), problems, done, time.Now())
+
+	respondJSON(w, http.StatusOK, struct {
+		Goal *Goal `json:"goal"`
+		GoalProgress
+	}{goal, progress})
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -24,46 +26,182 @@ func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
 	w.Write(response)
 }
 
-// GetProblems returns list of problems for the authenticated user
+// problemSortFields are the allowed ?sort= values for GetProblems.
+var problemSortFields = map[string]sortField{
+	"date_added":      {expr: "date_added", cast: "timestamptz"},
+	"last_revisited":  {expr: "COALESCE(last_revisited_at, 'epoch'::timestamptz)", cast: "timestamptz"},
+	"times_revisited": {expr: "times_revisited", cast: "int"},
+	"title":           {expr: "LOWER(title)", cast: "text"},
+	"weight":          {cast: "float8"}, // computed in Go
+}
+
+// GetProblems returns list of problems for the authenticated user.
+// Filters: status, difficulty, source, topic (or tag) — comma-separated lists —
+// added_from/added_to, revisited_from/revisited_to and never_revisited.
+// Sorting: ?sort=date_added|last_revisited|times_revisited|title|weight, "-" prefix for descending.
+// Pagination is cursor-based via ?limit and ?cursor; the next page is given in
+// the Link header and the total match count in X-Total-Count.
 func GetProblems(w http.ResponseWriter, r *http.Request) {
 	userID := GetUserIDFromContext(r)
-	status := r.URL.Query().Get("status")
-
-	query := `
-		SELECT id, user_id, title, link, date_added, last_revisited_at, 
-		       times_revisited, status, COALESCE(topic, ''), COALESCE(difficulty, ''), COALESCE(source, 'LeetCode'), COALESCE(notes, '')
-		FROM problems
-		WHERE user_id = $1`
+	q := r.URL.Query()
 
-	args := []interface{}{userID}
+	page, err := parsePageRequest(q, problemSortFields, "-date_added")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
 
-	if status != "" {
-		query += " AND status = $2"
-		args = append(args, status)
+	f := &sqlFilter{}
+	f.where("user_id = " + f.arg(userID))
+	f.where("status <> 'trashed'")
+	if status := q.Get("status"); status != "" {
+		f.where("status = " + f.arg(status))
+	}
+	addListFilter(f, "difficulty", q.Get("difficulty"))
+	addListFilter(f, "source", q.Get("source"))
+	addListFilter(f, "topic", q.Get("topic")+","+q.Get("tag"))
+	if err := addDateRange(f, "date_added", q.Get("added_from"), q.Get("added_to")); err != nil {
+		respondError(w, r, invalid(err.Error()))
+		return
+	}
+	if err := addDateRange(f, "last_revisited_at", q.Get("revisited_from"), q.Get("revisited_to")); err != nil {
+		respondError(w, r, invalid(err.Error()))
+		return
+	}
+	if v := q.Get("never_revisited"); v != "" {
+		never, err := strconv.ParseBool(v)
+		if err != nil {
+			respondError(w, r, invalidField("query.never_revisited", "must be true or false"))
+			return
+		}
+		if never {
+			f.where("last_revisited_at IS NULL")
+		} else {
+			f.where("last_revisited_at IS NOT NULL")
+		}
 	}
 
-	query += " ORDER BY date_added DESC"
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM problems WHERE `+f.sql(), f.args...).Scan(&total); err != nil {
+		respondError(w, r, err)
+		return
+	}
 
-	rows, err := db.Query(query, args...)
+	var problems []Problem
+	var next *listCursor
+	if page.sort.expr == "" {
+		problems, next, err = listProblemsByWeight(f, page)
+	} else {
+		problems, next, err = listProblemsSorted(f, page)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, err)
 		return
 	}
+
+	setListHeaders(w, r, total, next)
+	respondJSONConditional(w, r, problems)
+}
+
+// listProblemsSorted runs a keyset-paginated problem query sorted in Postgres.
+func listProblemsSorted(f *sqlFilter, page pageRequest) ([]Problem, *listCursor, error) {
+	page.applyKeyset(f, "id")
+
+	rows, err := db.Query(`
+		SELECT `+problemColumns+`, (`+page.sort.expr+`)::text
+		FROM problems
+		WHERE `+f.sql()+page.orderAndLimit("id"), f.args...)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer rows.Close()
 
 	problems := []Problem{}
+	var sortValues []string
 	for rows.Next() {
 		var p Problem
-		if err := rows.Scan(&p.ID, &p.UserID, &p.Title, &p.Link, &p.DateAdded,
-			&p.LastRevisitedAt, &p.TimesRevisited, &p.Status,
-			&p.Topic, &p.Difficulty, &p.Source, &p.Notes); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		var sortValue string
+		if err := scanProblem(rows, &p, &sortValue); err != nil {
+			return nil, nil, err
 		}
 		problems = append(problems, p)
+		sortValues = append(sortValues, sortValue)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if page.paginated && len(problems) > page.limit {
+		last := page.limit - 1
+		return problems[:page.limit], page.nextCursor(sortValues[last], problems[last].ID), nil
+	}
+	return problems, nil, nil
+}
+
+// listProblemsByWeight loads every matching problem and sorts by scheduling
+// weight in Go. Weights drift over time, so pages are consistent within a
+// short browsing session rather than exactly stable.
+func listProblemsByWeight(f *sqlFilter, page pageRequest) ([]Problem, *listCursor, error) {
+	rows, err := db.Query(`
+		SELECT `+problemColumns+`
+		FROM problems
+		WHERE `+f.sql(), f.args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	type weighted struct {
+		problem Problem
+		weight  float64
+	}
+	var all []weighted
+	for rows.Next() {
+		var p Problem
+		if err := scanProblem(rows, &p); err != nil {
+			return nil, nil, err
+		}
+		all = append(all, weighted{p, CalculateWeight(p)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// before reports whether a sorts before b in the requested direction
+	before := func(aWeight float64, aID uuid.UUID, bWeight float64, bID uuid.UUID) bool {
+		if aWeight != bWeight {
+			return (aWeight < bWeight) != page.desc
+		}
+		return (aID.String() < bID.String()) != page.desc
 	}
+	sort.Slice(all, func(i, j int) bool {
+		return before(all[i].weight, all[i].problem.ID, all[j].weight, all[j].problem.ID)
+	})
 
-	respondJSON(w, http.StatusOK, problems)
+	start := 0
+	if page.cursor != nil {
+		cursorWeight, err := strconv.ParseFloat(page.cursor.Value, 64)
+		if err != nil {
+			return nil, nil, invalidField("query.cursor", "does not belong to this sort")
+		}
+		for start < len(all) && !before(cursorWeight, page.cursor.ID, all[start].weight, all[start].problem.ID) {
+			start++
+		}
+	}
+	all = all[start:]
+
+	var next *listCursor
+	if page.paginated && len(all) > page.limit {
+		all = all[:page.limit]
+		last := all[len(all)-1]
+		next = page.nextCursor(strconv.FormatFloat(last.weight, 'g', -1, 64), last.problem.ID)
+	}
+
+	problems := make([]Problem, 0, len(all))
+	for _, item := range all {
+		problems = append(problems, item.problem)
+	}
+	return problems, next, nil
 }
 
 // GetProblemByID returns a single problem's details including revisit history and weight
@@ -72,22 +210,18 @@ func GetProblemByID(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
 		return
 	}
 
 	var p ProblemDetail
-	err = db.QueryRow(`
-		SELECT id, user_id, title, link, date_added, last_revisited_at, 
-		       times_revisited, status, COALESCE(topic, ''), COALESCE(difficulty, ''), COALESCE(source, 'LeetCode'), COALESCE(notes, '')
+	err = scanProblem(db.QueryRow(`
+		SELECT `+problemColumns+`
 		FROM problems 
-		WHERE id = $1 AND user_id = $2`, id, userID).Scan(
-		&p.ID, &p.UserID, &p.Title, &p.Link, &p.DateAdded,
-		&p.LastRevisitedAt, &p.TimesRevisited, &p.Status,
-		&p.Topic, &p.Difficulty, &p.Source, &p.Notes)
+		WHERE id = $1 AND user_id = $2 AND status <> 'trashed'`, id, userID), &p.Problem)
 
 	if err != nil {
-		http.Error(w, "Problem not found", http.StatusNotFound)
+		respondError(w, r, notFound("Problem"))
 		return
 	}
 
@@ -102,18 +236,8 @@ func GetProblemByID(w http.ResponseWriter, r *http.Request) {
 	p.RevisitedToday = todayCount > 0
 
 	// Calculate weight/scheduling info
-	problemForWeight := Problem{
-		ID:              p.ID,
-		UserID:          p.UserID,
-		Title:           p.Title,
-		Link:            p.Link,
-		DateAdded:       p.DateAdded,
-		LastRevisitedAt: p.LastRevisitedAt,
-		TimesRevisited:  p.TimesRevisited,
-		Status:          p.Status,
-	}
 	// Use default min revisit days (2) for MVP; can be user-specific later
-	p.WeightInfo = CalculateProblemWeight(problemForWeight, 2)
+	p.WeightInfo = CalculateProblemWeight(p.Problem, 2)
 
 	// Fetch revisit history for this problem (newest first)
 	historyRows, err := db.Query(`
@@ -142,6 +266,7 @@ func GetProblemByID(w http.ResponseWriter, r *http.Request) {
 		p.RevisitHistory = []RevisitEntry{}
 	}
 
+	w.Header().Set("ETag", problemETag(p.Version))
 	respondJSON(w, http.StatusOK, p)
 }
 
@@ -149,30 +274,58 @@ func GetProblemByID(w http.ResponseWriter, r *http.Request) {
 func CreateProblem(w http.ResponseWriter, r *http.Request) {
 	userID := GetUserIDFromContext(r)
 
-	var p Problem
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	in, err := decodeProblemInput(r, false)
+	if err != nil {
+		respondError(w, r, err)
 		return
 	}
+	var p Problem
+	in.apply(&p)
 
 	// Always use the authenticated user's ID
 	p.UserID = userID
 
-	// Default source
-	if p.Source == "" {
-		p.Source = "LeetCode"
+	// Recognize the platform (also defaults Source) and check for duplicates.
+	// ?on_duplicate=merge folds the submission into the existing problem instead of rejecting it.
+	platform, canonicalKey := canonicalLink(&p)
+	existing, err := findDuplicateProblem(db, userID, platform, canonicalKey, uuid.Nil)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if existing != nil {
+		if r.URL.Query().Get("on_duplicate") != "merge" {
+			respondDuplicate(w, r, existing)
+			return
+		}
+		merged, err := mergeIntoProblem(existing.ID, p)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		syncNoteFlashcardsAfterWrite(merged.ID, merged.Notes)
+		publishProblemEvent(userID, EventProblemUpdated, merged.ID)
+		respondJSON(w, http.StatusOK, merged)
+		return
 	}
 
 	sqlStatement := `
-		INSERT INTO problems (user_id, title, link, status, times_revisited, date_added, difficulty, source, notes)
-		VALUES ($1, $2, $3, 'active', 0, NOW(), $4, $5, $6)
-		RETURNING id, date_added, status`
+		INSERT INTO problems (user_id, title, link, status, times_revisited, date_added, difficulty, source, notes, platform, canonical_key, catalog_problem_id, topic)
+		VALUES ($1, $2, $3, 'active', 0, NOW(), $4, $5, $6, $7, $8,
+		        (SELECT id FROM catalog_problems WHERE platform = $7 AND canonical_key = $8), NULLIF($9, ''))
+		RETURNING id, date_added, status, pinned, priority_multiplier, catalog_problem_id, version`
 
-	err := db.QueryRow(sqlStatement, p.UserID, p.Title, p.Link, p.Difficulty, p.Source, p.Notes).Scan(&p.ID, &p.DateAdded, &p.Status)
+	err = db.QueryRow(sqlStatement, p.UserID, p.Title, p.Link, p.Difficulty, p.Source, p.Notes, platform, canonicalKey, p.Topic).Scan(&p.ID, &p.DateAdded, &p.Status, &p.Pinned, &p.PriorityMultiplier, &p.CatalogProblemID, &p.Version)
+	if isUniqueViolation(err) {
+		respondDuplicate(w, r, nil)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, err)
 		return
 	}
+	syncNoteFlashcardsAfterWrite(p.ID, p.Notes)
+	publishProblemEvent(userID, EventProblemCreated, p.ID)
 
 	respondJSON(w, http.StatusCreated, p)
 }
@@ -183,27 +336,35 @@ func MarkRevisited(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
 		return
 	}
 
 	// Verify ownership
 	var ownerID uuid.UUID
-	err = db.QueryRow(`SELECT user_id FROM problems WHERE id = $1`, id).Scan(&ownerID)
+	err = db.QueryRow(`SELECT user_id FROM problems WHERE id = $1 AND status <> 'trashed'`, id).Scan(&ownerID)
 	if err != nil {
-		http.Error(w, "Problem not found", http.StatusNotFound)
+		respondError(w, r, notFound("Problem"))
 		return
 	}
 	if ownerID != userID {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		respondError(w, r, forbidden("You do not own this problem"))
 		return
 	}
 
-	// Parse optional notes from request body
+	// Parse optional notes and solution from request body
 	var body struct {
-		Notes string `json:"notes"`
+		Notes    string         `json:"notes"`
+		Solution *SolutionInput `json:"solution"`
 	}
 	json.NewDecoder(r.Body).Decode(&body)
+	if body.Solution != nil {
+		body.Solution.RevisitID = uuid.NullUUID{}
+		if err := body.Solution.validate("body.solution"); err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
 
 	// Guard: check if already revisited today
 	var todayCount int
@@ -211,21 +372,18 @@ func MarkRevisited(w http.ResponseWriter, r *http.Request) {
 		SELECT COUNT(*) FROM revisit_history
 		WHERE problem_id = $1 AND revisited_at::date = CURRENT_DATE`, id).Scan(&todayCount)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, err)
 		return
 	}
 	if todayCount > 0 {
-		respondJSON(w, http.StatusConflict, map[string]string{
-			"error":   "already_revisited_today",
-			"message": "This problem has already been revisited today. Come back tomorrow!",
-		})
+		respondError(w, r, conflict("already_revisited_today", "This problem has already been revisited today. Come back tomorrow!", nil))
 		return
 	}
 
 	// Start a transaction to ensure both operations succeed
 	tx, err := db.Begin()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, err)
 		return
 	}
 	defer tx.Rollback()
@@ -235,30 +393,50 @@ func MarkRevisited(w http.ResponseWriter, r *http.Request) {
 	if body.Notes != "" {
 		notes = &body.Notes
 	}
-	_, err = tx.Exec(`
+	var revisitID uuid.UUID
+	err = tx.QueryRow(`
 		INSERT INTO revisit_history (problem_id, revisited_at, notes)
-		VALUES ($1, NOW(), $2)`, id, notes)
+		VALUES ($1, NOW(), $2)
+		RETURNING id`, id, notes).Scan(&revisitID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, err)
 		return
 	}
 
-	// 2. Update the problem's aggregate counters
+	// Optionally save the attempt's code as the next solution version
+	var solution *Solution
+	if body.Solution != nil {
+		body.Solution.RevisitID = uuid.NullUUID{UUID: revisitID, Valid: true}
+		s, err := insertSolution(tx, id, *body.Solution)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		solution = &s
+	}
+
+	// 2. Update the problem's aggregate counters (a revisit also releases a pin)
 	_, err = tx.Exec(`
 		UPDATE problems 
-		SET times_revisited = times_revisited + 1, last_revisited_at = NOW()
+		SET times_revisited = times_revisited + 1, last_revisited_at = NOW(), pinned = FALSE
 		WHERE id = $1`, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, err)
 		return
 	}
 
 	if err := tx.Commit(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "revisited"})
+	publishEvent(userID, EventRevisitRecorded, map[string]interface{}{"problem_id": id, "revisit_id": revisitID})
+
+	resp := map[string]interface{}{"status": "revisited", "revisit_id": revisitID}
+	if solution != nil {
+		resp["solution"] = solution
+	}
+	respondJSON(w, http.StatusOK, resp)
 }
 
 // ArchiveProblem retires a problem
@@ -267,109 +445,175 @@ func ArchiveProblem(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
 		return
 	}
 
 	result, err := db.Exec(`
 		UPDATE problems 
 		SET status = 'retired'
-		WHERE id = $1 AND user_id = $2`, id, userID)
+		WHERE id = $1 AND user_id = $2 AND status <> 'trashed'`, id, userID)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		http.Error(w, "Problem not found", http.StatusNotFound)
+		respondError(w, r, notFound("Problem"))
 		return
 	}
 
+	publishProblemEvent(userID, EventProblemArchived, id)
 	respondJSON(w, http.StatusOK, map[string]string{"status": "retired"})
 }
 
-// UpdateProblem updates problem details
+// UpdateProblem replaces a problem's editable fields. Omitted fields are
+// cleared, except topic, which is kept unless given.
 func UpdateProblem(w http.ResponseWriter, r *http.Request) {
+	if p, ok := updateProblem(w, r, false); ok {
+		publishProblemEvent(p.UserID, EventProblemUpdated, p.ID)
+		w.Header().Set("ETag", problemETag(p.Version))
+		respondJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+	}
+}
+
+// PatchProblem changes only the fields present in the body and returns the
+// updated problem.
+func PatchProblem(w http.ResponseWriter, r *http.Request) {
+	if p, ok := updateProblem(w, r, true); ok {
+		publishProblemEvent(p.UserID, EventProblemUpdated, p.ID)
+		w.Header().Set("ETag", problemETag(p.Version))
+		respondJSON(w, http.StatusOK, p)
+	}
+}
+
+// updateProblem applies a PUT or PATCH body to a problem, provided If-Match
+// names its current version. It writes the error response itself and reports
+// whether the update went through.
+func updateProblem(w http.ResponseWriter, r *http.Request, partial bool) (Problem, bool) {
 	userID := GetUserIDFromContext(r)
 	idStr := chi.URLParam(r, "id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
-		return
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return Problem{}, false
 	}
 
-	var p Problem
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	in, err := decodeProblemInput(r, partial)
+	if err != nil {
+		respondError(w, r, err)
+		return Problem{}, false
 	}
 
-	result, err := db.Exec(`
-		UPDATE problems 
-		SET title = $1, link = $2, difficulty = $3, source = $4, notes = $5
-		WHERE id = $6 AND user_id = $7`,
-		p.Title, p.Link, p.Difficulty, p.Source, p.Notes, id, userID)
-
+	var p Problem
+	err = scanProblem(db.QueryRow(`
+		SELECT `+problemColumns+`
+		FROM problems
+		WHERE id = $1 AND user_id = $2 AND status <> 'trashed'`, id, userID), &p)
+	if err == sql.ErrNoRows {
+		respondError(w, r, notFound("Problem"))
+		return p, false
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		respondError(w, r, err)
+		return p, false
 	}
+	if err := checkIfMatch(r, p.Version); err != nil {
+		w.Header().Set("ETag", problemETag(p.Version))
+		respondError(w, r, err)
+		return p, false
+	}
+	if !partial {
+		p.Link, p.Difficulty, p.Source, p.Notes = "", "", "", ""
+	}
+	in.apply(&p)
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Problem not found", http.StatusNotFound)
-		return
+	platform, canonicalKey := canonicalLink(&p)
+	existing, err := findDuplicateProblem(db, userID, platform, canonicalKey, id)
+	if err != nil {
+		respondError(w, r, err)
+		return p, false
+	}
+	if existing != nil {
+		respondDuplicate(w, r, existing)
+		return p, false
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+	err = db.QueryRow(`
+		UPDATE problems 
+		SET title = $1, link = $2, difficulty = $3, source = $4, notes = $5, topic = NULLIF($6, ''),
+		    platform = $7, canonical_key = $8,
+		    catalog_problem_id = (SELECT id FROM catalog_problems WHERE platform = $7 AND canonical_key = $8)
+		WHERE id = $9 AND user_id = $10 AND status <> 'trashed' AND version = $11
+		RETURNING catalog_problem_id, version`,
+		p.Title, p.Link, p.Difficulty, p.Source, p.Notes, p.Topic, platform, canonicalKey, id, userID, p.Version).Scan(
+		&p.CatalogProblemID, &p.Version)
+
+	if isUniqueViolation(err) {
+		respondDuplicate(w, r, nil)
+		return p, false
+	}
+	if err == sql.ErrNoRows {
+		// Changed (or trashed) since it was loaded above
+		respondError(w, r, preconditionFailed(0))
+		return p, false
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return p, false
+	}
+	syncNoteFlashcardsAfterWrite(id, p.Notes)
+	return p, true
 }
 
-// DeleteProblem permanently removes a problem and its history
+// DeleteProblem moves a problem to the trash, provided If-Match names its
+// current version. It is hidden from all lists and scheduling, and can be
+// restored until it is purged after the retention window.
 func DeleteProblem(w http.ResponseWriter, r *http.Request) {
 	userID := GetUserIDFromContext(r)
 	idStr := chi.URLParam(r, "id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
 		return
 	}
 
-	// Start a transaction to delete history and problem
-	tx, err := db.Begin()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var version int
+	err = db.QueryRow(`SELECT version FROM problems WHERE id = $1 AND user_id = $2 AND status <> 'trashed'`,
+		id, userID).Scan(&version)
+	if err == sql.ErrNoRows {
+		respondError(w, r, notFound("Problem"))
 		return
 	}
-	defer tx.Rollback()
-
-	// 1. Delete history
-	_, err = tx.Exec(`DELETE FROM revisit_history WHERE problem_id = $1`, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, err)
+		return
+	}
+	if err := checkIfMatch(r, version); err != nil {
+		w.Header().Set("ETag", problemETag(version))
+		respondError(w, r, err)
 		return
 	}
 
-	// 2. Delete problem (with ownership check)
-	result, err := tx.Exec(`DELETE FROM problems WHERE id = $1 AND user_id = $2`, id, userID)
+	result, err := db.Exec(`
+		UPDATE problems
+		SET previous_status = status, status = 'trashed', trashed_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND status <> 'trashed' AND version = $3`, id, userID, version)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		http.Error(w, "Problem not found", http.StatusNotFound)
-		return
-	}
-
-	if err := tx.Commit(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, preconditionFailed(0))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	publishProblemEvent(userID, EventProblemDeleted, id)
+	respondJSON(w, http.StatusOK, map[string]string{"status": "trashed"})
 }
 
 // GetProblemWeight returns the scheduling weight for a single problem
@@ -378,22 +622,18 @@ func GetProblemWeight(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
 		return
 	}
 
 	var p Problem
-	err = db.QueryRow(`
-		SELECT id, user_id, title, link, date_added, last_revisited_at, 
-		       times_revisited, status, COALESCE(topic, ''), COALESCE(difficulty, ''), COALESCE(source, 'LeetCode'), COALESCE(notes, '')
+	err = scanProblem(db.QueryRow(`
+		SELECT `+problemColumns+`
 		FROM problems 
-		WHERE id = $1 AND user_id = $2`, id, userID).Scan(
-		&p.ID, &p.UserID, &p.Title, &p.Link, &p.DateAdded,
-		&p.LastRevisitedAt, &p.TimesRevisited, &p.Status,
-		&p.Topic, &p.Difficulty, &p.Source, &p.Notes)
+		WHERE id = $1 AND user_id = $2 AND status <> 'trashed'`, id, userID), &p)
 
 	if err != nil {
-		http.Error(w, "Problem not found", http.StatusNotFound)
+		respondError(w, r, notFound("Problem"))
 		return
 	}
 
@@ -406,13 +646,12 @@ func GetAllWeights(w http.ResponseWriter, r *http.Request) {
 	userID := GetUserIDFromContext(r)
 
 	rows, err := db.Query(`
-		SELECT id, user_id, title, link, date_added, last_revisited_at, 
-		       times_revisited, status, COALESCE(topic, ''), COALESCE(difficulty, ''), COALESCE(source, 'LeetCode'), COALESCE(notes, '')
+		SELECT `+problemColumns+`
 		FROM problems
 		WHERE status = 'active' AND user_id = $1
 		ORDER BY date_added DESC`, userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, err)
 		return
 	}
 	defer rows.Close()
@@ -425,9 +664,7 @@ func GetAllWeights(w http.ResponseWriter, r *http.Request) {
 	var results []ProblemWithWeight
 	for rows.Next() {
 		var p Problem
-		if err := rows.Scan(&p.ID, &p.UserID, &p.Title, &p.Link, &p.DateAdded,
-			&p.LastRevisitedAt, &p.TimesRevisited, &p.Status,
-			&p.Topic, &p.Difficulty, &p.Source, &p.Notes); err != nil {
+		if err := scanProblem(rows, &p); err != nil {
 			continue
 		}
 		weight := CalculateProblemWeight(p, 2)
@@ -446,9 +683,9 @@ func GetAllWeights(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, results)
 }
 
-// GetTodaysFocus returns today's recommended problems using weighted selection.
-// The selection is deterministic per day — refreshing the page shows the same problems.
-// Uses DaySeed() so tomorrow picks different ones.
+// GetTodaysFocus returns today's recommended problems.
+// The selection is materialized once per day in daily_plans (by the cron or the
+// first request), so refreshing the page or editing problems mid-day shows the same list.
 func GetTodaysFocus(w http.ResponseWriter, r *http.Request) {
 	userID := GetUserIDFromContext(r)
 
@@ -456,7 +693,7 @@ func GetTodaysFocus(w http.ResponseWriter, r *http.Request) {
 	var user User
 	err := db.QueryRow("SELECT preferences FROM users WHERE id = $1", userID).Scan(&user.Preferences)
 	if err != nil {
-		log.Printf("[API] Error fetching preferences for user %s: %v", userID, err)
+		slog.WarnContext(r.Context(), "Error fetching preferences, using defaults", "error", err)
 		// Fallback to defaults if pref not found (though should exist)
 		user.Preferences = UserPreferences{
 			MinRevisitDays: 2,
@@ -464,105 +701,35 @@ func GetTodaysFocus(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// 1. Fetch all active problems for this user that were added BEFORE today.
-	// We also fetch their state at the start of the day (ignoring today's revisits)
-	// so the selection is deterministic for the entire day.
-	rows, err := db.Query(`
-		SELECT p.id, p.user_id, p.title, p.link, p.date_added, p.status, 
-		       COALESCE(p.topic, ''), COALESCE(p.difficulty, ''), COALESCE(p.source, 'LeetCode'), COALESCE(p.notes, ''),
-		       COUNT(CASE WHEN rh.revisited_at::date < CURRENT_DATE THEN 1 END) as prev_times_revisited,
-		       MAX(CASE WHEN rh.revisited_at::date < CURRENT_DATE THEN rh.revisited_at END) as prev_last_revisited_at,
-		       COUNT(CASE WHEN rh.revisited_at::date = CURRENT_DATE THEN 1 END) as today_revisit_count
-		FROM problems p
-		LEFT JOIN revisit_history rh ON p.id = rh.problem_id
-		WHERE p.user_id = $1 AND p.status = 'active' AND p.date_added::date < CURRENT_DATE
-		GROUP BY p.id
-		ORDER BY p.date_added ASC`, userID)
+	// 1. Load (or generate) today's plan
+	plan, err := GetTodaysPlan(userID, user.Preferences)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, err)
 		return
 	}
-	defer rows.Close()
-
-	var allProblems []Problem
-	revisitedTodayMap := make(map[uuid.UUID]bool)
 
-	for rows.Next() {
-		var p Problem
-		var todayCount int
-		if err := rows.Scan(&p.ID, &p.UserID, &p.Title, &p.Link, &p.DateAdded, &p.Status,
-			&p.Topic, &p.Difficulty, &p.Source, &p.Notes,
-			&p.TimesRevisited, &p.LastRevisitedAt, &todayCount); err != nil {
-			log.Printf("[API] Error scanning problem in GetTodaysFocus: %v", err)
-			continue
-		}
-		allProblems = append(allProblems, p)
-		if todayCount > 0 {
-			revisitedTodayMap[p.ID] = true
-		}
-	}
-
-	// 2. Filter for eligibility based on PREVIOUS state (start of day)
-	var eligible []Problem
-	for _, p := range allProblems {
-		daysSinceLast := 9999.0
-		if p.LastRevisitedAt.Valid {
-			daysSinceLast = time.Since(p.LastRevisitedAt.Time).Hours() / 24
-		}
-		if daysSinceLast >= float64(user.Preferences.MinRevisitDays) {
-			eligible = append(eligible, p)
-		}
-	}
-
-	// 3. Select today's focus using day-deterministic seed
-	focusCount := user.Preferences.ProblemsPerDay
-	if len(eligible) < focusCount {
-		focusCount = len(eligible)
-	}
-	selected := SelectProblemsSeeded(eligible, focusCount, DaySeed())
-
-	// 4. Return the selected problems with their actual "revisited today" status
+	// 2. Return the planned problems with their actual "revisited today" status
 	type TodaysFocusItem struct {
 		Problem        Problem       `json:"problem"`
 		Weight         ProblemWeight `json:"weight"`
 		RevisitedToday bool          `json:"revisited_today"`
 	}
 
-	var items []TodaysFocusItem
+	items := []TodaysFocusItem{}
 	completedCount := 0
 
-	for _, p := range selected {
-		// Use the "reverted" state for weight calculation too, to keep it consistent
-		weight := CalculateProblemWeight(p, user.Preferences.MinRevisitDays)
-
-		revisitedToday := revisitedTodayMap[p.ID]
-		if revisitedToday {
+	for _, item := range plan {
+		if item.RevisitedToday {
 			completedCount++
-			// For the response, we might want to show the ACTUAL current state (incremented count)
-			// but wait, if we do that, the UI might flicker or change.
-			// Actually, ProblemDetail (which the dashboard uses to show weight) might expect current state.
-			// Let's stick to the current state for the Problem object itself if it was revisited today,
-			// BUT the selection was based on prev state.
-			if revisitedToday {
-				p.TimesRevisited++
-				// We don't have the exact latest timestamp here without another query or keeping it from join,
-				// but p.LastRevisitedAt for selection was the previous one.
-				// Let's just update the count for the UI.
-			}
 		}
-
 		items = append(items, TodaysFocusItem{
-			Problem:        p,
-			Weight:         weight,
-			RevisitedToday: revisitedToday,
+			Problem:        item.Problem,
+			Weight:         CalculateProblemWeight(item.Problem, user.Preferences.MinRevisitDays),
+			RevisitedToday: item.RevisitedToday,
 		})
 	}
 
-	if items == nil {
-		items = []TodaysFocusItem{}
-	}
-
-	// 5. Return with summary
+	// 3. Return with summary
 	response := struct {
 		Problems []TodaysFocusItem `json:"problems"`
 		Summary  struct {
@@ -580,6 +747,30 @@ func GetTodaysFocus(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, response)
 }
 
+// GetPlanHistory returns past daily plans with completion stats.
+// Accepts an optional ?days=N (default 30, max 365).
+func GetPlanHistory(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	days := 30
+	if d := r.URL.Query().Get("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 || n > 365 {
+			respondError(w, r, invalidField("query.days", "must be between 1 and 365"))
+			return
+		}
+		days = n
+	}
+
+	history, err := LoadPlanHistory(userID, days)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondJSONConditional(w, r, history)
+}
+
 // GetSettings fetches user preferences
 func GetSettings(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Settings endpoint"})
@@ -601,20 +792,18 @@ func TestEmail(w http.ResponseWriter, r *http.Request) {
 	err := db.QueryRow("SELECT id, email, preferences FROM users WHERE id = $1", userID).Scan(
 		&u.ID, &u.Email, &u.Preferences)
 	if err != nil {
-		respondJSON(w, http.StatusNotFound, map[string]string{
-			"error": "User not found. Ensure your Clerk account has been provisioned.",
-		})
+		respondError(w, r, &APIError{Status: http.StatusNotFound, Code: codeNotFound,
+			Message: "User not found. Ensure your Clerk account has been provisioned."})
 		return
 	}
 
 	// 2. Fetch all active problems
 	rows, err := db.Query(`
-		SELECT id, user_id, title, link, date_added, last_revisited_at,
-		       times_revisited, status, COALESCE(topic, ''), COALESCE(difficulty, ''), COALESCE(source, 'LeetCode'), COALESCE(notes, '')
+		SELECT `+problemColumns+`
 		FROM problems
 		WHERE user_id = $1 AND status = 'active'`, userID)
 	if err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		respondError(w, r, err)
 		return
 	}
 	defer rows.Close()
@@ -627,23 +816,15 @@ func TestEmail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var allDetails []ProblemWeightDetail
-	var eligible []Problem
 	var allProblems []Problem
 
 	for rows.Next() {
 		var p Problem
-		if err := rows.Scan(&p.ID, &p.UserID, &p.Title, &p.Link, &p.DateAdded,
-			&p.LastRevisitedAt, &p.TimesRevisited, &p.Status,
-			&p.Topic, &p.Difficulty, &p.Source, &p.Notes); err != nil {
+		if err := scanProblem(rows, &p); err != nil {
 			continue
 		}
 		allProblems = append(allProblems, p)
 
-		daysSinceLast := 9999.0
-		if p.LastRevisitedAt.Valid {
-			daysSinceLast = time.Since(p.LastRevisitedAt.Time).Hours() / 24
-		}
-
 		pw := CalculateProblemWeight(p, u.Preferences.MinRevisitDays)
 		detail := ProblemWeightDetail{
 			Title:  p.Title,
@@ -651,14 +832,21 @@ func TestEmail(w http.ResponseWriter, r *http.Request) {
 			Weight: pw,
 		}
 		allDetails = append(allDetails, detail)
-
-		if daysSinceLast >= float64(u.Preferences.MinRevisitDays) {
-			eligible = append(eligible, p)
-		}
 	}
 
-	// 3. Select problems using weighted random
-	toSend := SelectProblems(eligible, u.Preferences.ProblemsPerDay)
+	// 3. Send what the daily email would: today's plan, minus what's already
+	// been revisited, and the eligible count it was selected from
+	plan, err := GetTodaysPlan(u.ID, u.Preferences)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	toSend := pendingProblems(plan)
+	eligibleCount, err := loadPlanEligibleCount(u.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
 
 	// Mark selected in the details list
 	selectedSet := make(map[string]bool)
@@ -702,7 +890,7 @@ func TestEmail(w http.ResponseWriter, r *http.Request) {
 		EmailError:     emailErr,
 		RecipientEmail: u.Email,
 		TotalProblems:  len(allProblems),
-		EligibleCount:  len(eligible),
+		EligibleCount:  eligibleCount,
 		SelectedCount:  len(toSend),
 		ProblemsPerDay: u.Preferences.ProblemsPerDay,
 		MinRevisitDays: u.Preferences.MinRevisitDays,
@@ -721,7 +909,7 @@ func TestEmail(w http.ResponseWriter, r *http.Request) {
 // To make it truly manual trigger, we should probably have a way to force it.
 // For now, it just calls RunDailyJob() and returns a summary.
 func RunCronAllUsers(w http.ResponseWriter, r *http.Request) {
-	log.Println("[Admin] Manually triggering daily job for all users...")
+	slog.InfoContext(r.Context(), "Manually triggering daily job for all users")
 	RunDailyJob(true)
 	respondJSON(w, http.StatusOK, map[string]string{
 		"status":  "ok",
@@ -729,30 +917,69 @@ func RunCronAllUsers(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetRevisitHistory returns all revisit records with problem details
+// historySortFields are the allowed ?sort= values for GetRevisitHistory.
+var historySortFields = map[string]sortField{
+	"revisited_at": {expr: "rh.revisited_at", cast: "timestamptz"},
+	"title":        {expr: "LOWER(p.title)", cast: "text"},
+}
+
+// GetRevisitHistory returns revisit records with problem details.
+// Filters: q (title/notes substring), problem_id, difficulty, source, topic (or tag)
+// and from/to on the revisit date. Sorting and pagination work like GetProblems.
 func GetRevisitHistory(w http.ResponseWriter, r *http.Request) {
 	userID := GetUserIDFromContext(r)
-	searchQuery := r.URL.Query().Get("q")
-
-	query := `
-		SELECT rh.id, rh.problem_id, rh.revisited_at, rh.notes, 
-		       p.title, p.link, COALESCE(p.difficulty, ''), COALESCE(p.topic, '')
-		FROM revisit_history rh
-		JOIN problems p ON rh.problem_id = p.id
-		WHERE p.user_id = $1`
+	q := r.URL.Query()
 
-	args := []interface{}{userID}
+	page, err := parsePageRequest(q, historySortFields, "-revisited_at")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
 
-	if searchQuery != "" {
-		query += " AND (p.title ILIKE $2 OR rh.notes ILIKE $2)"
-		args = append(args, "%"+searchQuery+"%")
+	f := &sqlFilter{}
+	f.where("p.user_id = " + f.arg(userID))
+	f.where("p.status <> 'trashed'")
+	if searchQuery := q.Get("q"); searchQuery != "" {
+		ph := f.arg("%" + searchQuery + "%")
+		f.where("(p.title ILIKE " + ph + " OR rh.notes ILIKE " + ph + ")")
+	}
+	if v := q.Get("problem_id"); v != "" {
+		problemID, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, r, invalidField("query.problem_id", "must be a UUID"))
+			return
+		}
+		f.where("rh.problem_id = " + f.arg(problemID))
+	}
+	addListFilter(f, "p.difficulty", q.Get("difficulty"))
+	addListFilter(f, "p.source", q.Get("source"))
+	addListFilter(f, "p.topic", q.Get("topic")+","+q.Get("tag"))
+	if err := addDateRange(f, "rh.revisited_at", q.Get("from"), q.Get("to")); err != nil {
+		respondError(w, r, invalid(err.Error()))
+		return
 	}
 
-	query += " ORDER BY rh.revisited_at DESC"
+	var total int
+	err = db.QueryRow(`
+		SELECT COUNT(*)
+		FROM revisit_history rh
+		JOIN problems p ON rh.problem_id = p.id
+		WHERE `+f.sql(), f.args...).Scan(&total)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
 
-	rows, err := db.Query(query, args...)
+	page.applyKeyset(f, "rh.id")
+	rows, err := db.Query(`
+		SELECT rh.id, rh.problem_id, rh.revisited_at, rh.notes, 
+		       p.title, p.link, COALESCE(p.difficulty, ''), COALESCE(p.topic, ''),
+		       (`+page.sort.expr+`)::text
+		FROM revisit_history rh
+		JOIN problems p ON rh.problem_id = p.id
+		WHERE `+f.sql()+page.orderAndLimit("rh.id"), f.args...)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondError(w, r, err)
 		return
 	}
 	defer rows.Close()
@@ -769,19 +996,26 @@ func GetRevisitHistory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	history := []RevisitHistoryItem{}
+	var sortValues []string
 	for rows.Next() {
 		var item RevisitHistoryItem
+		var sortValue string
 		if err := rows.Scan(&item.ID, &item.ProblemID, &item.RevisitedAt, &item.Notes,
-			&item.ProblemTitle, &item.ProblemLink, &item.Difficulty, &item.Topic); err != nil {
-			log.Printf("[API] Error scanning history item: %v", err)
+			&item.ProblemTitle, &item.ProblemLink, &item.Difficulty, &item.Topic, &sortValue); err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning history item", "error", err)
 			continue
 		}
 		history = append(history, item)
+		sortValues = append(sortValues, sortValue)
 	}
 
-	if history == nil {
-		history = []RevisitHistoryItem{}
+	var next *listCursor
+	if page.paginated && len(history) > page.limit {
+		last := page.limit - 1
+		next = page.nextCursor(sortValues[last], history[last].ID)
+		history = history[:page.limit]
 	}
 
-	respondJSON(w, http.StatusOK, history)
+	setListHeaders(w, r, total, next)
+	respondJSONConditional(w, r, history)
 }
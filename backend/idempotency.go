@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+// Idempotent POSTs: a client that sends an Idempotency-Key header with a POST
+// gets the first response to that key replayed on every retry, so a request
+// repeated after a dropped connection creates nothing twice. Keys are scoped
+// to the user and remembered for idempotencyTTL.
+
+const (
+	idempotencyHeader      = "Idempotency-Key"
+	idempotencyReplayed    = "Idempotent-Replayed"
+	maxIdempotencyKeyLen   = 255
+	idempotencyTTL         = 24 * time.Hour
+	idempotencyLockTimeout = 5 * time.Minute // an unfinished request older than this was abandoned
+	idempotencyRetryAfter  = time.Second
+)
+
+// idempotencyRecord is what a store holds for one key. Status is 0 while
+// the first request is still running.
+type idempotencyRecord struct {
+	RequestHash string
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyStore remembers the response to each user's idempotency keys.
+type IdempotencyStore interface {
+	// Reserve claims key for a request with the given hash. When the key is
+	// already taken it returns the existing record and reserved is false.
+	Reserve(userID uuid.UUID, key, hash string) (existing idempotencyRecord, reserved bool, err error)
+	// Complete stores the response to a reserved key.
+	Complete(userID uuid.UUID, key string, rec idempotencyRecord) error
+	// Release frees a reserved key so the request can be retried.
+	Release(userID uuid.UUID, key string) error
+}
+
+var idempotencyStore IdempotencyStore = postgresIdempotencyStore{}
+
+// postgresIdempotencyStore keeps keys in the idempotency_keys table, so every
+// instance sees them.
+type postgresIdempotencyStore struct{}
+
+func (postgresIdempotencyStore) Reserve(userID uuid.UUID, key, hash string) (idempotencyRecord, bool, error) {
+	_, err := db.Exec(`
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+		  AND (created_at < NOW() - $3 * INTERVAL '1 second'
+		       OR (status IS NULL AND created_at < NOW() - $4 * INTERVAL '1 second'))`,
+		userID, key, idempotencyTTL.Seconds(), idempotencyLockTimeout.Seconds())
+	if err != nil {
+		return idempotencyRecord{}, false, err
+	}
+
+	res, err := db.Exec(`
+		INSERT INTO idempotency_keys (user_id, key, request_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, key) DO NOTHING`, userID, key, hash)
+	if err != nil {
+		return idempotencyRecord{}, false, err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return idempotencyRecord{}, true, nil
+	}
+
+	var rec idempotencyRecord
+	var status sql.NullInt64
+	err = db.QueryRow(`
+		SELECT request_hash, status, content_type, body
+		FROM idempotency_keys WHERE user_id = $1 AND key = $2`, userID, key).
+		Scan(&rec.RequestHash, &status, &rec.ContentType, &rec.Body)
+	if err == sql.ErrNoRows {
+		// The holder released the key in between; report it as still running
+		// so the client retries
+		return idempotencyRecord{RequestHash: hash}, false, nil
+	}
+	rec.Status = int(status.Int64)
+	return rec, false, err
+}
+
+func (postgresIdempotencyStore) Complete(userID uuid.UUID, key string, rec idempotencyRecord) error {
+	_, err := db.Exec(`
+		UPDATE idempotency_keys SET status = $3, content_type = $4, body = $5
+		WHERE user_id = $1 AND key = $2`, userID, key, rec.Status, rec.ContentType, rec.Body)
+	return err
+}
+
+func (postgresIdempotencyStore) Release(userID uuid.UUID, key string) error {
+	_, err := db.Exec("DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2", userID, key)
+	return err
+}
+
+// requestHash fingerprints the parts of a request a retry must repeat.
+func requestHash(method, target string, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, method+" "+target+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayable reports whether a response should answer retries. Server
+// errors and rate limiting are transient, so those requests may run again.
+func replayable(status int) bool {
+	return status < http.StatusInternalServerError && status != http.StatusTooManyRequests
+}
+
+// Idempotency replays the stored response to a POST that repeats an
+// Idempotency-Key. A key reused with a different request gets 422, and a
+// retry that arrives while the first request is still running gets 409.
+// It must run after authentication.
+func Idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			respondError(w, r, invalidField("header."+idempotencyHeader, "must be at most 255 characters"))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondError(w, r, invalidBody(err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID := GetUserIDFromContext(r)
+		hash := requestHash(r.Method, r.URL.RequestURI(), body)
+		existing, reserved, err := idempotencyStore.Reserve(userID, key, hash)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		if !reserved {
+			switch {
+			case existing.RequestHash != hash:
+				respondError(w, r, unprocessable("idempotency_key_reused",
+					"This Idempotency-Key was already used for a different request"))
+			case existing.Status == 0:
+				respondError(w, r, &APIError{Status: http.StatusConflict, Code: "idempotency_key_in_use",
+					Message: "A request with this Idempotency-Key is still in progress", RetryAfter: idempotencyRetryAfter})
+			default:
+				if existing.ContentType != "" {
+					w.Header().Set("Content-Type", existing.ContentType)
+				}
+				w.Header().Set(idempotencyReplayed, "true")
+				w.WriteHeader(existing.Status)
+				w.Write(existing.Body)
+			}
+			return
+		}
+
+		var captured bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&captured)
+		completed := false
+		defer func() {
+			// Free the key if the handler panicked or failed transiently
+			if !completed {
+				if err := idempotencyStore.Release(userID, key); err != nil {
+					slog.ErrorContext(r.Context(), "Error releasing idempotency key", "error", err)
+				}
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if !replayable(status) {
+			return
+		}
+		rec := idempotencyRecord{RequestHash: hash, Status: status,
+			ContentType: ww.Header().Get("Content-Type"), Body: captured.Bytes()}
+		if err := idempotencyStore.Complete(userID, key, rec); err != nil {
+			slog.ErrorContext(r.Context(), "Error storing idempotent response", "error", err)
+			return
+		}
+		completed = true
+	})
+}
+
+// PurgeExpiredIdempotencyKeys deletes keys older than idempotencyTTL and
+// returns how many were removed.
+func PurgeExpiredIdempotencyKeys() (int64, error) {
+	res, err := db.Exec("DELETE FROM idempotency_keys WHERE created_at < NOW() - $1 * INTERVAL '1 second'",
+		idempotencyTTL.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// RunPurgeIdempotencyKeysJob is the cron wrapper around PurgeExpiredIdempotencyKeys.
+func RunPurgeIdempotencyKeysJob() {
+	ctx := startJobRun(context.Background(), "purge-idempotency-keys")
+	purged, err := PurgeExpiredIdempotencyKeys()
+	if err != nil {
+		slog.ErrorContext(ctx, "Error purging idempotency keys", "error", err)
+		return
+	}
+	if purged > 0 {
+		slog.InfoContext(ctx, "Purged expired idempotency keys", "keys", purged)
+	}
+}
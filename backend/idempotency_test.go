@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// memoryIdempotencyStore is an IdempotencyStore for tests.
+type memoryIdempotencyStore struct {
+	mu   sync.Mutex
+	keys map[string]idempotencyRecord
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{keys: make(map[string]idempotencyRecord)}
+}
+
+func (s *memoryIdempotencyStore) Reserve(userID uuid.UUID, key, hash string) (idempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.keys[userID.String()+key]; ok {
+		return rec, false, nil
+	}
+	s.keys[userID.String()+key] = idempotencyRecord{RequestHash: hash}
+	return idempotencyRecord{}, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(userID uuid.UUID, key string, rec idempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[userID.String()+key] = rec
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(userID uuid.UUID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, userID.String()+key)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	previous := idempotencyStore
+	idempotencyStore = store
+	defer func() { idempotencyStore = previous }()
+
+	calls := 0
+	status := http.StatusCreated
+	handler := testAuth(uuid.New())(Idempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if status >= 500 {
+			respondError(w, r, &APIError{Status: status, Code: codeUnavailable, Message: "down"})
+			return
+		}
+		respondJSON(w, status, map[string]int{"call": calls})
+	})))
+	post := func(method, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/problems", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotencyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := post("POST", "k1", `{"title":"Two Sum"}`)
+	if first.Code != http.StatusCreated || first.Header().Get(idempotencyReplayed) != "" {
+		t.Fatalf("first request: %d %v", first.Code, first.Header())
+	}
+	retry := post("POST", "k1", `{"title":"Two Sum"}`)
+	if calls != 1 || retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry ran the handler or changed the response: calls=%d %d %q", calls, retry.Code, retry.Body)
+	}
+	if retry.Header().Get(idempotencyReplayed) != "true" || retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("replay headers: %v", retry.Header())
+	}
+	if rec := post("POST", "k1", `{"title":"Three Sum"}`); rec.Code != http.StatusUnprocessableEntity || calls != 1 {
+		t.Errorf("reused key with another body: %d, calls=%d", rec.Code, calls)
+	}
+
+	// A request still in flight holds its key
+	store.keys[uuid.Nil.String()+"busy"] = idempotencyRecord{RequestHash: requestHash("POST", "/api/problems", []byte(`{}`))}
+	req := httptest.NewRequest("POST", "/api/problems", strings.NewReader(`{}`))
+	req.Header.Set(idempotencyHeader, "busy")
+	busy := httptest.NewRecorder()
+	Idempotency(http.NotFoundHandler()).ServeHTTP(busy, req)
+	if busy.Code != http.StatusConflict || busy.Header().Get("Retry-After") == "" {
+		t.Errorf("in-flight key: %d %v", busy.Code, busy.Header())
+	}
+
+	// Server errors free the key so the retry runs again
+	status = http.StatusServiceUnavailable
+	post("POST", "k2", `{}`)
+	status = http.StatusCreated
+	if rec := post("POST", "k2", `{}`); rec.Code != http.StatusCreated || calls != 3 {
+		t.Errorf("retry after a server error: %d, calls=%d", rec.Code, calls)
+	}
+
+	// Requests without a key, and other methods, are not tracked
+	post("POST", "", `{}`)
+	post("POST", "", `{}`)
+	post("PUT", "k1", `{}`)
+	if calls != 6 {
+		t.Errorf("untracked requests: calls=%d, want 6", calls)
+	}
+	if rec := post("POST", strings.Repeat("k", 256), `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("overlong key: %d", rec.Code)
+	}
+}
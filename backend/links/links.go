@@ -0,0 +1,170 @@
+// Package links recognizes problem URLs from the coding platforms we support
+// and reduces them to a canonical form, so that the same problem added through
+// different URLs (trailing /description/, query strings, mirrors such as
+// leetcode.cn) can be detected as a duplicate.
+package links
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Platform names, used as the problem Source.
+const (
+	LeetCode      = "LeetCode"
+	NeetCode      = "NeetCode"
+	Codeforces    = "Codeforces"
+	AtCoder       = "AtCoder"
+	HackerRank    = "HackerRank"
+	GeeksforGeeks = "GeeksforGeeks"
+	InterviewBit  = "InterviewBit"
+)
+
+// Canonical identifies a problem on a platform.
+type Canonical struct {
+	Platform string // one of the platform constants
+	Key      string // platform-unique problem ID, e.g. "two-sum" or "1520/A"
+	URL      string // canonical problem URL
+}
+
+// Parse recognizes a problem URL. It returns false for URLs on unknown hosts
+// or paths that don't point at a single problem (e.g. a contest page).
+// Schemeless input such as "leetcode.com/problems/two-sum" is accepted.
+func Parse(raw string) (Canonical, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Canonical{}, false
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return Canonical{}, false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	host = strings.TrimPrefix(host, "www.")
+	segs := pathSegments(u.Path)
+
+	switch host {
+	case "leetcode.com", "leetcode.cn":
+		return parseLeetCode(segs)
+	case "neetcode.io":
+		return parseNeetCode(segs)
+	case "codeforces.com", "m1.codeforces.com", "m2.codeforces.com", "m3.codeforces.com":
+		return parseCodeforces(segs)
+	case "atcoder.jp":
+		return parseAtCoder(segs)
+	case "hackerrank.com":
+		return parseHackerRank(segs)
+	case "geeksforgeeks.org", "practice.geeksforgeeks.org":
+		return parseGeeksforGeeks(segs)
+	case "interviewbit.com":
+		return parseInterviewBit(segs)
+	}
+	return Canonical{}, false
+}
+
+// pathSegments splits a URL path into its non-empty segments.
+func pathSegments(path string) []string {
+	var segs []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	return segs
+}
+
+// after returns the segment following the first occurrence of name.
+func after(segs []string, name string) (string, bool) {
+	for i := 0; i < len(segs)-1; i++ {
+		if segs[i] == name {
+			return segs[i+1], true
+		}
+	}
+	return "", false
+}
+
+// leetcode.com/problems/two-sum/description/, leetcode.cn/problems/two-sum/,
+// leetcode.com/contest/weekly-contest-300/problems/two-sum/
+func parseLeetCode(segs []string) (Canonical, bool) {
+	slug, ok := after(segs, "problems")
+	if !ok {
+		return Canonical{}, false
+	}
+	slug = strings.ToLower(slug)
+	return Canonical{LeetCode, slug, "https://leetcode.com/problems/" + slug + "/"}, true
+}
+
+// neetcode.io/problems/two-integer-sum, neetcode.io/problems/two-integer-sum/question
+func parseNeetCode(segs []string) (Canonical, bool) {
+	slug, ok := after(segs, "problems")
+	if !ok {
+		return Canonical{}, false
+	}
+	slug = strings.ToLower(slug)
+	return Canonical{NeetCode, slug, "https://neetcode.io/problems/" + slug}, true
+}
+
+// codeforces.com/problemset/problem/1520/A, codeforces.com/contest/1520/problem/A,
+// codeforces.com/gym/102253/problem/C
+func parseCodeforces(segs []string) (Canonical, bool) {
+	switch {
+	case len(segs) >= 4 && segs[0] == "problemset" && segs[1] == "problem":
+		return codeforcesProblem(segs[2], segs[3])
+	case len(segs) >= 4 && segs[0] == "contest" && segs[2] == "problem":
+		return codeforcesProblem(segs[1], segs[3])
+	case len(segs) >= 4 && segs[0] == "gym" && segs[2] == "problem":
+		index := strings.ToUpper(segs[3])
+		return Canonical{Codeforces, "gym/" + segs[1] + "/" + index,
+			"https://codeforces.com/gym/" + segs[1] + "/problem/" + index}, true
+	}
+	return Canonical{}, false
+}
+
+func codeforcesProblem(contest, index string) (Canonical, bool) {
+	index = strings.ToUpper(index)
+	return Canonical{Codeforces, contest + "/" + index,
+		"https://codeforces.com/problemset/problem/" + contest + "/" + index}, true
+}
+
+// atcoder.jp/contests/abc300/tasks/abc300_a
+func parseAtCoder(segs []string) (Canonical, bool) {
+	if len(segs) < 4 || segs[0] != "contests" || segs[2] != "tasks" {
+		return Canonical{}, false
+	}
+	contest, task := strings.ToLower(segs[1]), strings.ToLower(segs[3])
+	return Canonical{AtCoder, task, "https://atcoder.jp/contests/" + contest + "/tasks/" + task}, true
+}
+
+// hackerrank.com/challenges/two-sum/problem, hackerrank.com/contests/x/challenges/two-sum
+func parseHackerRank(segs []string) (Canonical, bool) {
+	slug, ok := after(segs, "challenges")
+	if !ok {
+		return Canonical{}, false
+	}
+	slug = strings.ToLower(slug)
+	return Canonical{HackerRank, slug, "https://www.hackerrank.com/challenges/" + slug + "/problem"}, true
+}
+
+// geeksforgeeks.org/problems/key-pair5616/1, practice.geeksforgeeks.org/problems/key-pair5616/1
+func parseGeeksforGeeks(segs []string) (Canonical, bool) {
+	slug, ok := after(segs, "problems")
+	if !ok {
+		return Canonical{}, false
+	}
+	slug = strings.ToLower(slug)
+	return Canonical{GeeksforGeeks, slug, "https://www.geeksforgeeks.org/problems/" + slug + "/1"}, true
+}
+
+// interviewbit.com/problems/two-sum/
+func parseInterviewBit(segs []string) (Canonical, bool) {
+	slug, ok := after(segs, "problems")
+	if !ok {
+		return Canonical{}, false
+	}
+	slug = strings.ToLower(slug)
+	return Canonical{InterviewBit, slug, "https://www.interviewbit.com/problems/" + slug + "/"}, true
+}
@@ -0,0 +1,64 @@
+package links
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in       string
+		platform string
+		key      string
+	}{
+		{"https://leetcode.com/problems/two-sum/", LeetCode, "two-sum"},
+		{"https://leetcode.com/problems/two-sum/description/", LeetCode, "two-sum"},
+		{"https://leetcode.com/problems/Two-Sum?envType=study-plan", LeetCode, "two-sum"},
+		{"leetcode.com/problems/two-sum", LeetCode, "two-sum"},
+		{"https://leetcode.cn/problems/two-sum/solutions/", LeetCode, "two-sum"},
+		{"https://leetcode.com/contest/weekly-contest-300/problems/decode-the-message/", LeetCode, "decode-the-message"},
+		{"https://neetcode.io/problems/two-integer-sum/question", NeetCode, "two-integer-sum"},
+		{"https://codeforces.com/problemset/problem/1520/a", Codeforces, "1520/A"},
+		{"https://codeforces.com/contest/1520/problem/A", Codeforces, "1520/A"},
+		{"https://codeforces.com/gym/102253/problem/C", Codeforces, "gym/102253/C"},
+		{"https://atcoder.jp/contests/abc300/tasks/abc300_a", AtCoder, "abc300_a"},
+		{"https://www.hackerrank.com/challenges/ctci-array-left-rotation/problem", HackerRank, "ctci-array-left-rotation"},
+		{"https://www.hackerrank.com/contests/x/challenges/ctci-array-left-rotation", HackerRank, "ctci-array-left-rotation"},
+		{"https://practice.geeksforgeeks.org/problems/key-pair5616/1", GeeksforGeeks, "key-pair5616"},
+		{"https://www.geeksforgeeks.org/problems/key-pair5616/0", GeeksforGeeks, "key-pair5616"},
+		{"https://www.interviewbit.com/problems/2-sum/", InterviewBit, "2-sum"},
+	}
+	for _, c := range cases {
+		got, ok := Parse(c.in)
+		if !ok {
+			t.Errorf("Parse(%q) not recognized", c.in)
+			continue
+		}
+		if got.Platform != c.platform || got.Key != c.key {
+			t.Errorf("Parse(%q) = %s %q, want %s %q", c.in, got.Platform, got.Key, c.platform, c.key)
+		}
+	}
+}
+
+func TestParseSameProblemSameURL(t *testing.T) {
+	a, _ := Parse("https://leetcode.com/problems/two-sum/description/")
+	b, _ := Parse("https://leetcode.cn/problems/two-sum/")
+	if a != b {
+		t.Errorf("mirror URLs differ: %+v vs %+v", a, b)
+	}
+	if a.URL != "https://leetcode.com/problems/two-sum/" {
+		t.Errorf("canonical URL = %q", a.URL)
+	}
+}
+
+func TestParseUnrecognized(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"not a url",
+		"https://example.com/problems/two-sum",
+		"https://leetcode.com/problemset/",
+		"https://codeforces.com/contest/1520",
+		"https://atcoder.jp/contests/abc300",
+	} {
+		if c, ok := Parse(in); ok {
+			t.Errorf("Parse(%q) = %+v, want unrecognized", in, c)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Defaults for cursor pagination on list endpoints.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// sqlFilter accumulates WHERE clauses and their positional arguments.
+type sqlFilter struct {
+	clauses []string
+	args    []interface{}
+}
+
+// arg registers a query argument and returns its placeholder.
+func (f *sqlFilter) arg(v interface{}) string {
+	f.args = append(f.args, v)
+	return fmt.Sprintf("$%d", len(f.args))
+}
+
+// where adds a clause; use arg() to build its placeholders.
+func (f *sqlFilter) where(clause string) {
+	f.clauses = append(f.clauses, clause)
+}
+
+// sql returns the clauses joined with AND.
+func (f *sqlFilter) sql() string {
+	return strings.Join(f.clauses, " AND ")
+}
+
+// sortField describes a sortable column. An empty expr means the value is
+// computed in Go (e.g. scheduling weight) rather than by Postgres.
+type sortField struct {
+	expr string // SQL expression to order by
+	cast string // Postgres type of the values: timestamptz, int, float8 or text
+}
+
+// pgTimestampLayouts match timestamptz values printed as text by Postgres.
+var pgTimestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999-07:00:00",
+}
+
+// validValue reports whether v, a cursor's sort value, is of the field's type,
+// so a tampered cursor is refused before Postgres has to cast it.
+func (sf sortField) validValue(v string) bool {
+	switch sf.cast {
+	case "timestamptz":
+		for _, layout := range pgTimestampLayouts {
+			if _, err := time.Parse(layout, v); err == nil {
+				return true
+			}
+		}
+		return false
+	case "int":
+		_, err := strconv.ParseInt(v, 10, 32)
+		return err == nil
+	case "float8":
+		_, err := strconv.ParseFloat(v, 64)
+		return err == nil
+	}
+	return true
+}
+
+// pageRequest holds the parsed sort, cursor and limit for a list request.
+// Pagination is opt-in: without ?limit or ?cursor the full list is returned,
+// so existing clients keep working.
+type pageRequest struct {
+	sortName  string
+	sort      sortField
+	desc      bool
+	paginated bool
+	limit     int
+	cursor    *listCursor
+}
+
+// listCursor is the position after the last row of a page: the sort it was
+// made for, the sort value and the row ID used as a tie-breaker. It is opaque
+// to clients.
+type listCursor struct {
+	Sort  string    `json:"s"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+func encodeCursor(c listCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*listCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c listCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// parsePageRequest reads ?sort=, ?limit= and ?cursor=. Sort is a field name,
+// prefixed with "-" for descending order. A cursor is only valid for the sort
+// it was made for.
+func parsePageRequest(q url.Values, fields map[string]sortField, defaultSort string) (pageRequest, error) {
+	var pr pageRequest
+
+	sortParam := q.Get("sort")
+	if sortParam == "" {
+		sortParam = defaultSort
+	}
+	pr.desc = strings.HasPrefix(sortParam, "-")
+	pr.sortName = strings.TrimPrefix(sortParam, "-")
+	field, ok := fields[pr.sortName]
+	if !ok {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return pr, invalidField("query.sort", "must be one of: "+strings.Join(names, ", "))
+	}
+	pr.sort = field
+
+	pr.limit = defaultPageSize
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return pr, invalidField("query.limit", fmt.Sprintf("must be between 1 and %d", maxPageSize))
+		}
+		pr.limit = n
+		pr.paginated = true
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil {
+			return pr, invalidField("query.cursor", "is not a valid cursor")
+		}
+		if c.Sort != sortParam || !field.validValue(c.Value) {
+			return pr, invalidField("query.cursor", "does not belong to this sort")
+		}
+		pr.cursor = c
+		pr.paginated = true
+	}
+
+	return pr, nil
+}
+
+// nextCursor is the cursor for the page after the row with the given sort
+// value and ID.
+func (pr pageRequest) nextCursor(value string, id uuid.UUID) *listCursor {
+	sortParam := pr.sortName
+	if pr.desc {
+		sortParam = "-" + sortParam
+	}
+	return &listCursor{Sort: sortParam, Value: value, ID: id}
+}
+
+// direction returns the SQL sort direction.
+func (pr pageRequest) direction() string {
+	if pr.desc {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// applyKeyset restricts a SQL query to rows after the cursor.
+func (pr pageRequest) applyKeyset(f *sqlFilter, idColumn string) {
+	if pr.cursor == nil || pr.sort.expr == "" {
+		return
+	}
+	op := ">"
+	if pr.desc {
+		op = "<"
+	}
+	f.where(fmt.Sprintf("(%s, %s) %s (%s::%s, %s::uuid)",
+		pr.sort.expr, idColumn, op, f.arg(pr.cursor.Value), pr.sort.cast, f.arg(pr.cursor.ID)))
+}
+
+// orderAndLimit returns the ORDER BY/LIMIT tail for a SQL-sorted query. One
+// extra row is fetched to know whether there is a next page.
+func (pr pageRequest) orderAndLimit(idColumn string) string {
+	tail := fmt.Sprintf(" ORDER BY %s %s, %s %s", pr.sort.expr, pr.direction(), idColumn, pr.direction())
+	if pr.paginated {
+		tail += fmt.Sprintf(" LIMIT %d", pr.limit+1)
+	}
+	return tail
+}
+
+// setListHeaders writes X-Total-Count and, if there is another page, a Link
+// header with rel="next" pointing at the same request with the next cursor.
+func setListHeaders(w http.ResponseWriter, r *http.Request, total int, next *listCursor) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if next == nil {
+		return
+	}
+	q := r.URL.Query()
+	q.Set("cursor", encodeCursor(*next))
+	if q.Get("limit") == "" {
+		q.Set("limit", strconv.Itoa(defaultPageSize))
+	}
+	w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, q.Encode()))
+}
+
+// parseDateFilter parses a YYYY-MM-DD date (in server local time) or an
+// RFC 3339 timestamp, reporting which form was given.
+func parseDateFilter(v string) (t time.Time, dateOnly bool, err error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, false, nil
+	}
+	t, err = time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return t, false, fmt.Errorf("invalid date %q (want YYYY-MM-DD or RFC 3339)", v)
+	}
+	return t, true, nil
+}
+
+// addDateRange adds inclusive from/to filters on a timestamp column. A bare
+// "to" date covers that whole day, so to=2025-01-31 includes January 31st.
+func addDateRange(f *sqlFilter, column, from, to string) error {
+	if from != "" {
+		t, _, err := parseDateFilter(from)
+		if err != nil {
+			return err
+		}
+		f.where(fmt.Sprintf("%s >= %s", column, f.arg(t)))
+	}
+	if to != "" {
+		t, dateOnly, err := parseDateFilter(to)
+		if err != nil {
+			return err
+		}
+		if dateOnly {
+			f.where(fmt.Sprintf("%s < %s", column, f.arg(t.AddDate(0, 0, 1))))
+		} else {
+			f.where(fmt.Sprintf("%s <= %s", column, f.arg(t)))
+		}
+	}
+	return nil
+}
+
+// addListFilter adds a case-insensitive "column IN (...)" filter from a
+// comma-separated query value such as ?difficulty=Easy,Medium.
+func addListFilter(f *sqlFilter, column, value string) {
+	if value == "" {
+		return
+	}
+	var placeholders []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			placeholders = append(placeholders, f.arg(strings.ToLower(v)))
+		}
+	}
+	if len(placeholders) > 0 {
+		f.where(fmt.Sprintf("LOWER(%s) IN (%s)", column, strings.Join(placeholders, ", ")))
+	}
+}
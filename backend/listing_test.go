@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := listCursor{Sort: "-date_added", Value: "2025-01-02 10:00:00+00", ID: uuid.New()}
+	got, err := decodeCursor(encodeCursor(c))
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if *got != c {
+		t.Errorf("got %+v, want %+v", *got, c)
+	}
+	if _, err := decodeCursor("not a cursor"); err == nil {
+		t.Error("expected error for garbage cursor")
+	}
+}
+
+func TestParsePageRequest(t *testing.T) {
+	pr, err := parsePageRequest(url.Values{}, problemSortFields, "-date_added")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.sortName != "date_added" || !pr.desc || pr.paginated {
+		t.Errorf("default request = %+v, want unpaginated date_added desc", pr)
+	}
+
+	pr, err = parsePageRequest(url.Values{"sort": {"title"}, "limit": {"10"}}, problemSortFields, "-date_added")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.sortName != "title" || pr.desc || !pr.paginated || pr.limit != 10 {
+		t.Errorf("got %+v, want paginated title asc with limit 10", pr)
+	}
+
+	for _, q := range []url.Values{
+		{"sort": {"bogus"}},
+		{"limit": {"0"}},
+		{"limit": {"1000"}},
+		{"cursor": {"%%%"}},
+	} {
+		if _, err := parsePageRequest(q, problemSortFields, "-date_added"); err == nil {
+			t.Errorf("expected error for %v", q)
+		}
+	}
+}
+
+func TestParsePageRequestCursor(t *testing.T) {
+	parse := func(sort string, c listCursor) error {
+		_, err := parsePageRequest(url.Values{"sort": {sort}, "cursor": {encodeCursor(c)}}, problemSortFields, "-date_added")
+		return err
+	}
+	id := uuid.New()
+
+	valid := map[string]string{
+		"-date_added":     "2025-01-02 10:00:00.123456+00",
+		"last_revisited":  "1970-01-01 00:00:00+05:30",
+		"times_revisited": "3",
+		"title":           "two sum",
+		"-weight":         "1.75",
+	}
+	for sort, value := range valid {
+		if err := parse(sort, listCursor{Sort: sort, Value: value, ID: id}); err != nil {
+			t.Errorf("cursor for %s rejected: %v", sort, err)
+		}
+	}
+
+	// A title cursor replayed on a date sort, or on the other direction
+	title := listCursor{Sort: "title", Value: "two sum", ID: id}
+	for _, sort := range []string{"date_added", "last_revisited", "-title"} {
+		if err := parse(sort, title); !isCursorError(err) {
+			t.Errorf("title cursor on sort=%s: %v, want a query.cursor error", sort, err)
+		}
+	}
+
+	// Tampered values and garbage
+	for sort, value := range map[string]string{"date_added": "two sum", "times_revisited": "3.5", "-weight": "heavy"} {
+		if err := parse(sort, listCursor{Sort: sort, Value: value, ID: id}); !isCursorError(err) {
+			t.Errorf("value %q on sort=%s: %v, want a query.cursor error", value, sort, err)
+		}
+	}
+	_, err := parsePageRequest(url.Values{"cursor": {"bm90IGpzb24"}}, problemSortFields, "-date_added")
+	if !isCursorError(err) {
+		t.Errorf("garbage cursor: %v, want a query.cursor error", err)
+	}
+}
+
+func isCursorError(err error) bool {
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Status != http.StatusBadRequest {
+		return false
+	}
+	fields, _ := apiErr.Details.([]FieldError)
+	return len(fields) == 1 && fields[0].Field == "query.cursor"
+}
+
+func TestAddDateRangeInclusiveDay(t *testing.T) {
+	f := &sqlFilter{}
+	if err := addDateRange(f, "date_added", "2025-01-01", "2025-01-31"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.sql(); got != "date_added >= $1 AND date_added < $2" {
+		t.Errorf("sql = %q", got)
+	}
+	if err := addDateRange(&sqlFilter{}, "date_added", "yesterday", ""); err == nil {
+		t.Error("expected error for invalid date")
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+// Logging: every log line is a structured log/slog record, JSON unless
+// LOG_FORMAT=text. Records logged with a context carry the request ID, the
+// authenticated user's ID and, in background jobs, the job name and run ID.
+// Email addresses and credentials are redacted before anything is written.
+
+const (
+	requestLogKey contextKey = "requestLog"
+	jobRunKey     contextKey = "jobRun"
+)
+
+// SetupLogging installs the default logger as configured by LOG_LEVEL
+// (debug, info, warn or error; default info) and LOG_FORMAT (json or text).
+// Output of the standard log package goes through it as well.
+func SetupLogging() {
+	level, levelErr := parseLogLevel(os.Getenv("LOG_LEVEL"))
+	format := strings.ToLower(os.Getenv("LOG_FORMAT"))
+	slog.SetDefault(slog.New(newLogHandler(os.Stderr, format, level)))
+	if levelErr != nil {
+		slog.Warn("Invalid LOG_LEVEL, using info", "value", os.Getenv("LOG_LEVEL"))
+	}
+	if format != "" && format != "json" && format != "text" {
+		slog.Warn("Invalid LOG_FORMAT, using json", "value", format)
+	}
+}
+
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo, err
+	}
+	return level, nil
+}
+
+// newLogHandler builds the handler behind the default logger.
+func newLogHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	if format == "text" {
+		return contextHandler{slog.NewTextHandler(w, opts)}
+	}
+	return contextHandler{slog.NewJSONHandler(w, opts)}
+}
+
+// contextHandler adds the request and job attributes found in the context
+// of each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if ctx != nil {
+		if id := middleware.GetReqID(ctx); id != "" {
+			rec.AddAttrs(slog.String("request_id", id))
+		}
+		if userID := logUserID(ctx); userID != uuid.Nil {
+			rec.AddAttrs(slog.String("user_id", userID.String()))
+		}
+		if run, ok := ctx.Value(jobRunKey).(jobRun); ok {
+			rec.AddAttrs(slog.String("job", run.Name), slog.String("job_run_id", run.ID))
+		}
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// ---------- Redaction ----------
+
+const redacted = "[REDACTED]"
+
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	bearerPattern = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=-]+`)
+	jwtPattern    = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+)
+
+// sensitiveLogKey reports attributes whose values are never logged.
+func sensitiveLogKey(key string) bool {
+	key = strings.ToLower(key)
+	if key == "email" || key == "to" {
+		return true
+	}
+	for _, s := range []string{"token", "password", "secret", "authorization", "cookie"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString hides email addresses, bearer credentials and JWTs in s.
+func redactString(s string) string {
+	s = bearerPattern.ReplaceAllString(s, "Bearer "+redacted)
+	s = jwtPattern.ReplaceAllString(s, redacted)
+	return emailPattern.ReplaceAllString(s, "[EMAIL]")
+}
+
+// redactAttr is the ReplaceAttr hook of the log handlers. It also sees the
+// message, so text logged through the standard log package is covered.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if sensitiveLogKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(redactString(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(redactString(err.Error()))
+		}
+	}
+	return a
+}
+
+// ---------- Requests ----------
+
+// requestLog collects what later middleware learns about a request for its
+// access log line.
+type requestLog struct {
+	userID uuid.UUID
+}
+
+// logUserID is the authenticated user of the request in ctx, if any.
+func logUserID(ctx context.Context) uuid.UUID {
+	if id, ok := ctx.Value(userIDKey).(uuid.UUID); ok {
+		return id
+	}
+	if state, ok := ctx.Value(requestLogKey).(*requestLog); ok {
+		return state.userID
+	}
+	return uuid.Nil
+}
+
+// setLogUser records the authenticated user for the access log line of the
+// request in ctx.
+func setLogUser(ctx context.Context, userID uuid.UUID) {
+	if state, ok := ctx.Value(requestLogKey).(*requestLog); ok {
+		state.userID = userID
+	}
+}
+
+// routePattern is the chi route that matched r, e.g. "/api/problems/{id}".
+// Logs name it instead of the path, so feed tokens in URLs stay out of them.
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return rctx.RoutePattern()
+	}
+	return "unmatched"
+}
+
+// RequestLogger writes one record per request, replacing chi's text logger.
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r = r.WithContext(context.WithValue(r.Context(), requestLogKey, &requestLog{}))
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			slog.LogAttrs(r.Context(), level, "Request",
+				slog.String("method", r.Method),
+				slog.String("route", routePattern(r)),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)))
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// ---------- Jobs ----------
+
+// jobRun identifies one run of a background job.
+type jobRun struct {
+	Name string
+	ID   string
+}
+
+// startJobRun returns a context for one run of the named job; records logged
+// with it carry the job name and a fresh run ID.
+func startJobRun(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, jobRunKey, jobRun{Name: name, ID: uuid.NewString()})
+}
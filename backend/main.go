@@ -1,8 +1,9 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 
@@ -14,38 +15,99 @@ import (
 
 func main() {
 	// Load environment variables from .env file (local development)
-	if err := godotenv.Load("../.env"); err != nil {
-		log.Println("No .env file found or unable to load")
+	envErr := godotenv.Load("../.env")
+	SetupLogging()
+	if envErr != nil {
+		slog.Info("No .env file found or unable to load")
 	}
 
 	// CLI Flags
-	jobFlag := flag.String("job", "", "Run a specific background job (e.g. 'daily') and exit")
+	jobFlag := flag.String("job", "", "Run a specific background job ('daily', 'reconcile', 'purge-trash' or 'simulate') and exit")
 	forceFlag := flag.Bool("force", false, "Force the daily job even if already sent today")
+
+	// Simulation flags (used with -job simulate)
+	simUser := flag.String("user", "", "Simulate: replay this user's problems and history (UUID or email)")
+	simSynthetic := flag.Int("synthetic", 0, "Simulate: generate N synthetic problems instead of loading a user")
+	simDays := flag.Int("days", 0, "Simulate: number of days to simulate (default: history span, or 90 for synthetic)")
+	simStrategies := flag.String("strategies", "weighted", "Simulate: ';'-separated strategies, e.g. 'weighted;weighted:decay=0.5;lru'")
+	simFormat := flag.String("format", "json", "Simulate: output format (json, csv, csv-problems)")
+	simSeed := flag.Int64("seed", 1, "Simulate: random seed")
+	simCompletion := flag.Float64("completion", 1.0, "Simulate: probability that a selected problem is revisited")
+	simOut := flag.String("out", "", "Simulate: write output to this file instead of stdout")
 	flag.Parse()
 
+	// The simulation only needs the database when replaying a real user
+	if *jobFlag == "simulate" {
+		err := RunSimulateJob(SimulationOptions{
+			User:       *simUser,
+			Synthetic:  *simSynthetic,
+			Days:       *simDays,
+			Strategies: *simStrategies,
+			Format:     *simFormat,
+			Seed:       *simSeed,
+			Completion: *simCompletion,
+			Out:        *simOut,
+		})
+		if err != nil {
+			slog.Error("Simulation failed", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Initialize Database
 	InitDB()
 
 	// If job flag is set, run the job and exit
 	if *jobFlag != "" {
-		if *jobFlag == "daily" {
-			log.Printf("[Main] Running scheduled job: daily (force=%v)", *forceFlag)
+		slog.Info("Running job", "job", *jobFlag, "force", *forceFlag)
+		switch *jobFlag {
+		case "daily":
 			RunDailyJob(*forceFlag)
-			log.Println("[Main] Job completed. Exiting.")
-			os.Exit(0)
-		} else {
-			log.Fatalf("[Main] Unknown job: %s", *jobFlag)
+		case "reconcile":
+			RunReconcileJob()
+		case "purge-trash":
+			RunPurgeTrashJob()
+		default:
+			slog.Error("Unknown job", "job", *jobFlag)
+			os.Exit(1)
 		}
+		slog.Info("Job completed, exiting", "job", *jobFlag)
+		os.Exit(0)
 	}
 
+	// Share rate limits between instances if configured
+	ConfigureRateLimits()
+
 	// Start Cron Job (Background ticker)
 	StartCron()
 
+	// Relay live events published by other instances and jobs
+	go ListenForEvents(context.Background())
+
 	// Initialize Router
+	r := newRouter(ClerkAuthMiddleware)
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	slog.Info("Server starting", "port", port)
+	if err := http.ListenAndServe(":"+port, r); err != nil {
+		slog.Error("Server failed to start", "error", err)
+		os.Exit(1)
+	}
+}
+
+// newRouter builds the HTTP router. authenticate guards every route that
+// isn't public (tests substitute their own).
+func newRouter(authenticate func(http.Handler) http.Handler) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Middleware
-	r.Use(middleware.Logger)
+	r.Use(middleware.RequestID)
+	r.Use(RequestLogger)
 	r.Use(middleware.Recoverer)
 
 	frontendURL := os.Getenv("FRONTEND_URL")
@@ -56,51 +118,116 @@ func main() {
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   allowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match", "Idempotency-Key"},
+		ExposedHeaders:   []string{"Link", "X-Total-Count", "ETag", "Idempotent-Replayed", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
 	// Routes
 	r.Route("/api", func(r chi.Router) {
-		// Public: health check
+		r.Use(LimitRequestBody(maxRequestBodyBytes))
+		r.Use(RateLimitByIP(rateLimits.IP))
+
+		// Public: health check and the API description
 		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte("OK"))
 		})
+		r.Get("/openapi.json", ServeOpenAPI)
+
+		// Public: feeds authorized by the secret token in their URL
+		r.Group(func(r chi.Router) {
+			r.Use(ValidateRequest)
+
+			r.Get("/feeds/calendar/{token}.ics", GetCalendarFeed)
+			r.Get("/feeds/focus/{token}.atom", GetFocusFeed)
+			r.Get("/feeds/journal/{token}.atom", GetJournalFeed)
+			r.Get("/feeds/journal/public/{userID}.atom", GetPublicJournalFeed)
+		})
 
-		// Protected: all other routes require Clerk authentication
+		// Protected: all other routes require authentication, and their
+		// requests must match the OpenAPI document. POSTs with an
+		// Idempotency-Key are replayed rather than repeated.
 		r.Group(func(r chi.Router) {
-			r.Use(ClerkAuthMiddleware)
+			r.Use(authenticate)
+			r.Use(RateLimitByUser(rateLimits.User))
+			r.Use(ValidateRequest)
+			r.Use(Idempotency)
+
+			r.Get("/events", StreamEvents)
 
 			r.Get("/problems", GetProblems)
 			r.Get("/problems/today", GetTodaysFocus)
+			r.Get("/plans", GetPlanHistory)
+			r.Get("/problems/forecast", GetForecast)
+			r.Get("/search", SearchProblems)
 			r.Get("/history", GetRevisitHistory)
+			r.Put("/history/{id}", UpdateRevisit)
+			r.Delete("/history/{id}", DeleteRevisit)
 			r.Get("/problems/weights", GetAllWeights)
 			r.Get("/problems/{id}", GetProblemByID)
 			r.Get("/problems/{id}/weight", GetProblemWeight)
 			r.Post("/problems", CreateProblem)
 			r.Put("/problems/{id}", UpdateProblem)
+			r.Patch("/problems/{id}", PatchProblem)
 			r.Delete("/problems/{id}", DeleteProblem)
 			r.Post("/problems/{id}/revisit", MarkRevisited)
 			r.Post("/problems/{id}/archive", ArchiveProblem)
+			r.Post("/problems/{id}/unarchive", UnarchiveProblem)
+			r.Post("/problems/{id}/restore", RestoreProblem)
+			r.Put("/problems/{id}/overrides", UpdateProblemOverrides)
+			r.Post("/problems/{id}/focus", FocusProblemNow)
+			r.Get("/problems/{id}/solutions", GetSolutions)
+			r.Post("/problems/{id}/solutions", CreateSolution)
+			r.Get("/problems/{id}/solutions/diff", GetSolutionDiff)
+			r.Get("/problems/{id}/solutions/{version}", GetSolution)
+			r.Get("/problems/{id}/flashcards", GetProblemFlashcards)
+			r.Post("/problems/{id}/flashcards", CreateFlashcard)
+
+			// Curated lists
+			r.Get("/catalog/lists", GetCatalogLists)
+			r.Get("/catalog/lists/{slug}", GetCatalogList)
+			r.Post("/catalog/lists/{slug}/add", AddCatalogList)
+			r.Get("/catalog/lists/{slug}/progress", GetCatalogListProgress)
+
+			r.Get("/collections", GetCollections)
+			r.Post("/collections", CreateCollection)
+			r.Get("/collections/{id}", GetCollection)
+			r.Put("/collections/{id}", UpdateCollection)
+			r.Delete("/collections/{id}", DeleteCollection)
+			r.Get("/collections/{id}/progress", GetCollectionProgress)
+			r.Put("/focus-scope", SetFocusScope)
+
+			r.Get("/goal", GetGoal)
+			r.Put("/goal", SetGoal)
+			r.Delete("/goal", DeleteGoal)
+			r.Get("/goal/progress", GetGoalProgress)
+
+			r.Get("/flashcards/queue", GetFlashcardQueue)
+			r.Get("/flashcards/drill", GetFlashcardDrill)
+			r.Put("/flashcards/{id}", UpdateFlashcard)
+			r.Delete("/flashcards/{id}", DeleteFlashcard)
+			r.Post("/flashcards/{id}/review", ReviewFlashcard)
+
+			r.With(RateLimitByUser(rateLimits.Export)).Get("/export/anki.apkg", ExportAnkiPackage)
+			r.With(RateLimitByUser(rateLimits.Export)).Get("/export/anki.tsv", ExportAnkiTSV)
+
+			// Trash
+			r.Get("/trash", GetTrash)
+			r.Delete("/trash/{id}", PurgeProblem)
 			// Settings
 			r.Get("/settings", GetSettings)
 			r.Put("/settings", UpdateSettings)
+			r.Get("/settings/feeds", GetFeedTokens)
+			r.Post("/settings/feeds/{kind}", RotateFeedToken)
+			r.Delete("/settings/feeds/{kind}", RevokeFeedToken)
+			r.Put("/settings/feeds/journal/public", SetPublicJournal)
 			// Testing / Debugging
-			r.Post("/test-email", TestEmail)
-			r.Post("/admin/run-cron", RunCronAllUsers)
+			r.With(RateLimitByUser(rateLimits.Email)).Post("/test-email", TestEmail)
+			r.With(RateLimitByUser(rateLimits.Email)).Post("/admin/run-cron", RunCronAllUsers)
 		})
 	})
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	log.Printf("Server starting on port %s", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
-	}
+	return r
 }
@@ -53,6 +53,12 @@ type UserPreferences struct {
 	EmailTime       string `json:"email_time"`
 	SkipWeekends    bool   `json:"skip_weekends"`
 	AIEncouragement bool   `json:"ai_encouragement"`
+
+	// Restricts Today's Focus to one of the user's collections (null = all problems)
+	FocusCollectionID uuid.NullUUID `json:"focus_collection_id"`
+
+	// Publishes the revisit journal as a public Atom feed
+	PublicJournal bool `json:"public_journal"`
 }
 
 // Value implements driver.Valuer for JSONB
@@ -86,28 +92,26 @@ type Problem struct {
 	DateAdded       time.Time `json:"date_added"`
 	LastRevisitedAt NullTime  `json:"last_revisited_at"`
 	TimesRevisited  int       `json:"times_revisited"`
-	Status          string    `json:"status"` // active, retired
+	Status          string    `json:"status"` // active, retired, trashed
 	Topic           string    `json:"topic,omitempty"`
 	Difficulty      string    `json:"difficulty,omitempty"`
 	Source          string    `json:"source,omitempty"`
 	Notes           string    `json:"notes,omitempty"`
+
+	// Per-problem scheduling overrides
+	SnoozedUntil       NullTime `json:"snoozed_until"`       // not scheduled before this time; cleared by pinning
+	HoldUntil          NullTime `json:"hold_until"`          // never scheduled before this time, even when pinned
+	Pinned             bool     `json:"pinned"`              // always included in the daily focus until revisited
+	PriorityMultiplier float64  `json:"priority_multiplier"` // scales the scheduling weight (1.0 = neutral)
+
+	CatalogProblemID uuid.NullUUID `json:"catalog_problem_id"` // matching shared catalog entry, if any
+	Version          int           `json:"version"`            // bumped on every change; the problem's ETag
 }
 
 // ProblemDetail is the response for the problem detail endpoint, includes revisit history
 type ProblemDetail struct {
-	ID              uuid.UUID      `json:"id"`
-	UserID          uuid.UUID      `json:"user_id"`
-	Title           string         `json:"title"`
-	Link            string         `json:"link"`
-	DateAdded       time.Time      `json:"date_added"`
-	LastRevisitedAt NullTime       `json:"last_revisited_at"`
-	TimesRevisited  int            `json:"times_revisited"`
-	Status          string         `json:"status"`
-	Topic           string         `json:"topic,omitempty"`
-	Difficulty      string         `json:"difficulty,omitempty"`
-	Source          string         `json:"source,omitempty"`
-	Notes           string         `json:"notes,omitempty"`
-	RevisitedToday  bool           `json:"revisited_today"`
-	RevisitHistory  []RevisitEntry `json:"revisit_history"`
-	WeightInfo      ProblemWeight  `json:"weight_info"`
+	Problem
+	RevisitedToday bool           `json:"revisited_today"`
+	RevisitHistory []RevisitEntry `json:"revisit_history"`
+	WeightInfo     ProblemWeight  `json:"weight_info"`
 }
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"dsa-revisit/openapi"
+)
+
+// ServeOpenAPI serves the API's OpenAPI document.
+func ServeOpenAPI(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, apiSpec)
+}
+
+// ValidateRequest rejects requests whose parameters or JSON body don't match
+// the OpenAPI document with 400 and a list of the offending fields. Requests
+// for undocumented routes are passed through for the router to reject.
+func ValidateRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, params, ok := apiSpec.FindOperation(r.Method, strings.TrimPrefix(r.URL.Path, apiBasePath))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		err := apiSpec.ValidateRequest(r, op, params)
+		var validationErr *openapi.ValidationError
+		if errors.As(err, &validationErr) {
+			respondError(w, r, invalid(validationErr.Error(), validationErr.Errors...))
+			return
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), "Error reading request body", "error", err)
+			respondError(w, r, invalidBody(err))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
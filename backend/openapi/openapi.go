@@ -0,0 +1,377 @@
+// Package openapi models the subset of OpenAPI 3.1 the API describes itself
+// with, and validates requests and responses against it. Schemas support the
+// JSON Schema keywords the API uses: type (including "null"), format, enum,
+// properties, required, items, additionalProperties, allOf, oneOf, $ref to
+// components and string, number and array bounds.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+}
+
+// Info is the document's metadata.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a base URL the paths are relative to.
+type Server struct {
+	URL string `json:"url"`
+}
+
+// SecurityRequirement maps security scheme names to scopes.
+type SecurityRequirement map[string][]string
+
+// PathItem maps lower-case HTTP methods to operations.
+type PathItem map[string]*Operation
+
+// Components holds the reusable schemas and security schemes.
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how clients authenticate.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Operation is one method on one path. A non-nil, empty Security makes the
+// operation public.
+type Operation struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Security    *[]SecurityRequirement `json:"security,omitempty"`
+	Parameters  []*Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody           `json:"requestBody,omitempty"`
+	Responses   map[string]*Response   `json:"responses"`
+}
+
+// Parameter is a path, query or header parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the accepted request bodies by media type.
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+// Response describes a response status.
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// Header describes a response header.
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType holds the schema of one content type. Schema is nil for
+// non-JSON bodies that aren't validated.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Schema is a JSON Schema (2020-12 subset).
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 Types              `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// Types is a schema's type keyword: one name, or several when a value may
+// also be null.
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = Types{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*t = many
+	return nil
+}
+
+// ---------- Schema constructors ----------
+
+// Ref refers to a schema in components.
+func Ref(name string) *Schema { return &Schema{Ref: "#/components/schemas/" + name} }
+
+// String returns a string schema with an optional format.
+func String(format ...string) *Schema {
+	s := &Schema{Type: Types{"string"}}
+	if len(format) > 0 {
+		s.Format = format[0]
+	}
+	return s
+}
+
+// Enum returns a string schema restricted to values.
+func Enum(values ...string) *Schema {
+	s := String()
+	for _, v := range values {
+		s.Enum = append(s.Enum, v)
+	}
+	return s
+}
+
+// Integer returns an integer schema.
+func Integer() *Schema { return &Schema{Type: Types{"integer"}} }
+
+// Number returns a number schema.
+func Number() *Schema { return &Schema{Type: Types{"number"}} }
+
+// Boolean returns a boolean schema.
+func Boolean() *Schema { return &Schema{Type: Types{"boolean"}} }
+
+// Array returns an array schema of items.
+func Array(items *Schema) *Schema { return &Schema{Type: Types{"array"}, Items: items} }
+
+// Object returns an object schema with the given properties, of which
+// required must be present.
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	sort.Strings(required)
+	return &Schema{Type: Types{"object"}, Properties: properties, Required: required}
+}
+
+// Map returns an object schema whose values all match values.
+func Map(values *Schema) *Schema { return &Schema{Type: Types{"object"}, AdditionalProperties: values} }
+
+// Nullable allows null in addition to s.
+func Nullable(s *Schema) *Schema {
+	if s.Ref != "" || len(s.Type) == 0 {
+		return &Schema{OneOf: []*Schema{s, {Type: Types{"null"}}}}
+	}
+	c := *s
+	c.Type = append(append(Types{}, s.Type...), "null")
+	if len(c.Enum) > 0 {
+		c.Enum = append(append([]interface{}{}, s.Enum...), nil)
+	}
+	return &c
+}
+
+// Between sets the inclusive numeric bounds of s and returns it.
+func (s *Schema) Between(min, max float64) *Schema {
+	s.Minimum, s.Maximum = &min, &max
+	return s
+}
+
+// AtLeast sets the inclusive minimum of s and returns it.
+func (s *Schema) AtLeast(min float64) *Schema {
+	s.Minimum = &min
+	return s
+}
+
+// Length sets the string length bounds of s (max 0 means unbounded) and returns it.
+func (s *Schema) Length(min, max int) *Schema {
+	s.MinLength = &min
+	if max > 0 {
+		s.MaxLength = &max
+	}
+	return s
+}
+
+// Describe sets the description of s and returns it.
+func (s *Schema) Describe(description string) *Schema {
+	s.Description = description
+	return s
+}
+
+// ---------- Paths ----------
+
+// Add registers an operation for a method and path template.
+func (d *Document) Add(method, path string, op *Operation) {
+	if d.Paths == nil {
+		d.Paths = make(map[string]PathItem)
+	}
+	if d.Paths[path] == nil {
+		d.Paths[path] = make(PathItem)
+	}
+	d.Paths[path][strings.ToLower(method)] = op
+}
+
+// Operations lists every operation as "METHOD /path", sorted.
+func (d *Document) Operations() []string {
+	var ops []string
+	for path, item := range d.Paths {
+		for method := range item {
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// FindOperation returns the operation for a request path (relative to the
+// server URL) and the values of its path parameters. Templates with more
+// literal segments win, so /problems/today is preferred over /problems/{id}.
+func (d *Document) FindOperation(method, path string) (*Operation, map[string]string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	var best *Operation
+	var bestParams map[string]string
+	bestScore := -1
+	for template, item := range d.Paths {
+		op := item[strings.ToLower(method)]
+		if op == nil {
+			continue
+		}
+		params, score, ok := matchPath(template, segments)
+		if ok && score > bestScore {
+			best, bestParams, bestScore = op, params, score
+		}
+	}
+	return best, bestParams, best != nil
+}
+
+// matchPath matches path segments against a template. The score counts the
+// literal segments.
+func matchPath(template string, segments []string) (map[string]string, int, bool) {
+	parts := strings.Split(strings.Trim(template, "/"), "/")
+	if len(parts) != len(segments) {
+		return nil, 0, false
+	}
+	params := map[string]string{}
+	score := 0
+	for i, part := range parts {
+		open := strings.Index(part, "{")
+		if open < 0 {
+			if part != segments[i] {
+				return nil, 0, false
+			}
+			score++
+			continue
+		}
+		end := strings.Index(part, "}")
+		if end < open {
+			return nil, 0, false
+		}
+		prefix, name, suffix := part[:open], part[open+1:end], part[end+1:]
+		seg := segments[i]
+		if len(seg) <= len(prefix)+len(suffix) || !strings.HasPrefix(seg, prefix) || !strings.HasSuffix(seg, suffix) {
+			return nil, 0, false
+		}
+		params[name] = seg[len(prefix) : len(seg)-len(suffix)]
+	}
+	return params, score, true
+}
+
+// resolve follows a $ref to its component schema.
+func (d *Document) resolve(s *Schema) (*Schema, error) {
+	for s != nil && s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		target, ok := d.Components.Schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("unresolved $ref %q", s.Ref)
+		}
+		s = target
+	}
+	return s, nil
+}
+
+// CheckRefs reports every $ref that doesn't resolve to a component schema.
+func (d *Document) CheckRefs() []string {
+	var missing []string
+	seen := map[*Schema]bool{}
+	var walk func(s *Schema, where string)
+	walk = func(s *Schema, where string) {
+		if s == nil || seen[s] {
+			return
+		}
+		seen[s] = true
+		if s.Ref != "" {
+			if _, err := d.resolve(s); err != nil {
+				missing = append(missing, where+": "+err.Error())
+			}
+		}
+		for name, p := range s.Properties {
+			walk(p, where+"."+name)
+		}
+		walk(s.AdditionalProperties, where)
+		walk(s.Items, where+"[]")
+		for _, sub := range append(append([]*Schema{}, s.AllOf...), s.OneOf...) {
+			walk(sub, where)
+		}
+	}
+
+	for name, s := range d.Components.Schemas {
+		walk(s, name)
+	}
+	for path, item := range d.Paths {
+		for method, op := range item {
+			where := strings.ToUpper(method) + " " + path
+			for _, p := range op.Parameters {
+				walk(p.Schema, where+" "+p.Name)
+			}
+			if op.RequestBody != nil {
+				for _, mt := range op.RequestBody.Content {
+					walk(mt.Schema, where+" body")
+				}
+			}
+			for status, resp := range op.Responses {
+				for _, mt := range resp.Content {
+					walk(mt.Schema, where+" "+status)
+				}
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,159 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testDocument() *Document {
+	d := &Document{
+		OpenAPI: "3.1.0",
+		Components: Components{Schemas: map[string]*Schema{
+			"Item": Object(map[string]*Schema{
+				"id":    String("uuid"),
+				"title": String().Length(1, 10),
+				"level": Enum("easy", "hard"),
+				"due":   Nullable(String("date-time")),
+				"tags":  Array(String()),
+			}, "id", "title"),
+		}},
+	}
+	d.Add("GET", "/items/{id}", &Operation{OperationID: "getItem",
+		Parameters: []*Parameter{{Name: "id", In: "path", Required: true, Schema: String("uuid")}},
+		Responses: map[string]*Response{
+			"200":     {Description: "ok", Content: map[string]*MediaType{"application/json": {Schema: Ref("Item")}}},
+			"default": {Description: "error", Content: map[string]*MediaType{"text/plain": {}}},
+		}})
+	d.Add("GET", "/items/today", &Operation{OperationID: "todaysItems", Responses: map[string]*Response{}})
+	d.Add("GET", "/feeds/{token}.ics", &Operation{OperationID: "feed", Responses: map[string]*Response{}})
+	d.Add("POST", "/items", &Operation{OperationID: "createItem",
+		Parameters: []*Parameter{{Name: "limit", In: "query", Schema: Integer().Between(1, 50)}},
+		RequestBody: &RequestBody{Required: true, Content: map[string]*MediaType{
+			"application/json": {Schema: Ref("Item")},
+		}},
+		Responses: map[string]*Response{}})
+	return d
+}
+
+func TestFindOperation(t *testing.T) {
+	d := testDocument()
+	cases := []struct {
+		method, path, op, param string
+	}{
+		{"GET", "/items/today", "todaysItems", ""},
+		{"GET", "/items/abc", "getItem", "abc"},
+		{"GET", "/feeds/s3cr3t.ics", "feed", "s3cr3t"},
+		{"POST", "/items/", "createItem", ""},
+	}
+	for _, c := range cases {
+		op, params, ok := d.FindOperation(c.method, c.path)
+		if !ok || op.OperationID != c.op {
+			t.Errorf("%s %s: got %v, want %s", c.method, c.path, op, c.op)
+			continue
+		}
+		if c.param != "" && params["id"] != c.param && params["token"] != c.param {
+			t.Errorf("%s %s: params %v", c.method, c.path, params)
+		}
+	}
+	for _, miss := range []string{"/feeds/.ics", "/feeds/x.atom", "/items/a/b"} {
+		if _, _, ok := d.FindOperation("GET", miss); ok {
+			t.Errorf("GET %s should not match", miss)
+		}
+	}
+	if _, _, ok := d.FindOperation("DELETE", "/items/abc"); ok {
+		t.Error("undocumented method matched")
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	d := testDocument()
+	op, params, _ := d.FindOperation("POST", "/items")
+
+	validate := func(target, body string) []FieldError {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		err := d.ValidateRequest(req, op, params)
+		if err == nil {
+			return nil
+		}
+		return err.(*ValidationError).Errors
+	}
+
+	ok := `{"id":"6f1c1a3e-8d4e-4c52-9a7a-2b7d7c0b4c11","title":"Two Sum","due":null,"extra":1}`
+	if errs := validate("/items", ok); errs != nil {
+		t.Errorf("valid request rejected: %v", errs)
+	}
+
+	cases := []struct {
+		target, body, field string
+	}{
+		{"/items", ``, "body"},
+		{"/items", `{`, "body"},
+		{"/items", `{"id":"6f1c1a3e-8d4e-4c52-9a7a-2b7d7c0b4c11"}`, "body.title"},
+		{"/items", `{"id":"nope","title":"x"}`, "body.id"},
+		{"/items", `{"id":"6f1c1a3e-8d4e-4c52-9a7a-2b7d7c0b4c11","title":"much too long title"}`, "body.title"},
+		{"/items", `{"id":"6f1c1a3e-8d4e-4c52-9a7a-2b7d7c0b4c11","title":"x","level":"medium"}`, "body.level"},
+		{"/items", `{"id":"6f1c1a3e-8d4e-4c52-9a7a-2b7d7c0b4c11","title":"x","due":"tomorrow"}`, "body.due"},
+		{"/items", `{"id":"6f1c1a3e-8d4e-4c52-9a7a-2b7d7c0b4c11","title":"x","tags":["a",2]}`, "body.tags[1]"},
+		{"/items?limit=abc", ok, "query.limit"},
+		{"/items?limit=51", ok, "query.limit"},
+	}
+	for _, c := range cases {
+		errs := validate(c.target, c.body)
+		if len(errs) == 0 || errs[0].Field != c.field {
+			t.Errorf("%s %s: got %v, want an error on %s", c.target, c.body, errs, c.field)
+		}
+	}
+
+	// Handlers can still read the body after validation
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(ok))
+	if err := d.ValidateRequest(req, op, params); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.NewDecoder(req.Body).Decode(&decoded); err != nil || decoded["title"] != "Two Sum" {
+		t.Errorf("body not restored: %v %v", decoded, err)
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	d := testDocument()
+	op, _, _ := d.FindOperation("GET", "/items/x")
+	jsonHeader := http.Header{"Content-Type": {"application/json"}}
+
+	if err := d.ValidateResponse(op, 200, jsonHeader, []byte(`{"id":"6f1c1a3e-8d4e-4c52-9a7a-2b7d7c0b4c11","title":"ok"}`)); err != nil {
+		t.Errorf("valid response rejected: %v", err)
+	}
+	if err := d.ValidateResponse(op, 200, jsonHeader, []byte(`{"id":"6f1c1a3e-8d4e-4c52-9a7a-2b7d7c0b4c11"}`)); err == nil {
+		t.Error("missing required property accepted")
+	}
+	if err := d.ValidateResponse(op, 200, http.Header{"Content-Type": {"text/html"}}, []byte(`<p>`)); err == nil {
+		t.Error("undocumented content type accepted")
+	}
+	if err := d.ValidateResponse(op, 404, http.Header{"Content-Type": {"text/plain; charset=utf-8"}}, []byte("Not found\n")); err != nil {
+		t.Errorf("default response not applied: %v", err)
+	}
+}
+
+func TestSchemaJSON(t *testing.T) {
+	b, err := json.Marshal(Nullable(Enum("a", "b")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != `{"type":["string","null"],"enum":["a","b",null]}` {
+		t.Errorf("unexpected schema JSON %s", got)
+	}
+
+	var s Schema
+	if err := json.Unmarshal([]byte(`{"type":"integer"}`), &s); err != nil || !s.Type.has("integer") {
+		t.Errorf("type not decoded: %v %v", s.Type, err)
+	}
+
+	d := testDocument()
+	d.Components.Schemas["Broken"] = Array(Ref("Missing"))
+	if missing := d.CheckRefs(); len(missing) != 1 {
+		t.Errorf("expected one unresolved ref, got %v", missing)
+	}
+}
@@ -0,0 +1,374 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError is one validation failure. Field is a dotted path into the
+// body ("body.solution.code") or the parameter name ("query.limit").
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists everything wrong with a request or response.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+var patterns sync.Map // pattern string -> *regexp.Regexp
+
+// ValidateRequest checks a request's path and query parameters and its JSON
+// body against op. The body is read and replaced, so handlers can still
+// decode it.
+func (d *Document) ValidateRequest(r *http.Request, op *Operation, pathParams map[string]string) error {
+	var errs []FieldError
+
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		var raw string
+		var present bool
+		switch p.In {
+		case "path":
+			raw, present = pathParams[p.Name]
+		case "query":
+			present = query.Has(p.Name)
+			raw = query.Get(p.Name)
+		case "header":
+			raw = r.Header.Get(p.Name)
+			present = raw != ""
+		default:
+			continue
+		}
+		field := p.In + "." + p.Name
+		if !present {
+			if p.Required {
+				errs = append(errs, FieldError{field, "is required"})
+			}
+			continue
+		}
+		errs = append(errs, d.validateParam(p.Schema, raw, field)...)
+	}
+
+	if op.RequestBody != nil {
+		bodyErrs, err := d.validateRequestBody(r, op.RequestBody)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, bodyErrs...)
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func (d *Document) validateRequestBody(r *http.Request, rb *RequestBody) ([]FieldError, error) {
+	if r.Body == nil {
+		if rb.Required {
+			return []FieldError{{"body", "is required"}}, nil
+		}
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if rb.Required {
+			return []FieldError{{"body", "is required"}}, nil
+		}
+		return nil, nil
+	}
+
+	mt := rb.Content["application/json"]
+	if mt == nil || mt.Schema == nil {
+		return nil, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []FieldError{{"body", "is not valid JSON"}}, nil
+	}
+	return d.ValidateValue(mt.Schema, value, "body"), nil
+}
+
+// validateParam converts a parameter's string value to the schema's type
+// before validating it.
+func (d *Document) validateParam(s *Schema, raw, field string) []FieldError {
+	s, err := d.resolve(s)
+	if err != nil {
+		return []FieldError{{field, err.Error()}}
+	}
+	var value interface{} = raw
+	switch {
+	case s.Type.has("integer"):
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return []FieldError{{field, "must be an integer"}}
+		}
+		value = float64(n)
+	case s.Type.has("number"):
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return []FieldError{{field, "must be a number"}}
+		}
+		value = f
+	case s.Type.has("boolean"):
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return []FieldError{{field, "must be true or false"}}
+		}
+		value = b
+	}
+	return d.ValidateValue(s, value, field)
+}
+
+// ValidateResponse checks a response against op: the status must be
+// documented (or covered by "default"), the content type must be one of the
+// documented ones and JSON bodies must match their schema.
+func (d *Document) ValidateResponse(op *Operation, status int, header http.Header, body []byte) error {
+	resp := op.Responses[strconv.Itoa(status)]
+	if resp == nil {
+		resp = op.Responses[strconv.Itoa(status/100)+"XX"]
+	}
+	if resp == nil {
+		resp = op.Responses["default"]
+	}
+	if resp == nil {
+		return fmt.Errorf("undocumented status %d", status)
+	}
+	if len(resp.Content) == 0 || len(body) == 0 {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("status %d: invalid Content-Type %q", status, header.Get("Content-Type"))
+	}
+	mt, ok := resp.Content[mediaType]
+	if !ok {
+		documented := make([]string, 0, len(resp.Content))
+		for t := range resp.Content {
+			documented = append(documented, t)
+		}
+		sort.Strings(documented)
+		return fmt.Errorf("status %d: Content-Type %s is not one of %s", status, mediaType, strings.Join(documented, ", "))
+	}
+	if mt.Schema == nil || mediaType != "application/json" {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("status %d: body is not valid JSON: %v", status, err)
+	}
+	if errs := d.ValidateValue(mt.Schema, value, "body"); len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// ValidateValue checks a decoded JSON value against a schema.
+func (d *Document) ValidateValue(s *Schema, value interface{}, field string) []FieldError {
+	s, err := d.resolve(s)
+	if err != nil {
+		return []FieldError{{field, err.Error()}}
+	}
+	if s == nil {
+		return nil
+	}
+
+	var errs []FieldError
+	for _, sub := range s.AllOf {
+		errs = append(errs, d.ValidateValue(sub, value, field)...)
+	}
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, sub := range s.OneOf {
+			if len(d.ValidateValue(sub, value, field)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			errs = append(errs, FieldError{field, fmt.Sprintf("must match exactly one of %d schemas (matched %d)", len(s.OneOf), matches)})
+		}
+	}
+
+	if len(s.Type) > 0 && !s.Type.accepts(value) {
+		return append(errs, FieldError{field, "must be " + s.Type.describe()})
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if allowed == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, FieldError{field, "must be one of " + describeEnum(s.Enum)})
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		errs = append(errs, d.validateString(s, v, field)...)
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			errs = append(errs, FieldError{field, fmt.Sprintf("must be at least %v", *s.Minimum)})
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			errs = append(errs, FieldError{field, fmt.Sprintf("must be at most %v", *s.Maximum)})
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			errs = append(errs, FieldError{field, fmt.Sprintf("must have at least %d items", *s.MinItems)})
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			errs = append(errs, FieldError{field, fmt.Sprintf("must have at most %d items", *s.MaxItems)})
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				errs = append(errs, d.ValidateValue(s.Items, item, fmt.Sprintf("%s[%d]", field, i))...)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				errs = append(errs, FieldError{field + "." + name, "is required"})
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if prop, ok := s.Properties[k]; ok {
+				errs = append(errs, d.ValidateValue(prop, v[k], field+"."+k)...)
+			} else if s.AdditionalProperties != nil {
+				errs = append(errs, d.ValidateValue(s.AdditionalProperties, v[k], field+"."+k)...)
+			}
+		}
+	}
+	return errs
+}
+
+func (d *Document) validateString(s *Schema, v, field string) []FieldError {
+	var errs []FieldError
+	length := utf8.RuneCountInString(v)
+	if s.MinLength != nil && length < *s.MinLength {
+		errs = append(errs, FieldError{field, fmt.Sprintf("must be at least %d characters", *s.MinLength)})
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		errs = append(errs, FieldError{field, fmt.Sprintf("must be at most %d characters", *s.MaxLength)})
+	}
+	if s.Pattern != "" {
+		re, ok := patterns.Load(s.Pattern)
+		if !ok {
+			compiled, err := regexp.Compile(s.Pattern)
+			if err != nil {
+				return append(errs, FieldError{field, "invalid pattern in schema: " + err.Error()})
+			}
+			re, _ = patterns.LoadOrStore(s.Pattern, compiled)
+		}
+		if !re.(*regexp.Regexp).MatchString(v) {
+			errs = append(errs, FieldError{field, "must match " + s.Pattern})
+		}
+	}
+	switch s.Format {
+	case "uuid":
+		if !uuidRe.MatchString(v) {
+			errs = append(errs, FieldError{field, "must be a UUID"})
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+			errs = append(errs, FieldError{field, "must be an RFC 3339 timestamp"})
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			errs = append(errs, FieldError{field, "must be a date (YYYY-MM-DD)"})
+		}
+	}
+	return errs
+}
+
+func (t Types) has(name string) bool {
+	for _, n := range t {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// accepts reports whether a decoded JSON value has one of the types.
+func (t Types) accepts(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return t.has("null")
+	case bool:
+		return t.has("boolean")
+	case string:
+		return t.has("string")
+	case float64:
+		return t.has("number") || (t.has("integer") && v == math.Trunc(v))
+	case []interface{}:
+		return t.has("array")
+	case map[string]interface{}:
+		return t.has("object")
+	}
+	return false
+}
+
+func (t Types) describe() string {
+	names := make([]string, len(t))
+	for i, n := range t {
+		switch n {
+		case "integer", "array", "object":
+			names[i] = "an " + n
+		case "null":
+			names[i] = "null"
+		default:
+			names[i] = "a " + n
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+func describeEnum(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		b, _ := json.Marshal(v)
+		parts[i] = string(b)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// UpdateProblemOverrides sets per-problem scheduling overrides.
+// Accepts any subset of:
+//
+//	{"snoozed_until": "...", "hold_until": "...", "pinned": true, "priority_multiplier": 1.5}
+//
+// Dates are RFC 3339 timestamps; null clears them. Pinning a problem also
+// clears its snooze unless snoozed_until is set in the same request.
+func UpdateProblemOverrides(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	idStr := chi.URLParam(r, "id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, r, invalidBody(err))
+		return
+	}
+
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	for key, raw := range body {
+		switch key {
+		case "snoozed_until", "hold_until":
+			var t NullTime
+			if err := json.Unmarshal(raw, &t); err != nil {
+				respondError(w, r, invalidField("body."+key, "must be an RFC 3339 timestamp or null"))
+				return
+			}
+			set(key, t)
+		case "pinned":
+			var pinned bool
+			if err := json.Unmarshal(raw, &pinned); err != nil {
+				respondError(w, r, invalidField("body.pinned", "must be a boolean"))
+				return
+			}
+			set("pinned", pinned)
+			if _, snoozeGiven := body["snoozed_until"]; pinned && !snoozeGiven {
+				sets = append(sets, "snoozed_until = NULL")
+			}
+		case "priority_multiplier":
+			var m float64
+			if err := json.Unmarshal(raw, &m); err != nil || m < 0.1 || m > 10 {
+				respondError(w, r, invalidField("body.priority_multiplier", "must be a number between 0.1 and 10"))
+				return
+			}
+			set("priority_multiplier", m)
+		default:
+			respondError(w, r, invalidField("body."+key, "is not a known override"))
+			return
+		}
+	}
+
+	if len(sets) == 0 {
+		respondError(w, r, invalid("No overrides given"))
+		return
+	}
+
+	args = append(args, id, userID)
+	var p Problem
+	err = scanProblem(db.QueryRow(fmt.Sprintf(`
+		UPDATE problems
+		SET %s
+		WHERE id = $%d AND user_id = $%d AND status <> 'trashed'
+		RETURNING `+problemColumns, strings.Join(sets, ", "), len(args)-1, len(args)), args...), &p)
+	if err == sql.ErrNoRows {
+		respondError(w, r, notFound("Problem"))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	publishProblemEvent(userID, EventProblemUpdated, p.ID)
+	respondJSON(w, http.StatusOK, p)
+}
+
+// FocusProblemNow adds a problem to today's focus immediately, on top of the
+// already materialized plan.
+func FocusProblemNow(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	idStr := chi.URLParam(r, "id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return
+	}
+
+	var status string
+	err = db.QueryRow(`SELECT status FROM problems WHERE id = $1 AND user_id = $2`, id, userID).Scan(&status)
+	if err != nil || status != "active" {
+		respondError(w, r, notFound("Problem"))
+		return
+	}
+
+	var prefs UserPreferences
+	if err := db.QueryRow("SELECT preferences FROM users WHERE id = $1", userID).Scan(&prefs); err != nil {
+		slog.WarnContext(r.Context(), "Error fetching preferences, using defaults", "error", err)
+		prefs = UserPreferences{MinRevisitDays: 2, ProblemsPerDay: 3}
+	}
+
+	// The plan must exist first, otherwise generating it later would skip this problem
+	if _, err := EnsureDailyPlan(userID, prefs); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	added, err := AddToTodaysPlan(userID, id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	status = "focused"
+	if !added {
+		status = "already_planned"
+	} else {
+		publishProblemEvent(userID, EventProblemUpdated, id)
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"status": status})
+}
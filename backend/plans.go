@@ -0,0 +1,329 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PlanItem is a single problem in a materialized daily plan, joined with the
+// problem's current state.
+type PlanItem struct {
+	Problem        Problem `json:"problem"`
+	Position       int     `json:"position"`
+	RevisitedToday bool    `json:"revisited_today"`
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// focusScopeClause restricts a problem query to the collection in the given
+// placeholder, or to all problems when it is NULL.
+func focusScopeClause(idColumn, placeholder string) string {
+	return "(" + placeholder + "::uuid IS NULL OR " + idColumn +
+		" IN (SELECT problem_id FROM collection_items WHERE collection_id = " + placeholder + "::uuid))"
+}
+
+// fetchStartOfDayProblems loads all active problems for a user that were added
+// before today, with their revisit counters reverted to the start of the day
+// (today's revisits are ignored). This is the candidate pool for a daily plan.
+// A valid scope limits the pool to that collection's problems.
+func fetchStartOfDayProblems(q querier, userID uuid.UUID, scope uuid.NullUUID) ([]Problem, error) {
+	rows, err := q.Query(`
+		SELECT `+problemColumnsFor("p")+`,
+		       COUNT(CASE WHEN rh.revisited_at::date < CURRENT_DATE THEN 1 END) as prev_times_revisited,
+		       MAX(CASE WHEN rh.revisited_at::date < CURRENT_DATE THEN rh.revisited_at END) as prev_last_revisited_at
+		FROM problems p
+		LEFT JOIN revisit_history rh ON p.id = rh.problem_id
+		WHERE p.user_id = $1 AND p.status = 'active' AND p.date_added::date < CURRENT_DATE
+		  AND `+focusScopeClause("p.id", "$2")+`
+		GROUP BY p.id
+		ORDER BY p.date_added ASC`, userID, scope)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var problems []Problem
+	for rows.Next() {
+		var p Problem
+		var prevTimes int
+		var prevLast NullTime
+		if err := scanProblem(rows, &p, &prevTimes, &prevLast); err != nil {
+			return nil, err
+		}
+		p.TimesRevisited = prevTimes
+		p.LastRevisitedAt = prevLast
+		problems = append(problems, p)
+	}
+	return problems, rows.Err()
+}
+
+// EnsureDailyPlan materializes today's focus selection for a user if it has not
+// been generated yet. The selection is made once per user per day — by the cron
+// or by the first dashboard request — and never recomputed afterwards, so
+// adding or archiving problems mid-day does not reshuffle it.
+// Returns true if a new plan was created.
+func EnsureDailyPlan(userID uuid.UUID, prefs UserPreferences) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Serialize plan generation per user so the cron and a concurrent
+	// dashboard request can't both insert a selection.
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, "daily_plan:"+userID.String()); err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM daily_plans WHERE user_id = $1 AND plan_date = CURRENT_DATE)`,
+		userID).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	candidates, err := fetchStartOfDayProblems(tx, userID, prefs.FocusCollectionID)
+	if err != nil {
+		return false, err
+	}
+
+	// An active interview goal switches selection to cram mode
+	goal, err := loadActiveGoal(tx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	var selected []Problem
+	var eligibleCount int
+	if goal != nil {
+		scoped, done, err := loadGoalProblems(tx, userID, goal, false)
+		if err != nil {
+			return false, err
+		}
+		inScope := make(map[uuid.UUID]bool, len(scoped))
+		for _, p := range scoped {
+			inScope[p.ID] = true
+		}
+		// The goal's own scope counts even when the focus scope excludes it
+		if prefs.FocusCollectionID.Valid && prefs.FocusCollectionID != goal.CollectionID {
+			goalPool, err := fetchStartOfDayProblems(tx, userID, goal.CollectionID)
+			if err != nil {
+				return false, err
+			}
+			candidates = CramCandidates(candidates, goalPool, inScope)
+		}
+		selected, eligibleCount = SelectCramFocus(candidates, inScope, done, goal.cram(), prefs.MinRevisitDays, DaySeed(), time.Now())
+	} else {
+		eligible := FilterEligible(candidates, prefs.MinRevisitDays, time.Now())
+		selected = SelectFocus(eligible, prefs.ProblemsPerDay, DaySeed(), time.Now())
+		eligibleCount = len(eligible)
+	}
+
+	var planID uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO daily_plans (user_id, plan_date, eligible_count)
+		VALUES ($1, CURRENT_DATE, $2)
+		RETURNING id`, userID, eligibleCount).Scan(&planID)
+	if err != nil {
+		return false, err
+	}
+
+	for i, p := range selected {
+		_, err = tx.Exec(`
+			INSERT INTO daily_plan_items (plan_id, problem_id, position)
+			VALUES ($1, $2, $3)`, planID, p.ID, i)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	slog.Info("Generated daily plan", "user_id", userID, "selected", len(selected), "eligible", eligibleCount)
+	publishEvent(userID, EventPlanGenerated, map[string]interface{}{"plan_id": planID, "problems": len(selected)})
+	return true, nil
+}
+
+// LoadDailyPlan returns the items of a user's plan for the given date (YYYY-MM-DD,
+// or "" for today), joined with the current state of each problem. Problems that are no longer
+// active are left out, but the remaining items keep their order.
+func LoadDailyPlan(userID uuid.UUID, planDate string) ([]PlanItem, error) {
+	rows, err := db.Query(`
+		SELECT `+problemColumnsFor("p")+`,
+		       dpi.position,
+		       EXISTS(SELECT 1 FROM revisit_history rh WHERE rh.problem_id = p.id AND rh.revisited_at::date = dp.plan_date)
+		FROM daily_plans dp
+		JOIN daily_plan_items dpi ON dpi.plan_id = dp.id
+		JOIN problems p ON p.id = dpi.problem_id
+		WHERE dp.user_id = $1 AND dp.plan_date = COALESCE(NULLIF($2, '')::date, CURRENT_DATE) AND p.status = 'active'
+		ORDER BY dpi.position ASC`, userID, planDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []PlanItem{}
+	for rows.Next() {
+		var item PlanItem
+		p := &item.Problem
+		if err := scanProblem(rows, p, &item.Position, &item.RevisitedToday); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// AddToTodaysPlan appends a problem to the end of the user's plan for today.
+// Returns false if the problem was already planned.
+func AddToTodaysPlan(userID, problemID uuid.UUID) (bool, error) {
+	result, err := db.Exec(`
+		INSERT INTO daily_plan_items (plan_id, problem_id, position)
+		SELECT dp.id, $2, COALESCE((SELECT MAX(position) + 1 FROM daily_plan_items WHERE plan_id = dp.id), 0)
+		FROM daily_plans dp
+		WHERE dp.user_id = $1 AND dp.plan_date = CURRENT_DATE
+		ON CONFLICT (plan_id, problem_id) DO NOTHING`, userID, problemID)
+	if err != nil {
+		return false, err
+	}
+	added, _ := result.RowsAffected()
+	return added > 0, nil
+}
+
+// resetTodaysPlanIfUnstarted deletes today's plan so the next request rebuilds
+// it, unless one of its problems has already been revisited today. Used when a
+// settings change (focus scope, goal) should apply immediately.
+// Returns true if today's plan will reflect the change.
+func resetTodaysPlanIfUnstarted(q querier, userID uuid.UUID) (bool, error) {
+	var started bool
+	err := q.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM daily_plans dp
+			JOIN daily_plan_items dpi ON dpi.plan_id = dp.id
+			JOIN revisit_history rh ON rh.problem_id = dpi.problem_id AND rh.revisited_at::date = dp.plan_date
+			WHERE dp.user_id = $1 AND dp.plan_date = CURRENT_DATE
+		)`, userID).Scan(&started)
+	if err != nil || started {
+		return false, err
+	}
+	_, err = q.Exec(`DELETE FROM daily_plans WHERE user_id = $1 AND plan_date = CURRENT_DATE`, userID)
+	return err == nil, err
+}
+
+// GetTodaysPlan ensures today's plan exists for the user and returns it.
+func GetTodaysPlan(userID uuid.UUID, prefs UserPreferences) ([]PlanItem, error) {
+	if _, err := EnsureDailyPlan(userID, prefs); err != nil {
+		return nil, err
+	}
+	return LoadDailyPlan(userID, "")
+}
+
+// pendingProblems returns the plan's problems not yet revisited today, in
+// plan order. This is what the daily email sends.
+func pendingProblems(plan []PlanItem) []Problem {
+	var pending []Problem
+	for _, item := range plan {
+		if !item.RevisitedToday {
+			pending = append(pending, item.Problem)
+		}
+	}
+	return pending
+}
+
+// loadPlanEligibleCount returns the eligible_count stored with the user's plan
+// for today.
+func loadPlanEligibleCount(userID uuid.UUID) (int, error) {
+	var count int
+	err := db.QueryRow(`
+		SELECT eligible_count FROM daily_plans WHERE user_id = $1 AND plan_date = CURRENT_DATE`,
+		userID).Scan(&count)
+	return count, err
+}
+
+// PlanDaySummary is a past (or current) daily plan with its completion stats.
+type PlanDaySummary struct {
+	PlanDate      string           `json:"plan_date"`
+	EligibleCount int              `json:"eligible_count"`
+	TotalFocus    int              `json:"total_focus"`
+	Completed     int              `json:"completed"`
+	Problems      []PlanDayProblem `json:"problems"`
+}
+
+// PlanDayProblem is a problem that was part of a past plan. ProblemID is null
+// once the problem has been purged from the trash.
+type PlanDayProblem struct {
+	ProblemID uuid.NullUUID `json:"problem_id"`
+	Title     string        `json:"title"`
+	Completed bool          `json:"completed"`
+}
+
+// LoadPlanHistory returns the user's plans for the last `days` days, newest first.
+// An item counts as completed if the problem was revisited on the plan's date.
+// Items of purged problems are reported from the snapshot taken at purge time.
+func LoadPlanHistory(userID uuid.UUID, days int) ([]PlanDaySummary, error) {
+	rows, err := db.Query(`
+		SELECT to_char(dp.plan_date, 'YYYY-MM-DD'), dp.eligible_count, dpi.plan_id IS NOT NULL,
+		       dpi.problem_id, COALESCE(p.title, dpi.title),
+		       dpi.completed_at IS NOT NULL
+		       OR EXISTS(SELECT 1 FROM revisit_history rh WHERE rh.problem_id = dpi.problem_id AND rh.revisited_at::date = dp.plan_date)
+		FROM daily_plans dp
+		LEFT JOIN daily_plan_items dpi ON dpi.plan_id = dp.id
+		LEFT JOIN problems p ON p.id = dpi.problem_id
+		WHERE dp.user_id = $1 AND dp.plan_date > CURRENT_DATE - $2::int
+		ORDER BY dp.plan_date DESC, dpi.position ASC`, userID, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []PlanDaySummary{}
+	for rows.Next() {
+		var planDate string
+		var eligibleCount int
+		var hasItem bool
+		var problemID uuid.NullUUID
+		var title sql.NullString
+		var completed bool
+		if err := rows.Scan(&planDate, &eligibleCount, &hasItem, &problemID, &title, &completed); err != nil {
+			return nil, err
+		}
+
+		if len(summaries) == 0 || summaries[len(summaries)-1].PlanDate != planDate {
+			summaries = append(summaries, PlanDaySummary{
+				PlanDate:      planDate,
+				EligibleCount: eligibleCount,
+				Problems:      []PlanDayProblem{},
+			})
+		}
+		day := &summaries[len(summaries)-1]
+
+		// Plans with no selected problems have a single row with NULL item columns
+		if !hasItem {
+			continue
+		}
+		day.Problems = append(day.Problems, PlanDayProblem{
+			ProblemID: problemID,
+			Title:     title.String,
+			Completed: completed,
+		})
+		day.TotalFocus++
+		if completed {
+			day.Completed++
+		}
+	}
+	return summaries, rows.Err()
+}
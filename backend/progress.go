@@ -0,0 +1,196 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// masteryRevisits is how many revisits make a problem count as mastered.
+// Archived (retired) problems are always counted as mastered.
+const masteryRevisits = 3
+
+// ListProgress summarizes how far a user is through a curated list or one of
+// their collections.
+type ListProgress struct {
+	Total            int           `json:"total"`
+	Added            int           `json:"added"`             // in the user's problems
+	Revisited        int           `json:"revisited"`         // revisited at least once
+	ReviewedRecently int           `json:"reviewed_recently"` // revisited within max_revisit_days
+	Mastered         int           `json:"mastered"`
+	MaxRevisitDays   int           `json:"max_revisit_days"`
+	NextSuggested    *ProgressItem `json:"next_suggested"` // first item not yet started, in list order
+}
+
+// ProgressItem identifies a list entry. ProblemID is set when the user has
+// added it; CatalogProblemID is set for curated list entries.
+type ProgressItem struct {
+	Position         int           `json:"position"`
+	Title            string        `json:"title"`
+	Link             string        `json:"link"`
+	ProblemID        uuid.NullUUID `json:"problem_id"`
+	CatalogProblemID uuid.NullUUID `json:"catalog_problem_id,omitempty"`
+}
+
+// progressEntry is one list position along with the user's problem for it
+// (nil when not added yet).
+type progressEntry struct {
+	ProgressItem
+	Problem *Problem
+}
+
+// computeListProgress tallies progress over entries, which must be in list
+// order. An entry is "started" once it is added and revisited at least once;
+// mastered entries are never suggested, even if they were not.
+func computeListProgress(entries []progressEntry, maxRevisitDays int, now time.Time) ListProgress {
+	progress := ListProgress{Total: len(entries), MaxRevisitDays: maxRevisitDays}
+	recentCutoff := now.AddDate(0, 0, -maxRevisitDays)
+
+	for _, e := range entries {
+		p := e.Problem
+		started := p != nil && (p.TimesRevisited > 0 || mastered(p))
+		if !started && progress.NextSuggested == nil {
+			item := e.ProgressItem
+			progress.NextSuggested = &item
+		}
+		if p == nil {
+			continue
+		}
+
+		progress.Added++
+		if p.TimesRevisited > 0 {
+			progress.Revisited++
+		}
+		if p.LastRevisitedAt.Valid && !p.LastRevisitedAt.Time.Before(recentCutoff) {
+			progress.ReviewedRecently++
+		}
+		if mastered(p) {
+			progress.Mastered++
+		}
+	}
+	return progress
+}
+
+// mastered reports whether p counts as mastered for list progress.
+func mastered(p *Problem) bool {
+	return p.Status == "retired" || p.TimesRevisited >= masteryRevisits
+}
+
+// loadMaxRevisitDays returns the user's max_revisit_days preference.
+func loadMaxRevisitDays(userID uuid.UUID) int {
+	var prefs UserPreferences
+	if err := db.QueryRow("SELECT preferences FROM users WHERE id = $1", userID).Scan(&prefs); err != nil || prefs.MaxRevisitDays <= 0 {
+		return 10
+	}
+	return prefs.MaxRevisitDays
+}
+
+// GetCatalogListProgress returns the user's progress through a curated list.
+func GetCatalogListProgress(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	slug := chi.URLParam(r, "slug")
+
+	var listID uuid.UUID
+	err := db.QueryRow(`SELECT id FROM catalog_lists WHERE slug = $1`, slug).Scan(&listID)
+	if err == sql.ErrNoRows {
+		respondError(w, r, notFound("List"))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	// The user's copies of the list's problems, keyed by catalog entry
+	rows, err := db.Query(`
+		SELECT `+problemColumns+`
+		FROM problems
+		WHERE user_id = $1 AND status <> 'trashed'
+		  AND catalog_problem_id IN (SELECT catalog_problem_id FROM catalog_list_items WHERE list_id = $2)`,
+		userID, listID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	byCatalogID := make(map[uuid.UUID]*Problem)
+	for rows.Next() {
+		p := new(Problem)
+		if err := scanProblem(rows, p); err != nil {
+			respondError(w, r, err)
+			return
+		}
+		byCatalogID[p.CatalogProblemID.UUID] = p
+	}
+
+	itemRows, err := db.Query(`
+		SELECT i.position, c.id, c.title, c.link
+		FROM catalog_list_items i
+		JOIN catalog_problems c ON c.id = i.catalog_problem_id
+		WHERE i.list_id = $1
+		ORDER BY i.position`, listID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer itemRows.Close()
+
+	var entries []progressEntry
+	for itemRows.Next() {
+		var e progressEntry
+		var catalogID uuid.UUID
+		if err := itemRows.Scan(&e.Position, &catalogID, &e.Title, &e.Link); err != nil {
+			respondError(w, r, err)
+			return
+		}
+		e.CatalogProblemID = uuid.NullUUID{UUID: catalogID, Valid: true}
+		if p, ok := byCatalogID[catalogID]; ok {
+			e.Problem = p
+			e.ProblemID = uuid.NullUUID{UUID: p.ID, Valid: true}
+		}
+		entries = append(entries, e)
+	}
+
+	respondJSON(w, http.StatusOK, computeListProgress(entries, loadMaxRevisitDays(userID), time.Now()))
+}
+
+// GetCollectionProgress returns the user's progress through one of their collections.
+func GetCollectionProgress(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return
+	}
+
+	problems, err := loadCollectionProblems(userID, id)
+	if err == sql.ErrNoRows {
+		respondError(w, r, notFound("Collection"))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	entries := make([]progressEntry, len(problems))
+	for i := range problems {
+		p := &problems[i]
+		entries[i] = progressEntry{
+			ProgressItem: ProgressItem{
+				Position:         i,
+				Title:            p.Title,
+				Link:             p.Link,
+				ProblemID:        uuid.NullUUID{UUID: p.ID, Valid: true},
+				CatalogProblemID: p.CatalogProblemID,
+			},
+			Problem: p,
+		}
+	}
+
+	respondJSON(w, http.StatusOK, computeListProgress(entries, loadMaxRevisitDays(userID), time.Now()))
+}
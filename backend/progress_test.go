@@ -0,0 +1,56 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestComputeListProgress(t *testing.T) {
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+	revisited := func(times int, daysAgo int) *Problem {
+		return &Problem{
+			Status:          "active",
+			TimesRevisited:  times,
+			LastRevisitedAt: NullTime{sql.NullTime{Time: now.AddDate(0, 0, -daysAgo), Valid: true}},
+		}
+	}
+
+	entries := []progressEntry{
+		{ProgressItem: ProgressItem{Position: 1, Title: "recent"}, Problem: revisited(1, 2)},
+		{ProgressItem: ProgressItem{Position: 2, Title: "stale"}, Problem: revisited(4, 30)},
+		{ProgressItem: ProgressItem{Position: 3, Title: "added, never revisited"}, Problem: &Problem{Status: "active"}},
+		{ProgressItem: ProgressItem{Position: 4, Title: "not added"}},
+		{ProgressItem: ProgressItem{Position: 5, Title: "archived"}, Problem: &Problem{Status: "retired"}},
+	}
+
+	got := computeListProgress(entries, 10, now)
+
+	if got.Total != 5 || got.Added != 4 || got.Revisited != 2 || got.ReviewedRecently != 1 || got.Mastered != 2 {
+		t.Errorf("progress = %+v, want total 5, added 4, revisited 2, recent 1, mastered 2", got)
+	}
+	if got.NextSuggested == nil || got.NextSuggested.Position != 3 {
+		t.Errorf("next suggested = %+v, want position 3", got.NextSuggested)
+	}
+}
+
+func TestComputeListProgressSkipsArchived(t *testing.T) {
+	entries := []progressEntry{
+		{ProgressItem: ProgressItem{Position: 1, Title: "archived, never revisited"}, Problem: &Problem{Status: "retired"}},
+		{ProgressItem: ProgressItem{Position: 2, Title: "not added"}},
+	}
+	got := computeListProgress(entries, 10, time.Now())
+	if got.Mastered != 1 || got.NextSuggested == nil || got.NextSuggested.Position != 2 {
+		t.Errorf("progress = %+v, next %+v; want the archived entry mastered and position 2 suggested", got, got.NextSuggested)
+	}
+}
+
+func TestComputeListProgressAllStarted(t *testing.T) {
+	now := time.Now()
+	entries := []progressEntry{
+		{Problem: &Problem{TimesRevisited: 1, LastRevisitedAt: NullTime{sql.NullTime{Time: now, Valid: true}}}},
+	}
+	if got := computeListProgress(entries, 10, now); got.NextSuggested != nil {
+		t.Errorf("expected no next suggestion, got %+v", got.NextSuggested)
+	}
+}
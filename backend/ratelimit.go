@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limiting: every request spends a token from a bucket that refills at
+// a steady rate. Requests are limited by client IP before authentication (so
+// floods never reach the JWKS lookup) and by user after it, and costly
+// routes spend from an extra, smaller bucket of their own.
+
+// Budget is a token bucket: Burst requests at once, refilled evenly over Per.
+type Budget struct {
+	Name  string // distinguishes the buckets of different budgets
+	Burst int
+	Per   time.Duration
+}
+
+// Budgets per client IP, per user, and for the routes that need less.
+var rateLimits = struct {
+	IP, User, Export, Email Budget
+}{
+	IP:     Budget{Name: "ip", Burst: 300, Per: time.Minute},
+	User:   Budget{Name: "user", Burst: 120, Per: time.Minute},
+	Export: Budget{Name: "export", Burst: 10, Per: time.Hour},
+	Email:  Budget{Name: "email", Burst: 3, Per: time.Hour},
+}
+
+func (b Budget) rate() float64 { return float64(b.Burst) / b.Per.Seconds() }
+
+// bucket is the state of one key's budget.
+type bucket struct {
+	Tokens  float64
+	Updated time.Time // zero for a key never seen, whose bucket is full
+}
+
+// Decision is the outcome of spending from a bucket.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token, when not allowed
+}
+
+// take refills bk for the time since its last update and spends a token if
+// one is left.
+func (b Budget) take(bk bucket, now time.Time) (bucket, Decision) {
+	tokens := float64(b.Burst)
+	if !bk.Updated.IsZero() {
+		tokens = math.Min(tokens, bk.Tokens+now.Sub(bk.Updated).Seconds()*b.rate())
+	}
+	d := Decision{Limit: b.Burst}
+	if tokens >= 1 {
+		tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = secondsDuration((1 - tokens) / b.rate())
+	}
+	d.Remaining = int(tokens)
+	d.Reset = secondsDuration((float64(b.Burst) - tokens) / b.rate())
+	return bucket{Tokens: tokens, Updated: now}, d
+}
+
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// RateLimitStore keeps the buckets.
+type RateLimitStore interface {
+	// Take spends a token from key's bucket under budget.
+	Take(key string, budget Budget, now time.Time) (Decision, error)
+	// Sweep forgets buckets that have refilled completely.
+	Sweep(now time.Time) error
+}
+
+var rateLimitStore RateLimitStore = newMemoryRateLimitStore()
+
+// ConfigureRateLimits picks the bucket store: RATE_LIMIT_STORE=postgres
+// shares buckets between instances through the database; the default keeps
+// them in memory, per instance.
+func ConfigureRateLimits() {
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+	case "postgres":
+		rateLimitStore = postgresRateLimitStore{}
+		slog.Info("Rate limits: using the Postgres store")
+	default:
+		slog.Warn("Invalid RATE_LIMIT_STORE, using memory", "value", store)
+	}
+}
+
+// memoryRateLimitStore keeps buckets in this process.
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]memoryBucket
+}
+
+type memoryBucket struct {
+	bucket
+	per time.Duration
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{buckets: make(map[string]memoryBucket)}
+}
+
+func (s *memoryRateLimitStore) Take(key string, budget Budget, now time.Time) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bk, d := budget.take(s.buckets[key].bucket, now)
+	s.buckets[key] = memoryBucket{bk, budget.Per}
+	return d, nil
+}
+
+func (s *memoryRateLimitStore) Sweep(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, bk := range s.buckets {
+		if now.Sub(bk.Updated) >= bk.per {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
+
+// postgresRateLimitStore keeps buckets in the rate_limit_buckets table, so
+// all instances share them.
+type postgresRateLimitStore struct{}
+
+func (postgresRateLimitStore) Take(key string, budget Budget, now time.Time) (Decision, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Decision{}, err
+	}
+	defer tx.Rollback()
+
+	var bk bucket
+	err = tx.QueryRow(`SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE`, key).
+		Scan(&bk.Tokens, &bk.Updated)
+	if err != nil && err != sql.ErrNoRows {
+		return Decision{}, err
+	}
+	bk, d := budget.take(bk, now)
+	_, err = tx.Exec(`
+		INSERT INTO rate_limit_buckets (key, tokens, updated_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET tokens = $2, updated_at = $3, expires_at = $4`,
+		key, bk.Tokens, bk.Updated, now.Add(budget.Per))
+	if err != nil {
+		return Decision{}, err
+	}
+	return d, tx.Commit()
+}
+
+func (postgresRateLimitStore) Sweep(now time.Time) error {
+	_, err := db.Exec("DELETE FROM rate_limit_buckets WHERE expires_at < $1", now)
+	return err
+}
+
+// RunSweepRateLimitsJob drops idle buckets from the store.
+func RunSweepRateLimitsJob() {
+	if err := rateLimitStore.Sweep(time.Now()); err != nil {
+		slog.ErrorContext(startJobRun(context.Background(), "sweep-rate-limits"), "Error sweeping rate limit buckets", "error", err)
+	}
+}
+
+// clientIP is the address rate limits are keyed by. Behind a proxy that sets
+// a trusted header with the client address (e.g. Fly-Client-IP), name it in
+// RATE_LIMIT_IP_HEADER; otherwise the connection's address is used, since
+// clients can forge forwarding headers.
+func clientIP(r *http.Request) string {
+	if header := os.Getenv("RATE_LIMIT_IP_HEADER"); header != "" {
+		if ip := strings.TrimSpace(strings.Split(r.Header.Get(header), ",")[0]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RateLimitByIP limits requests per client IP under budget.
+func RateLimitByIP(budget Budget) func(http.Handler) http.Handler {
+	return rateLimit(budget, clientIP)
+}
+
+// RateLimitByUser limits requests per authenticated user under budget. It
+// must run after authentication.
+func RateLimitByUser(budget Budget) func(http.Handler) http.Handler {
+	return rateLimit(budget, func(r *http.Request) string { return GetUserIDFromContext(r).String() })
+}
+
+// rateLimit spends a token from the bucket of the request's key, answering
+// 429 when it is empty. The RateLimit headers describe the most depleted
+// bucket the request spent from. If the store fails, requests go through.
+func rateLimit(budget Budget, keyOf func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d, err := rateLimitStore.Take(budget.Name+":"+keyOf(r), budget, time.Now())
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Rate limit store error, not limiting", "budget", budget.Name, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			setRateLimitHeaders(w.Header(), budget, d)
+			if !d.Allowed {
+				respondError(w, r, rateLimited(d.RetryAfter))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setRateLimitHeaders sets the RateLimit fields of the IETF httpapi draft,
+// unless an earlier limiter already reported a bucket with fewer requests
+// left.
+func setRateLimitHeaders(h http.Header, budget Budget, d Decision) {
+	if prev, err := strconv.Atoi(h.Get("RateLimit-Remaining")); err == nil && prev < d.Remaining {
+		return
+	}
+	h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(d.Reset.Seconds()))))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", budget.Burst, int(budget.Per.Seconds())))
+}
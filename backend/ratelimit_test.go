@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBudgetTake(t *testing.T) {
+	budget := Budget{Name: "test", Burst: 3, Per: time.Minute} // a token every 20s
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var bk bucket
+	var d Decision
+	for i := 2; i >= 0; i-- {
+		bk, d = budget.take(bk, now)
+		if !d.Allowed || d.Remaining != i {
+			t.Fatalf("request %d: %+v", 3-i, d)
+		}
+	}
+	if d.Reset != time.Minute {
+		t.Errorf("empty bucket resets in %v, want 1m", d.Reset)
+	}
+
+	bk, d = budget.take(bk, now.Add(5*time.Second))
+	if d.Allowed || d.RetryAfter != 15*time.Second {
+		t.Errorf("over budget: %+v", d)
+	}
+
+	// Refused requests don't spend: the token arrives on schedule
+	bk, d = budget.take(bk, now.Add(20*time.Second))
+	if !d.Allowed || d.Remaining != 0 {
+		t.Errorf("after refill: %+v", d)
+	}
+
+	// The bucket never holds more than Burst
+	_, d = budget.take(bk, now.Add(time.Hour))
+	if !d.Allowed || d.Remaining != 2 {
+		t.Errorf("after idling: %+v", d)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	previous := rateLimitStore
+	rateLimitStore = newMemoryRateLimitStore()
+	defer func() { rateLimitStore = previous }()
+
+	wide := Budget{Name: "wide", Burst: 10, Per: time.Minute}
+	narrow := Budget{Name: "narrow", Burst: 2, Per: time.Hour}
+	handler := RateLimitByIP(wide)(RateLimitByIP(narrow)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/test-email", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := serve("198.51.100.7:5000")
+	if first.Code != http.StatusNoContent {
+		t.Fatalf("first request: %d", first.Code)
+	}
+	// The headers describe the narrower budget, which has fewer requests left
+	h := first.Header()
+	if h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Remaining") != "1" || h.Get("RateLimit-Policy") != "2;w=3600" {
+		t.Errorf("headers: %v", h)
+	}
+
+	serve("198.51.100.7:5001")
+	limited := serve("198.51.100.7:5002")
+	if limited.Code != http.StatusTooManyRequests || limited.Header().Get("Retry-After") != "1800" {
+		t.Errorf("over budget: %d %v", limited.Code, limited.Header())
+	}
+	if other := serve("203.0.113.9:5000"); other.Code != http.StatusNoContent {
+		t.Errorf("another client was limited: %d", other.Code)
+	}
+
+	t.Setenv("RATE_LIMIT_IP_HEADER", "Fly-Client-IP")
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Fly-Client-IP", "192.0.2.44")
+	if ip := clientIP(req); ip != "192.0.2.44" {
+		t.Errorf("clientIP with a trusted header = %q", ip)
+	}
+}
+
+func TestMemoryRateLimitSweep(t *testing.T) {
+	store := newMemoryRateLimitStore()
+	now := time.Now()
+	store.Take("short:a", Budget{Name: "short", Burst: 1, Per: time.Minute}, now)
+	store.Take("long:a", Budget{Name: "long", Burst: 1, Per: time.Hour}, now)
+	store.Sweep(now.Add(2 * time.Minute))
+	if _, ok := store.buckets["short:a"]; ok {
+		t.Error("a refilled bucket was kept")
+	}
+	if _, ok := store.buckets["long:a"]; !ok {
+		t.Error("a partly spent bucket was dropped")
+	}
+}
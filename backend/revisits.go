@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// recomputeProblemAggregates rebuilds a problem's denormalized times_revisited
+// and last_revisited_at from revisit_history.
+func recomputeProblemAggregates(q querier, problemID uuid.UUID) error {
+	_, err := q.Exec(`
+		UPDATE problems p
+		SET times_revisited = agg.cnt, last_revisited_at = agg.last
+		FROM (
+			SELECT COUNT(*) AS cnt, MAX(revisited_at) AS last
+			FROM revisit_history
+			WHERE problem_id = $1
+		) agg
+		WHERE p.id = $1`, problemID)
+	return err
+}
+
+// ReconcileRevisitCounters recomputes times_revisited and last_revisited_at for
+// every problem whose counters have drifted from revisit_history.
+// Returns the number of problems that were corrected.
+func ReconcileRevisitCounters() (int64, error) {
+	result, err := db.Exec(`
+		UPDATE problems p
+		SET times_revisited = COALESCE(agg.cnt, 0), last_revisited_at = agg.last
+		FROM problems p2
+		LEFT JOIN (
+			SELECT problem_id, COUNT(*) AS cnt, MAX(revisited_at) AS last
+			FROM revisit_history
+			GROUP BY problem_id
+		) agg ON agg.problem_id = p2.id
+		WHERE p.id = p2.id
+		  AND (p.times_revisited IS DISTINCT FROM COALESCE(agg.cnt, 0)
+		       OR p.last_revisited_at IS DISTINCT FROM agg.last)`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RunReconcileJob is the entry point for -job reconcile.
+func RunReconcileJob() {
+	ctx := startJobRun(context.Background(), "reconcile")
+	fixed, err := ReconcileRevisitCounters()
+	if err != nil {
+		slog.ErrorContext(ctx, "Error reconciling revisit counters", "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "Corrected revisit counters", "problems", fixed)
+}
+
+// lockOwnedRevisit loads a revisit entry inside tx, checking that it belongs to
+// one of the user's problems, and locks the row for the rest of the transaction.
+func lockOwnedRevisit(tx *sql.Tx, revisitID, userID uuid.UUID) (RevisitEntry, error) {
+	var entry RevisitEntry
+	err := tx.QueryRow(`
+		SELECT rh.id, rh.problem_id, rh.revisited_at, rh.notes
+		FROM revisit_history rh
+		JOIN problems p ON p.id = rh.problem_id
+		WHERE rh.id = $1 AND p.user_id = $2
+		FOR UPDATE OF rh`, revisitID, userID).Scan(&entry.ID, &entry.ProblemID, &entry.RevisitedAt, &entry.Notes)
+	return entry, err
+}
+
+// UpdateRevisit edits a revisit's journal notes and/or timestamp.
+// Omitted fields are left unchanged; an empty notes string clears the notes.
+func UpdateRevisit(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	idStr := chi.URLParam(r, "id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return
+	}
+
+	var body struct {
+		Notes       *string    `json:"notes"`
+		RevisitedAt *time.Time `json:"revisited_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, r, invalidBody(err))
+		return
+	}
+	if body.RevisitedAt != nil && body.RevisitedAt.After(time.Now()) {
+		respondError(w, r, invalidField("body.revisited_at", "cannot be in the future"))
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	entry, err := lockOwnedRevisit(tx, id, userID)
+	if err == sql.ErrNoRows {
+		respondError(w, r, notFound("Revisit"))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if body.Notes != nil {
+		entry.Notes = body.Notes
+		if *body.Notes == "" {
+			entry.Notes = nil
+		}
+	}
+
+	if body.RevisitedAt != nil {
+		// Keep the one-revisit-per-day rule when moving an entry to another day
+		var sameDayCount int
+		err = tx.QueryRow(`
+			SELECT COUNT(*) FROM revisit_history
+			WHERE problem_id = $1 AND id <> $2 AND revisited_at::date = $3::timestamptz::date`,
+			entry.ProblemID, entry.ID, *body.RevisitedAt).Scan(&sameDayCount)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		if sameDayCount > 0 {
+			respondError(w, r, conflict("already_revisited_on_date", "This problem already has a revisit on that day.", nil))
+			return
+		}
+		entry.RevisitedAt = *body.RevisitedAt
+	}
+
+	_, err = tx.Exec(`
+		UPDATE revisit_history
+		SET notes = $1, revisited_at = $2
+		WHERE id = $3`, entry.Notes, entry.RevisitedAt, entry.ID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err := recomputeProblemAggregates(tx, entry.ProblemID); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	publishProblemEvent(userID, EventProblemUpdated, entry.ProblemID)
+	respondJSON(w, http.StatusOK, entry)
+}
+
+// DeleteRevisit removes a revisit (e.g. a mis-click) and recomputes the
+// problem's revisit counters.
+func DeleteRevisit(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	idStr := chi.URLParam(r, "id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	entry, err := lockOwnedRevisit(tx, id, userID)
+	if err == sql.ErrNoRows {
+		respondError(w, r, notFound("Revisit"))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if _, err := tx.Exec(`DELETE FROM revisit_history WHERE id = $1`, entry.ID); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err := recomputeProblemAggregates(tx, entry.ProblemID); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	publishProblemEvent(userID, EventProblemUpdated, entry.ProblemID)
+	respondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testRevisit records a revisit of problemID daysAgo days ago and brings the
+// problem's counters up to date.
+func testRevisit(t *testing.T, problemID uuid.UUID, daysAgo int) uuid.UUID {
+	t.Helper()
+	var id uuid.UUID
+	err := db.QueryRow(`
+		INSERT INTO revisit_history (problem_id, revisited_at)
+		VALUES ($1, NOW() - make_interval(days => $2))
+		RETURNING id`, problemID, daysAgo).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recomputeProblemAggregates(db, problemID); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// revisitCounters returns a problem's times_revisited and whether its
+// last_revisited_at matches the given revisit.
+func revisitCounters(t *testing.T, problemID, lastRevisitID uuid.UUID) (int, bool) {
+	t.Helper()
+	var times int
+	var matches bool
+	err := db.QueryRow(`
+		SELECT times_revisited,
+		       last_revisited_at IS NOT DISTINCT FROM (SELECT revisited_at FROM revisit_history WHERE id = $2)
+		FROM problems WHERE id = $1`, problemID, lastRevisitID).Scan(&times, &matches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return times, matches
+}
+
+func TestUpdateRevisitSameDayConflict(t *testing.T) {
+	userID := testUser(t)
+	problemID := testProblem(t, userID, "Two Sum", "https://example.com/two-sum")
+	testRevisit(t, problemID, 5)
+	moved := testRevisit(t, problemID, 2)
+
+	target := time.Now().AddDate(0, 0, -5).Format(time.RFC3339)
+	req := httptest.NewRequest("PUT", "/api/history/"+moved.String(),
+		strings.NewReader(fmt.Sprintf(`{"revisited_at": %q}`, target)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	newRouter(testAuth(userID)).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "already_revisited_on_date") {
+		t.Errorf("moving onto a day with a revisit: %d %s", rec.Code, rec.Body)
+	}
+	if times, latest := revisitCounters(t, problemID, moved); times != 2 || !latest {
+		t.Errorf("a refused move changed the counters: times %d, latest kept %v", times, latest)
+	}
+}
+
+func TestDeleteLatestRevisit(t *testing.T) {
+	userID := testUser(t)
+	problemID := testProblem(t, userID, "Two Sum", "https://example.com/two-sum")
+	earlier := testRevisit(t, problemID, 5)
+	latest := testRevisit(t, problemID, 1)
+
+	if rec := serveAs(userID, "DELETE", "/api/history/"+latest.String()); rec.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body)
+	}
+	if times, moved := revisitCounters(t, problemID, earlier); times != 1 || !moved {
+		t.Errorf("after deleting the latest revisit: times %d, last_revisited_at moved back %v", times, moved)
+	}
+}
+
+func TestReconcileRevisitCounters(t *testing.T) {
+	userID := testUser(t)
+	problemID := testProblem(t, userID, "Two Sum", "https://example.com/two-sum")
+	latest := testRevisit(t, problemID, 3)
+
+	if _, err := db.Exec(`UPDATE problems SET times_revisited = 7, last_revisited_at = NOW() WHERE id = $1`, problemID); err != nil {
+		t.Fatal(err)
+	}
+	fixed, err := ReconcileRevisitCounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed < 1 {
+		t.Errorf("ReconcileRevisitCounters fixed %d problems, want the drifted one", fixed)
+	}
+	if times, ok := revisitCounters(t, problemID, latest); times != 1 || !ok {
+		t.Errorf("after reconciling: times %d, last_revisited_at restored %v", times, ok)
+	}
+}
@@ -15,6 +15,9 @@ type ProblemWeight struct {
 	TimesRevisited       int     `json:"times_revisited"`
 	RevisitDecay         float64 `json:"revisit_decay"`
 	IsEligible           bool    `json:"is_eligible"`
+	EligibilityReason    string  `json:"eligibility_reason"` // see CheckEligibility
+	PriorityMultiplier   float64 `json:"priority_multiplier"`
+	Pinned               bool    `json:"pinned"`
 	Priority             string  `json:"priority"` // "high", "medium", "low"
 }
 
@@ -27,14 +30,40 @@ type ProblemWeight struct {
 //   - Recently added problems get a short cooldown so they don't spam immediately.
 //   - Minimum weight is always 1.0 — no problem is ever fully silenced.
 func CalculateWeight(p Problem) float64 {
-	daysSinceAdded := time.Since(p.DateAdded).Hours() / 24
+	return CalculateWeightAt(p, time.Now())
+}
+
+// WeightParams are the tunable constants of CalculateWeight.
+// The simulation job varies them to compare scheduling strategies.
+type WeightParams struct {
+	RevisitDecayRate    float64 `json:"revisit_decay_rate"`    // per-revisit decay (weight × 1/(1+rate·revisits))
+	NeverRevisitedBoost float64 `json:"never_revisited_boost"` // urgency multiplier on age for never-revisited problems
+	NewnessWindowDays   float64 `json:"newness_window_days"`   // cooldown window for recently added problems
+}
+
+// DefaultWeightParams are the constants used by the live scheduler.
+var DefaultWeightParams = WeightParams{
+	RevisitDecayRate:    0.3,
+	NeverRevisitedBoost: 1.5,
+	NewnessWindowDays:   2.0,
+}
+
+// CalculateWeightAt is CalculateWeight evaluated at an arbitrary point in time.
+// Used by the forecast to run the scheduler on a simulated calendar.
+func CalculateWeightAt(p Problem, now time.Time) float64 {
+	return CalculateWeightWith(p, now, DefaultWeightParams)
+}
+
+// CalculateWeightWith is CalculateWeightAt with explicit weight constants.
+func CalculateWeightWith(p Problem, now time.Time, params WeightParams) float64 {
+	daysSinceAdded := now.Sub(p.DateAdded).Hours() / 24
 
 	var daysSinceLastRevisited float64
 	if p.LastRevisitedAt.Valid {
-		daysSinceLastRevisited = time.Since(p.LastRevisitedAt.Time).Hours() / 24
+		daysSinceLastRevisited = now.Sub(p.LastRevisitedAt.Time).Hours() / 24
 	} else {
 		// Never revisited — give a boost: treat urgency as 1.5x the age
-		daysSinceLastRevisited = daysSinceAdded * 1.5
+		daysSinceLastRevisited = daysSinceAdded * params.NeverRevisitedBoost
 	}
 
 	// 1. Base age factor: sqrt so older problems gain priority with diminishing returns
@@ -45,17 +74,20 @@ func CalculateWeight(p Problem) float64 {
 
 	// 3. Revisit decay: problems slowly fade with revisits but never reach 0
 	//    At 0 revisits: 1.0, at 1: 0.77, at 3: 0.53, at 10: 0.25, at 20: 0.14
-	revisitDecay := 1.0 / (1.0 + 0.3*float64(p.TimesRevisited))
+	revisitDecay := 1.0 / (1.0 + params.RevisitDecayRate*float64(p.TimesRevisited))
 
 	// 4. Newness cooldown: problems added in the last 2 days get reduced weight
 	//    Day 0: 0.3, Day 1: 0.65, Day 2+: 1.0
 	newnessFactor := 1.0
-	if daysSinceAdded < 2.0 {
-		newnessFactor = 0.3 + (daysSinceAdded / 2.0 * 0.7)
+	if daysSinceAdded < params.NewnessWindowDays {
+		newnessFactor = 0.3 + (daysSinceAdded / params.NewnessWindowDays * 0.7)
 	}
 
-	// Final weight
+	// Final weight, scaled by the user's per-problem priority override
 	weight := (ageFactor + urgencyFactor) * revisitDecay * newnessFactor
+	if p.PriorityMultiplier > 0 {
+		weight *= p.PriorityMultiplier
+	}
 
 	// Minimum floor — no problem is ever fully silenced
 	if weight < 1.0 {
@@ -73,16 +105,18 @@ func CalculateProblemWeight(p Problem, minRevisitDays int) ProblemWeight {
 	if p.LastRevisitedAt.Valid {
 		daysSinceLastRevisited = time.Since(p.LastRevisitedAt.Time).Hours() / 24
 	} else {
-		daysSinceLastRevisited = daysSinceAdded * 1.5
+		daysSinceLastRevisited = daysSinceAdded * DefaultWeightParams.NeverRevisitedBoost
 	}
 
 	weight := CalculateWeight(p)
-	revisitDecay := 1.0 / (1.0 + 0.3*float64(p.TimesRevisited))
+	revisitDecay := 1.0 / (1.0 + DefaultWeightParams.RevisitDecayRate*float64(p.TimesRevisited))
 
-	// Determine eligibility based on min revisit days
-	isEligible := daysSinceLastRevisited >= float64(minRevisitDays)
-	if !p.LastRevisitedAt.Valid {
-		isEligible = true // Never revisited = always eligible
+	// Determine eligibility based on overrides and min revisit days
+	isEligible, reason := CheckEligibility(p, minRevisitDays, time.Now())
+
+	multiplier := p.PriorityMultiplier
+	if multiplier <= 0 {
+		multiplier = 1.0
 	}
 
 	// Priority classification
@@ -101,10 +135,69 @@ func CalculateProblemWeight(p Problem, minRevisitDays int) ProblemWeight {
 		TimesRevisited:       p.TimesRevisited,
 		RevisitDecay:         math.Round(revisitDecay*100) / 100,
 		IsEligible:           isEligible,
+		EligibilityReason:    reason,
+		PriorityMultiplier:   multiplier,
+		Pinned:               p.Pinned,
 		Priority:             priority,
 	}
 }
 
+// CheckEligibility decides whether a problem can be scheduled at `now` and why.
+// In order of precedence:
+//   - "on_hold": hold_until is in the future — nothing overrides a hold.
+//   - "pinned": pinned problems are always eligible until revisited.
+//   - "snoozed": snoozed_until is in the future.
+//   - "never_revisited": never-revisited problems are always eligible.
+//   - "due" / "too_recent": whether min_revisit_days have passed since the last revisit.
+func CheckEligibility(p Problem, minRevisitDays int, now time.Time) (bool, string) {
+	if p.HoldUntil.Valid && now.Before(p.HoldUntil.Time) {
+		return false, "on_hold"
+	}
+	if p.Pinned {
+		return true, "pinned"
+	}
+	if p.SnoozedUntil.Valid && now.Before(p.SnoozedUntil.Time) {
+		return false, "snoozed"
+	}
+	if !p.LastRevisitedAt.Valid {
+		return true, "never_revisited"
+	}
+	if now.Sub(p.LastRevisitedAt.Time).Hours()/24 >= float64(minRevisitDays) {
+		return true, "due"
+	}
+	return false, "too_recent"
+}
+
+// FilterEligible returns the problems that CheckEligibility allows at `now`.
+func FilterEligible(problems []Problem, minRevisitDays int, now time.Time) []Problem {
+	var eligible []Problem
+	for _, p := range problems {
+		if ok, _ := CheckEligibility(p, minRevisitDays, now); ok {
+			eligible = append(eligible, p)
+		}
+	}
+	return eligible
+}
+
+// SelectFocus builds a day's focus from eligible problems: every pinned problem
+// is included first, and the remaining budget is filled by weighted selection.
+func SelectFocus(eligible []Problem, n int, seed int64, now time.Time) []Problem {
+	var pinned, rest []Problem
+	for _, p := range eligible {
+		if p.Pinned {
+			pinned = append(pinned, p)
+		} else {
+			rest = append(rest, p)
+		}
+	}
+
+	remaining := n - len(pinned)
+	if remaining <= 0 {
+		return pinned
+	}
+	return append(pinned, SelectProblemsAt(rest, remaining, seed, now)...)
+}
+
 // SelectProblems picks n problems based on weighted randomness
 func SelectProblems(problems []Problem, n int) []Problem {
 	return SelectProblemsSeeded(problems, n, time.Now().UnixNano())
@@ -114,6 +207,19 @@ func SelectProblems(problems []Problem, n int) []Problem {
 // Using the same seed with the same input always produces the same selection.
 // This is used for "Today's Focus" so the dashboard is stable across page refreshes.
 func SelectProblemsSeeded(problems []Problem, n int, seed int64) []Problem {
+	return SelectProblemsAt(problems, n, seed, time.Now())
+}
+
+// SelectProblemsAt is SelectProblemsSeeded with weights evaluated at the given time.
+func SelectProblemsAt(problems []Problem, n int, seed int64, now time.Time) []Problem {
+	return SelectProblemsWeighted(problems, n, seed, func(p Problem) float64 {
+		return CalculateWeightAt(p, now)
+	})
+}
+
+// SelectProblemsWeighted picks n problems by weighted random sampling without
+// replacement, using the given weight function.
+func SelectProblemsWeighted(problems []Problem, n int, seed int64, weight func(Problem) float64) []Problem {
 	if len(problems) <= n {
 		return problems
 	}
@@ -122,12 +228,18 @@ func SelectProblemsSeeded(problems []Problem, n int, seed int64) []Problem {
 	remaining := make([]Problem, len(problems))
 	copy(remaining, problems)
 
+	// Weights don't change while picking, so compute them once
+	weights := make([]float64, len(remaining))
+	for i, p := range remaining {
+		weights[i] = weight(p)
+	}
+
 	r := rand.New(rand.NewSource(seed))
 
 	for i := 0; i < n && len(remaining) > 0; i++ {
 		totalWeight := 0.0
-		for _, p := range remaining {
-			totalWeight += CalculateWeight(p)
+		for _, w := range weights {
+			totalWeight += w
 		}
 
 		if totalWeight == 0 {
@@ -135,16 +247,18 @@ func SelectProblemsSeeded(problems []Problem, n int, seed int64) []Problem {
 			idx := r.Intn(len(remaining))
 			selected = append(selected, remaining[idx])
 			remaining = append(remaining[:idx], remaining[idx+1:]...)
+			weights = append(weights[:idx], weights[idx+1:]...)
 			continue
 		}
 
 		value := r.Float64() * totalWeight
 		cumulative := 0.0
 		for j, p := range remaining {
-			cumulative += CalculateWeight(p)
+			cumulative += weights[j]
 			if cumulative >= value {
 				selected = append(selected, p)
 				remaining = append(remaining[:j], remaining[j+1:]...)
+				weights = append(weights[:j], weights[j+1:]...)
 				break
 			}
 		}
@@ -156,7 +270,11 @@ func SelectProblemsSeeded(problems []Problem, n int, seed int64) []Problem {
 // DaySeed returns a deterministic seed for the current calendar day.
 // Same date → same seed → same "Today's Focus" selection.
 func DaySeed() int64 {
-	now := time.Now()
+	return DaySeedFor(time.Now())
+}
+
+// DaySeedFor returns the deterministic seed for the calendar day of t.
+func DaySeedFor(now time.Time) int64 {
 	return int64(now.Year())*10000 + int64(now.Month())*100 + int64(now.Day())
 }
 
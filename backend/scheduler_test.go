@@ -181,3 +181,77 @@ func TestSelectProblems_ReturnsExactN(t *testing.T) {
 		t.Errorf("should return exactly 3 problems, got %d", len(selected))
 	}
 }
+
+// ── FilterEligible tests ──────────────────────────────────────────────
+
+func TestFilterEligible_RespectsMinRevisitDays(t *testing.T) {
+	problems := []Problem{
+		makeProblem(10, 1, 2),  // revisited yesterday → not eligible with min=3
+		makeProblem(10, 5, 2),  // revisited 5 days ago → eligible
+		makeProblem(10, -1, 0), // never revisited → eligible
+	}
+
+	eligible := FilterEligible(problems, 3, time.Now())
+	if len(eligible) != 2 {
+		t.Fatalf("expected 2 eligible problems, got %d", len(eligible))
+	}
+	for _, p := range eligible {
+		if p.LastRevisitedAt.Valid && time.Since(p.LastRevisitedAt.Time) < 3*24*time.Hour {
+			t.Errorf("problem revisited %v ago should not be eligible", time.Since(p.LastRevisitedAt.Time))
+		}
+	}
+}
+
+// ── Override tests ────────────────────────────────────────────────────
+
+func TestCalculateWeight_PriorityMultiplier(t *testing.T) {
+	base := makeProblem(30, 10, 2)
+	boosted := base
+	boosted.PriorityMultiplier = 2.0
+
+	if wb, wx := CalculateWeight(base), CalculateWeight(boosted); wx <= wb {
+		t.Errorf("multiplier 2.0 should increase weight: base=%f boosted=%f", wb, wx)
+	}
+}
+
+func TestCheckEligibility_Overrides(t *testing.T) {
+	now := time.Now()
+	future := NullTime{sql.NullTime{Time: now.Add(48 * time.Hour), Valid: true}}
+
+	snoozed := makeProblem(30, 10, 2)
+	snoozed.SnoozedUntil = future
+	if ok, reason := CheckEligibility(snoozed, 2, now); ok || reason != "snoozed" {
+		t.Errorf("snoozed problem: got eligible=%v reason=%s", ok, reason)
+	}
+
+	// Pinned overrides min_revisit_days (revisited yesterday, min=3)
+	pinned := makeProblem(30, 1, 2)
+	pinned.Pinned = true
+	if ok, reason := CheckEligibility(pinned, 3, now); !ok || reason != "pinned" {
+		t.Errorf("pinned problem: got eligible=%v reason=%s", ok, reason)
+	}
+
+	// A hold wins even over a pin
+	pinned.HoldUntil = future
+	if ok, reason := CheckEligibility(pinned, 3, now); ok || reason != "on_hold" {
+		t.Errorf("held problem: got eligible=%v reason=%s", ok, reason)
+	}
+}
+
+func TestSelectFocus_PinnedFirst(t *testing.T) {
+	problems := []Problem{
+		makeProblem(10, -1, 0),
+		makeProblem(20, 5, 1),
+		makeProblem(30, 10, 2),
+		makeProblem(2, 1, 0),
+	}
+	problems[3].Pinned = true
+
+	selected := SelectFocus(problems, 2, 42, time.Now())
+	if len(selected) != 2 {
+		t.Fatalf("expected 2 problems, got %d", len(selected))
+	}
+	if !selected[0].Pinned {
+		t.Errorf("pinned problem should be selected first")
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Search result limits for GET /search.
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Fields a search hit can come from.
+const (
+	searchFieldTitle        = "title"
+	searchFieldNotes        = "notes"
+	searchFieldRevisitNotes = "revisit_notes"
+)
+
+// headlineOptions wraps matched terms in <mark> tags. Fragments keep notes
+// snippets short; titles are short enough to be returned whole.
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2, FragmentDelimiter=\" … \""
+
+// SearchHit is one ranked match. A problem can appear several times if the
+// query matches more than one of its fields or revisit entries.
+type SearchHit struct {
+	ProblemID   uuid.UUID  `json:"problem_id"`
+	Title       string     `json:"title"`
+	Link        string     `json:"link"`
+	Difficulty  string     `json:"difficulty"`
+	Topic       string     `json:"topic"`
+	Status      string     `json:"status"`
+	Field       string     `json:"field"` // title, notes or revisit_notes
+	RevisitID   *uuid.UUID `json:"revisit_id,omitempty"`
+	RevisitedAt *time.Time `json:"revisited_at,omitempty"`
+	Snippet     string     `json:"snippet"` // HTML-escaped, matches wrapped in <mark>
+	Rank        float64    `json:"rank"`
+}
+
+// SearchResponse is the body of GET /search.
+type SearchResponse struct {
+	Query   string      `json:"query"`
+	Results []SearchHit `json:"results"`
+}
+
+// escapeSnippet HTML-escapes a ts_headline snippet while keeping the <mark>
+// tags it inserted, so clients can render it as HTML safely.
+func escapeSnippet(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, "&lt;mark&gt;", "<mark>")
+	return strings.ReplaceAll(s, "&lt;/mark&gt;", "</mark>")
+}
+
+// SearchProblems runs a full-text search over problem titles, problem notes and
+// revisit journal notes. The query uses web search syntax ("quoted phrases",
+// or, -exclude). Title matches are ranked above notes matches.
+// Query params: q (required), limit (default 20, max 100).
+func SearchProblems(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		respondError(w, r, invalidField("query.q", "is required"))
+		return
+	}
+
+	limit := defaultSearchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			respondError(w, r, invalidField("query.limit", "must be between 1 and 100"))
+			return
+		}
+		limit = n
+	}
+
+	rows, err := db.Query(`
+		WITH q AS (SELECT websearch_to_tsquery('english', $2) AS query)
+		SELECT * FROM (
+			SELECT p.id, p.title, p.link, COALESCE(p.difficulty, ''), COALESCE(p.topic, ''), p.status,
+			       '`+searchFieldTitle+`' AS field, NULL::uuid AS revisit_id, NULL::timestamptz AS revisited_at,
+			       ts_headline('english', p.title, q.query, 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>') AS snippet,
+			       ts_rank(p.title_tsv, q.query) * 2 AS rank
+			FROM problems p, q
+			WHERE p.user_id = $1 AND p.status <> 'trashed' AND p.title_tsv @@ q.query
+
+			UNION ALL
+
+			SELECT p.id, p.title, p.link, COALESCE(p.difficulty, ''), COALESCE(p.topic, ''), p.status,
+			       '`+searchFieldNotes+`', NULL::uuid, NULL::timestamptz,
+			       ts_headline('english', p.notes, q.query, $3),
+			       ts_rank(p.notes_tsv, q.query)
+			FROM problems p, q
+			WHERE p.user_id = $1 AND p.status <> 'trashed' AND p.notes_tsv @@ q.query
+
+			UNION ALL
+
+			SELECT p.id, p.title, p.link, COALESCE(p.difficulty, ''), COALESCE(p.topic, ''), p.status,
+			       '`+searchFieldRevisitNotes+`', rh.id, rh.revisited_at,
+			       ts_headline('english', rh.notes, q.query, $3),
+			       ts_rank(rh.notes_tsv, q.query)
+			FROM revisit_history rh
+			JOIN problems p ON p.id = rh.problem_id, q
+			WHERE p.user_id = $1 AND p.status <> 'trashed' AND rh.notes_tsv @@ q.query
+		) hits
+		ORDER BY rank DESC, revisited_at DESC NULLS LAST, id
+		LIMIT $4`, userID, query, headlineOptions, limit)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	results := []SearchHit{}
+	for rows.Next() {
+		var h SearchHit
+		if err := rows.Scan(&h.ProblemID, &h.Title, &h.Link, &h.Difficulty, &h.Topic, &h.Status,
+			&h.Field, &h.RevisitID, &h.RevisitedAt, &h.Snippet, &h.Rank); err != nil {
+			respondError(w, r, err)
+			return
+		}
+		h.Snippet = escapeSnippet(h.Snippet)
+		results = append(results, h)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, SearchResponse{Query: query, Results: results})
+}
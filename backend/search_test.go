@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestEscapeSnippet(t *testing.T) {
+	got := escapeSnippet(`use a <mark>monotonic</mark> stack & pop while a[i] > top`)
+	want := `use a <mark>monotonic</mark> stack &amp; pop while a[i] &gt; top`
+	if got != want {
+		t.Errorf("escapeSnippet = %q, want %q", got, want)
+	}
+
+	// Tags written by the user are escaped; only ts_headline's markers survive.
+	if got := escapeSnippet(`<script>x</script>`); got != `&lt;script&gt;x&lt;/script&gt;` {
+		t.Errorf("escapeSnippet did not escape user HTML: %q", got)
+	}
+}
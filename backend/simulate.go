@@ -0,0 +1,531 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SimulationOptions configures the offline scheduler simulation (-job simulate).
+type SimulationOptions struct {
+	User       string  // user UUID or email whose problems/history are replayed
+	Synthetic  int     // number of synthetic problems to generate instead of loading a user
+	Days       int     // simulated calendar length; 0 = user's history span (or 90 for synthetic)
+	Strategies string  // strategy specs, see ParseStrategies
+	Format     string  // json, csv or csv-problems
+	Seed       int64   // base seed for selection and synthetic data
+	Completion float64 // probability that a selected problem is actually revisited
+	Out        string  // output file; empty = stdout
+}
+
+// SimStrategy is one scheduling strategy to compare.
+type SimStrategy struct {
+	Name           string       `json:"name"`
+	Kind           string       `json:"kind"` // "weighted", "lru" or "actual"
+	Params         WeightParams `json:"params"`
+	ProblemsPerDay int          `json:"problems_per_day"`
+}
+
+// SimProblemReport is the per-problem outcome of a simulated strategy.
+type SimProblemReport struct {
+	ProblemID  uuid.UUID `json:"problem_id"`
+	Title      string    `json:"title"`
+	AddedDay   int       `json:"added_day"`
+	Revisits   int       `json:"revisits"`
+	MaxGapDays int       `json:"max_gap_days"`
+	Starved    bool      `json:"starved"`
+}
+
+// SimDistribution summarizes how revisits are spread across problems.
+type SimDistribution struct {
+	Min  int     `json:"min"`
+	P50  int     `json:"p50"`
+	P90  int     `json:"p90"`
+	Max  int     `json:"max"`
+	Mean float64 `json:"mean"`
+}
+
+// SimReport is the result of running one strategy over the simulated calendar.
+type SimReport struct {
+	Strategy            SimStrategy        `json:"strategy"`
+	Days                int                `json:"days"`
+	Problems            int                `json:"problems"`
+	TotalRevisits       int                `json:"total_revisits"`
+	Coverage            float64            `json:"coverage"` // share of problems revisited at least once
+	MaxGapDays          int                `json:"max_gap_days"`
+	MeanGapDays         float64            `json:"mean_gap_days"`
+	StarvedProblems     int                `json:"starved_problems"`     // problems that ever went past max_revisit_days
+	StarvedProblemDays  int                `json:"starved_problem_days"` // problem-days spent past max_revisit_days
+	StarvationRate      float64            `json:"starvation_rate"`      // starved problem-days / live problem-days
+	RevisitDistribution SimDistribution    `json:"revisit_distribution"`
+	PerProblem          []SimProblemReport `json:"per_problem"`
+}
+
+// simInput is the dataset a simulation runs on.
+type simInput struct {
+	problems []Problem
+	history  map[uuid.UUID][]time.Time // recorded revisits, only for real users
+	prefs    UserPreferences
+	start    time.Time
+	days     int
+}
+
+// ParseStrategies parses a ";"-separated list of strategy specs of the form
+// name[:key=value,...]. Base names are "weighted" (the live scheduler) and
+// "lru" (least recently revisited first). Keys: decay, boost, newness, per_day.
+// Example: "weighted;weighted:decay=0.5,newness=1;lru:per_day=5"
+func ParseStrategies(spec string) ([]SimStrategy, error) {
+	var strategies []SimStrategy
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		kind, rawParams, _ := strings.Cut(part, ":")
+		if kind == "default" {
+			kind = "weighted"
+		}
+		if kind != "weighted" && kind != "lru" {
+			return nil, fmt.Errorf("unknown strategy %q", kind)
+		}
+
+		s := SimStrategy{Name: part, Kind: kind, Params: DefaultWeightParams}
+		if rawParams != "" {
+			for _, kv := range strings.Split(rawParams, ",") {
+				key, value, ok := strings.Cut(kv, "=")
+				if !ok {
+					return nil, fmt.Errorf("strategy %q: expected key=value, got %q", part, kv)
+				}
+				f, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, fmt.Errorf("strategy %q: invalid value for %s: %v", part, key, err)
+				}
+				switch key {
+				case "decay":
+					s.Params.RevisitDecayRate = f
+				case "boost":
+					s.Params.NeverRevisitedBoost = f
+				case "newness":
+					s.Params.NewnessWindowDays = f
+				case "per_day":
+					s.ProblemsPerDay = int(f)
+				default:
+					return nil, fmt.Errorf("strategy %q: unknown parameter %q", part, key)
+				}
+			}
+		}
+		strategies = append(strategies, s)
+	}
+
+	if len(strategies) == 0 {
+		return nil, fmt.Errorf("no strategies given")
+	}
+	return strategies, nil
+}
+
+// RunSimulateJob is the entry point for -job simulate.
+func RunSimulateJob(opts SimulationOptions) error {
+	strategies, err := ParseStrategies(opts.Strategies)
+	if err != nil {
+		return err
+	}
+	if opts.Completion <= 0 || opts.Completion > 1 {
+		return fmt.Errorf("completion must be in (0, 1], got %v", opts.Completion)
+	}
+	// Checked up front: writing starts by truncating -out
+	switch opts.Format {
+	case "", "json", "csv", "csv-problems":
+	default:
+		return fmt.Errorf("unknown format %q (want json, csv or csv-problems)", opts.Format)
+	}
+
+	var input simInput
+	switch {
+	case opts.Synthetic > 0:
+		days := opts.Days
+		if days <= 0 {
+			days = 90
+		}
+		input = syntheticInput(opts.Synthetic, days, opts.Seed)
+	case opts.User != "":
+		InitDB()
+		input, err = loadUserInput(opts.User, opts.Days)
+		if err != nil {
+			return err
+		}
+		// Replaying a real user also reports what actually happened
+		strategies = append([]SimStrategy{{Name: "actual", Kind: "actual"}}, strategies...)
+	default:
+		return fmt.Errorf("either -user or -synthetic is required")
+	}
+
+	slog.Info("Simulating", "problems", len(input.problems), "days", input.days, "strategies", len(strategies))
+
+	var reports []SimReport
+	for _, s := range strategies {
+		reports = append(reports, runStrategy(input, s, opts.Seed, opts.Completion))
+	}
+
+	var out io.Writer = os.Stdout
+	if opts.Out != "" {
+		f, err := os.Create(opts.Out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	return writeSimReports(out, opts.Format, reports)
+}
+
+// syntheticInput generates n never-revisited problems added at random days
+// across the first half of the calendar.
+func syntheticInput(n, days int, seed int64) simInput {
+	r := rand.New(rand.NewSource(seed))
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	problems := make([]Problem, n)
+	for i := range problems {
+		addedDay := r.Intn(days/2 + 1)
+		problems[i] = Problem{
+			ID:        uuid.NewSHA1(uuid.NameSpaceOID, []byte(fmt.Sprintf("synthetic-%d-%d", seed, i))),
+			Title:     fmt.Sprintf("Synthetic problem %d", i+1),
+			DateAdded: start.AddDate(0, 0, addedDay),
+			Status:    "active",
+		}
+	}
+
+	return simInput{
+		problems: problems,
+		prefs:    UserPreferences{ProblemsPerDay: 3, MinRevisitDays: 2, MaxRevisitDays: 10},
+		start:    start,
+		days:     days,
+	}
+}
+
+// loadUserInput loads a user's active problems and revisit history. The calendar
+// starts on the day the first problem was added.
+func loadUserInput(userRef string, days int) (simInput, error) {
+	var input simInput
+	var userID uuid.UUID
+
+	query := "SELECT id, preferences FROM users WHERE email = $1"
+	if _, err := uuid.Parse(userRef); err == nil {
+		query = "SELECT id, preferences FROM users WHERE id = $1"
+	}
+	if err := db.QueryRow(query, userRef).Scan(&userID, &input.prefs); err != nil {
+		return input, fmt.Errorf("user %s: %w", userRef, err)
+	}
+
+	rows, err := db.Query(`
+		SELECT id, title, date_added
+		FROM problems
+		WHERE user_id = $1 AND status = 'active'
+		ORDER BY date_added ASC`, userID)
+	if err != nil {
+		return input, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		p := Problem{UserID: userID, Status: "active"}
+		if err := rows.Scan(&p.ID, &p.Title, &p.DateAdded); err != nil {
+			return input, err
+		}
+		input.problems = append(input.problems, p)
+	}
+	if len(input.problems) == 0 {
+		return input, fmt.Errorf("user %s has no active problems", userRef)
+	}
+
+	histRows, err := db.Query(`
+		SELECT rh.problem_id, rh.revisited_at
+		FROM revisit_history rh
+		JOIN problems p ON p.id = rh.problem_id
+		WHERE p.user_id = $1 AND p.status = 'active'
+		ORDER BY rh.revisited_at ASC`, userID)
+	if err != nil {
+		return input, err
+	}
+	defer histRows.Close()
+	input.history = make(map[uuid.UUID][]time.Time)
+	for histRows.Next() {
+		var id uuid.UUID
+		var at time.Time
+		if err := histRows.Scan(&id, &at); err != nil {
+			return input, err
+		}
+		input.history[id] = append(input.history[id], at)
+	}
+
+	first := input.problems[0].DateAdded
+	input.start = time.Date(first.Year(), first.Month(), first.Day(), 12, 0, 0, 0, first.Location())
+	input.days = days
+	if input.days <= 0 {
+		input.days = int(time.Since(input.start).Hours()/24) + 1
+	}
+	return input, nil
+}
+
+// dayIndex converts a timestamp to a day offset on the simulated calendar.
+func dayIndex(start, t time.Time) int {
+	startDay := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	return int(math.Floor(t.In(start.Location()).Sub(startDay).Hours() / 24))
+}
+
+// runStrategy simulates one strategy and computes its report.
+func runStrategy(input simInput, s SimStrategy, seed int64, completion float64) SimReport {
+	perDay := s.ProblemsPerDay
+	if perDay <= 0 {
+		perDay = input.prefs.ProblemsPerDay
+	}
+	s.ProblemsPerDay = perDay
+
+	state := make([]Problem, len(input.problems))
+	copy(state, input.problems)
+	index := make(map[uuid.UUID]int, len(state))
+	addedDays := make([]int, len(state))
+	for i, p := range state {
+		index[p.ID] = i
+		addedDays[i] = dayIndex(input.start, p.DateAdded)
+	}
+	revisitDays := make([][]int, len(state))
+
+	if s.Kind == "actual" {
+		for i, p := range state {
+			for _, at := range input.history[p.ID] {
+				if d := dayIndex(input.start, at); d < input.days {
+					revisitDays[i] = append(revisitDays[i], d)
+				}
+			}
+		}
+		return buildSimReport(input, s, addedDays, revisitDays)
+	}
+
+	completionRand := rand.New(rand.NewSource(seed))
+	for d := 0; d < input.days; d++ {
+		now := input.start.AddDate(0, 0, d)
+
+		// Like the live scheduler, only problems added before today are candidates
+		var candidates []Problem
+		for i, p := range state {
+			if addedDays[i] < d {
+				candidates = append(candidates, p)
+			}
+		}
+		eligible := FilterEligible(candidates, input.prefs.MinRevisitDays, now)
+
+		var selected []Problem
+		switch s.Kind {
+		case "lru":
+			selected = selectLeastRecent(eligible, perDay)
+		default:
+			params := s.Params
+			selected = SelectProblemsWeighted(eligible, perDay, seed+DaySeedFor(now), func(p Problem) float64 {
+				return CalculateWeightWith(p, now, params)
+			})
+		}
+
+		for _, p := range selected {
+			if completionRand.Float64() >= completion {
+				continue
+			}
+			i := index[p.ID]
+			state[i].TimesRevisited++
+			state[i].LastRevisitedAt = NullTime{sql.NullTime{Time: now, Valid: true}}
+			revisitDays[i] = append(revisitDays[i], d)
+		}
+	}
+
+	return buildSimReport(input, s, addedDays, revisitDays)
+}
+
+// selectLeastRecent picks the n problems that have gone longest without a revisit.
+func selectLeastRecent(problems []Problem, n int) []Problem {
+	sorted := make([]Problem, len(problems))
+	copy(sorted, problems)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return lastTouched(sorted[i]).Before(lastTouched(sorted[j]))
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// lastTouched is the last revisit time, or the date added if never revisited.
+func lastTouched(p Problem) time.Time {
+	if p.LastRevisitedAt.Valid {
+		return p.LastRevisitedAt.Time
+	}
+	return p.DateAdded
+}
+
+// buildSimReport computes gap, coverage and starvation metrics from revisit days.
+// Gaps run from the day a problem was added to its first revisit, between
+// consecutive revisits, and from the last revisit to the end of the calendar.
+// The mean only counts closed gaps; the max also includes the open one.
+func buildSimReport(input simInput, s SimStrategy, addedDays []int, revisitDays [][]int) SimReport {
+	report := SimReport{
+		Strategy:   s,
+		Days:       input.days,
+		Problems:   len(input.problems),
+		PerProblem: make([]SimProblemReport, 0, len(input.problems)),
+	}
+
+	maxDays := input.prefs.MaxRevisitDays
+	covered := 0
+	closedGaps, closedGapSum := 0, 0
+	liveDays := 0
+	counts := make([]int, 0, len(input.problems))
+
+	for i, p := range input.problems {
+		added := addedDays[i]
+		events := revisitDays[i]
+
+		pr := SimProblemReport{ProblemID: p.ID, Title: p.Title, AddedDay: added, Revisits: len(events)}
+		if len(events) > 0 {
+			covered++
+		}
+
+		last := added
+		for _, d := range events {
+			gap := d - last
+			closedGaps++
+			closedGapSum += gap
+			if gap > pr.MaxGapDays {
+				pr.MaxGapDays = gap
+			}
+			last = d
+		}
+		if open := input.days - 1 - last; open > pr.MaxGapDays {
+			pr.MaxGapDays = open
+		}
+
+		// Walk the calendar to count days spent past max_revisit_days
+		next, last := 0, added
+		for d := added + 1; d < input.days; d++ {
+			for next < len(events) && events[next] <= d {
+				last = events[next]
+				next++
+			}
+			liveDays++
+			if d-last > maxDays {
+				report.StarvedProblemDays++
+				pr.Starved = true
+			}
+		}
+		if pr.Starved {
+			report.StarvedProblems++
+		}
+
+		if pr.MaxGapDays > report.MaxGapDays {
+			report.MaxGapDays = pr.MaxGapDays
+		}
+		report.TotalRevisits += len(events)
+		counts = append(counts, len(events))
+		report.PerProblem = append(report.PerProblem, pr)
+	}
+
+	if report.Problems > 0 {
+		report.Coverage = round2(float64(covered) / float64(report.Problems))
+	}
+	if closedGaps > 0 {
+		report.MeanGapDays = round2(float64(closedGapSum) / float64(closedGaps))
+	}
+	if liveDays > 0 {
+		report.StarvationRate = round2(float64(report.StarvedProblemDays) / float64(liveDays))
+	}
+	report.RevisitDistribution = distribution(counts)
+	return report
+}
+
+// distribution summarizes a list of per-problem revisit counts.
+func distribution(counts []int) SimDistribution {
+	if len(counts) == 0 {
+		return SimDistribution{}
+	}
+	sorted := make([]int, len(counts))
+	copy(sorted, counts)
+	sort.Ints(sorted)
+
+	sum := 0
+	for _, c := range sorted {
+		sum += c
+	}
+	percentile := func(q float64) int {
+		return sorted[int(q*float64(len(sorted)-1))]
+	}
+	return SimDistribution{
+		Min:  sorted[0],
+		P50:  percentile(0.5),
+		P90:  percentile(0.9),
+		Max:  sorted[len(sorted)-1],
+		Mean: round2(float64(sum) / float64(len(sorted))),
+	}
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// writeSimReports renders the reports as JSON, a CSV summary (one row per
+// strategy) or a per-problem CSV (one row per strategy and problem).
+func writeSimReports(out io.Writer, format string, reports []SimReport) error {
+	switch format {
+	case "", "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reports)
+
+	case "csv":
+		w := csv.NewWriter(out)
+		w.Write([]string{"strategy", "problems_per_day", "days", "problems", "total_revisits", "coverage",
+			"max_gap_days", "mean_gap_days", "starved_problems", "starved_problem_days", "starvation_rate",
+			"revisits_min", "revisits_p50", "revisits_p90", "revisits_max", "revisits_mean"})
+		for _, r := range reports {
+			d := r.RevisitDistribution
+			w.Write([]string{
+				r.Strategy.Name, strconv.Itoa(r.Strategy.ProblemsPerDay), strconv.Itoa(r.Days), strconv.Itoa(r.Problems),
+				strconv.Itoa(r.TotalRevisits), ftoa(r.Coverage), strconv.Itoa(r.MaxGapDays), ftoa(r.MeanGapDays),
+				strconv.Itoa(r.StarvedProblems), strconv.Itoa(r.StarvedProblemDays), ftoa(r.StarvationRate),
+				strconv.Itoa(d.Min), strconv.Itoa(d.P50), strconv.Itoa(d.P90), strconv.Itoa(d.Max), ftoa(d.Mean),
+			})
+		}
+		w.Flush()
+		return w.Error()
+
+	case "csv-problems":
+		w := csv.NewWriter(out)
+		w.Write([]string{"strategy", "problem_id", "title", "added_day", "revisits", "max_gap_days", "starved"})
+		for _, r := range reports {
+			for _, p := range r.PerProblem {
+				w.Write([]string{
+					r.Strategy.Name, p.ProblemID.String(), p.Title, strconv.Itoa(p.AddedDay),
+					strconv.Itoa(p.Revisits), strconv.Itoa(p.MaxGapDays), strconv.FormatBool(p.Starved),
+				})
+			}
+		}
+		w.Flush()
+		return w.Error()
+	}
+
+	return fmt.Errorf("unknown format %q (want json, csv or csv-problems)", format)
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseStrategies(t *testing.T) {
+	strategies, err := ParseStrategies("weighted; weighted:decay=0.5,per_day=4 ;lru")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(strategies) != 3 {
+		t.Fatalf("expected 3 strategies, got %d", len(strategies))
+	}
+	if strategies[0].Params != DefaultWeightParams {
+		t.Errorf("plain weighted strategy should use the default params, got %+v", strategies[0].Params)
+	}
+	if strategies[1].Params.RevisitDecayRate != 0.5 || strategies[1].ProblemsPerDay != 4 {
+		t.Errorf("params not applied: %+v", strategies[1])
+	}
+	if strategies[2].Kind != "lru" {
+		t.Errorf("expected lru, got %s", strategies[2].Kind)
+	}
+
+	if _, err := ParseStrategies("weighted:bogus=1"); err == nil {
+		t.Errorf("expected an error for an unknown parameter")
+	}
+}
+
+func TestBuildSimReport_GapsAndStarvation(t *testing.T) {
+	input := simInput{
+		problems: []Problem{{Title: "a"}, {Title: "b"}},
+		prefs:    UserPreferences{MaxRevisitDays: 5},
+		start:    time.Now(),
+		days:     20,
+	}
+	// "a" is revisited every 4 days; "b" is never revisited after day 0.
+	addedDays := []int{0, 0}
+	revisitDays := [][]int{{4, 8, 12, 16}, nil}
+
+	report := buildSimReport(input, SimStrategy{Name: "test"}, addedDays, revisitDays)
+
+	if report.Coverage != 0.5 {
+		t.Errorf("expected coverage 0.5, got %v", report.Coverage)
+	}
+	if report.MaxGapDays != 19 {
+		t.Errorf("expected max gap 19 (b never revisited), got %d", report.MaxGapDays)
+	}
+	if report.MeanGapDays != 4 {
+		t.Errorf("expected mean closed gap 4, got %v", report.MeanGapDays)
+	}
+	if report.StarvedProblems != 1 || report.PerProblem[0].Starved {
+		t.Errorf("only b should be starved, got %d starved problems", report.StarvedProblems)
+	}
+	// b is past 5 days on days 6..19
+	if report.StarvedProblemDays != 14 {
+		t.Errorf("expected 14 starved problem-days, got %d", report.StarvedProblemDays)
+	}
+}
+
+func TestRunSimulateJob_BadFormatKeepsOutput(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(out, []byte("previous report"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := RunSimulateJob(SimulationOptions{Synthetic: 5, Days: 10, Completion: 1, Strategies: "weighted", Format: "cvs", Out: out})
+	if err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+	if b, _ := os.ReadFile(out); string(b) != "previous report" {
+		t.Errorf("the existing report was overwritten: %q", b)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// maxSolutionCodeBytes caps the size of a stored solution.
+const maxSolutionCodeBytes = 64 << 10
+
+// Solution is one version of a problem's solution code. Versions are numbered
+// from 1 per problem; RevisitID links a version to the attempt it was written in.
+type Solution struct {
+	ID              uuid.UUID     `json:"id"`
+	ProblemID       uuid.UUID     `json:"problem_id"`
+	RevisitID       uuid.NullUUID `json:"revisit_id"`
+	Version         int           `json:"version"`
+	Language        string        `json:"language"`
+	Code            string        `json:"code"`
+	TimeComplexity  string        `json:"time_complexity,omitempty"`
+	SpaceComplexity string        `json:"space_complexity,omitempty"`
+	Approach        string        `json:"approach,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+}
+
+// SolutionInput is the request body for saving a solution.
+type SolutionInput struct {
+	RevisitID       uuid.NullUUID `json:"revisit_id"`
+	Language        string        `json:"language"`
+	Code            string        `json:"code"`
+	TimeComplexity  string        `json:"time_complexity"`
+	SpaceComplexity string        `json:"space_complexity"`
+	Approach        string        `json:"approach"`
+}
+
+// validate trims the input and checks required fields. field is where the
+// input sits in the request body, e.g. "body.solution".
+func (in *SolutionInput) validate(field string) error {
+	in.Language = strings.ToLower(strings.TrimSpace(in.Language))
+	in.TimeComplexity = strings.TrimSpace(in.TimeComplexity)
+	in.SpaceComplexity = strings.TrimSpace(in.SpaceComplexity)
+	in.Approach = strings.TrimSpace(in.Approach)
+	switch {
+	case in.Language == "":
+		return invalidField(field+".language", "is required")
+	case len(in.Language) > 50:
+		return invalidField(field+".language", "must be at most 50 characters")
+	case strings.TrimSpace(in.Code) == "":
+		return invalidField(field+".code", "is required")
+	case len(in.Code) > maxSolutionCodeBytes:
+		return invalidField(field+".code", fmt.Sprintf("must be at most %d bytes", maxSolutionCodeBytes))
+	case len(in.TimeComplexity) > 100:
+		return invalidField(field+".time_complexity", "must be at most 100 characters")
+	case len(in.SpaceComplexity) > 100:
+		return invalidField(field+".space_complexity", "must be at most 100 characters")
+	case len(in.Approach) > 255:
+		return invalidField(field+".approach", "must be at most 255 characters")
+	}
+	return nil
+}
+
+const solutionColumns = `id, problem_id, revisit_id, version, language, code,
+	COALESCE(time_complexity, ''), COALESCE(space_complexity, ''), COALESCE(approach, ''), created_at`
+
+func scanSolution(row rowScanner, s *Solution) error {
+	return row.Scan(&s.ID, &s.ProblemID, &s.RevisitID, &s.Version, &s.Language, &s.Code,
+		&s.TimeComplexity, &s.SpaceComplexity, &s.Approach, &s.CreatedAt)
+}
+
+// insertSolution stores in as the problem's next version. The problem row is
+// locked so concurrent saves can't pick the same version number.
+func insertSolution(tx *sql.Tx, problemID uuid.UUID, in SolutionInput) (Solution, error) {
+	var s Solution
+	if _, err := tx.Exec(`SELECT 1 FROM problems WHERE id = $1 FOR UPDATE`, problemID); err != nil {
+		return s, err
+	}
+	err := scanSolution(tx.QueryRow(`
+		INSERT INTO solutions (problem_id, revisit_id, version, language, code, time_complexity, space_complexity, approach)
+		VALUES ($1, $2, (SELECT COALESCE(MAX(version), 0) + 1 FROM solutions WHERE problem_id = $1),
+		        $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''))
+		RETURNING `+solutionColumns,
+		problemID, in.RevisitID, in.Language, in.Code, in.TimeComplexity, in.SpaceComplexity, in.Approach), &s)
+	return s, err
+}
+
+// ownedProblemID parses the {id} URL param and checks the problem belongs to
+// the user, writing the error response if not.
+func ownedProblemID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID := GetUserIDFromContext(r)
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return uuid.Nil, false
+	}
+	var exists bool
+	err = db.QueryRow(`SELECT EXISTS(SELECT 1 FROM problems WHERE id = $1 AND user_id = $2 AND status <> 'trashed')`,
+		id, userID).Scan(&exists)
+	if err != nil {
+		respondError(w, r, err)
+		return uuid.Nil, false
+	}
+	if !exists {
+		respondError(w, r, notFound("Problem"))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// GetSolutions lists a problem's solution versions, newest first.
+func GetSolutions(w http.ResponseWriter, r *http.Request) {
+	problemID, ok := ownedProblemID(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := db.Query(`SELECT `+solutionColumns+` FROM solutions WHERE problem_id = $1 ORDER BY version DESC`, problemID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	solutions := []Solution{}
+	for rows.Next() {
+		var s Solution
+		if err := scanSolution(rows, &s); err != nil {
+			respondError(w, r, err)
+			return
+		}
+		solutions = append(solutions, s)
+	}
+	respondJSONConditional(w, r, solutions)
+}
+
+// GetSolution returns one version; "latest" is accepted in place of a number.
+func GetSolution(w http.ResponseWriter, r *http.Request) {
+	problemID, ok := ownedProblemID(w, r)
+	if !ok {
+		return
+	}
+
+	s, err := loadSolutionVersion(problemID, chi.URLParam(r, "version"))
+	if err == errInvalidVersion {
+		respondError(w, r, err)
+		return
+	}
+	if err == sql.ErrNoRows {
+		respondError(w, r, notFound("Solution version"))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, s)
+}
+
+var errInvalidVersion = invalidField("path.version", "must be a positive number or \"latest\"")
+
+// loadSolutionVersion loads a version by number or "latest".
+func loadSolutionVersion(problemID uuid.UUID, version string) (Solution, error) {
+	var s Solution
+	if version == "latest" {
+		err := scanSolution(db.QueryRow(`
+			SELECT `+solutionColumns+` FROM solutions WHERE problem_id = $1
+			ORDER BY version DESC LIMIT 1`, problemID), &s)
+		return s, err
+	}
+	n, err := strconv.Atoi(version)
+	if err != nil || n < 1 {
+		return s, errInvalidVersion
+	}
+	err = scanSolution(db.QueryRow(`
+		SELECT `+solutionColumns+` FROM solutions WHERE problem_id = $1 AND version = $2`, problemID, n), &s)
+	return s, err
+}
+
+// CreateSolution saves a new solution version for a problem. revisit_id, when
+// given, must be one of the problem's revisits.
+func CreateSolution(w http.ResponseWriter, r *http.Request) {
+	problemID, ok := ownedProblemID(w, r)
+	if !ok {
+		return
+	}
+
+	var in SolutionInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, r, invalidBody(err))
+		return
+	}
+	if err := in.validate("body"); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if in.RevisitID.Valid {
+		var exists bool
+		err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM revisit_history WHERE id = $1 AND problem_id = $2)`,
+			in.RevisitID.UUID, problemID).Scan(&exists)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		if !exists {
+			respondError(w, r, invalidField("body.revisit_id", "does not belong to this problem"))
+			return
+		}
+	}
+
+	s, err := insertSolution(tx, problemID, in)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, s)
+}
+
+// GetSolutionDiff returns a unified diff between two versions of a problem's
+// solution. Query: from, to (version numbers or "latest"). from defaults to
+// the version before to (an empty file for version 1, reported as from 0),
+// and to defaults to latest.
+func GetSolutionDiff(w http.ResponseWriter, r *http.Request) {
+	problemID, ok := ownedProblemID(w, r)
+	if !ok {
+		return
+	}
+
+	toParam := r.URL.Query().Get("to")
+	if toParam == "" {
+		toParam = "latest"
+	}
+	to, err := loadSolutionVersion(problemID, toParam)
+	if err == errInvalidVersion {
+		respondError(w, r, invalidField("query.to", "must be a positive number or \"latest\""))
+		return
+	}
+	if err == sql.ErrNoRows {
+		respondError(w, r, notFound("Solution version"))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	// The first version has nothing before it, so by default it is diffed
+	// against an empty file and shows as entirely added
+	var from Solution
+	fromName := "/dev/null"
+	fromParam := r.URL.Query().Get("from")
+	if fromParam != "" || to.Version > 1 {
+		if fromParam == "" {
+			fromParam = strconv.Itoa(to.Version - 1)
+		}
+		from, err = loadSolutionVersion(problemID, fromParam)
+		if err == errInvalidVersion {
+			respondError(w, r, invalidField("query.from", "must be a positive number or \"latest\""))
+			return
+		}
+		if err == sql.ErrNoRows {
+			respondError(w, r, notFound("Solution version"))
+			return
+		}
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		fromName = fmt.Sprintf("v%d (%s)", from.Version, from.Language)
+	}
+
+	diff, err := UnifiedDiff(from.Code, to.Code, fromName,
+		fmt.Sprintf("v%d (%s)", to.Version, to.Language))
+	if err == errDiffTooLarge {
+		respondError(w, r, unprocessable("diff_too_large", "The versions are too different to diff"))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	// Plain patch text for tools, JSON otherwise
+	if strings.Contains(r.Header.Get("Accept"), "text/x-diff") || r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(diff))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"from":      from.Version,
+		"to":        to.Version,
+		"identical": diff == "",
+		"diff":      diff,
+	})
+}
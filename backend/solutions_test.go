@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetSolutionDiffFirstVersion(t *testing.T) {
+	userID := testUser(t)
+	id := testProblem(t, userID, "Two Sum", "https://example.com/two-sum")
+	router := newRouter(testAuth(userID))
+	base := "/api/problems/" + id.String() + "/solutions"
+
+	req := httptest.NewRequest("POST", base, strings.NewReader(`{"language": "go", "code": "x\ny\n"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+
+	// With a single version and no from, the whole file is added
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", base+"/diff", nil))
+	var body struct {
+		From, To int
+		Diff     string
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusOK || body.From != 0 || body.To != 1 {
+		t.Fatalf("diff: %d %s", rec.Code, rec.Body)
+	}
+	if want := "--- /dev/null\n+++ v1 (go)\n@@ -0,0 +1,2 @@\n+x\n+y\n"; body.Diff != want {
+		t.Errorf("diff = %q, want %q", body.Diff, want)
+	}
+
+	// An explicit from must still name a real version
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", base+"/diff?from=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("from=0: %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,346 @@
+// Package sqlitefile writes small, read-only SQLite database files without
+// cgo or a SQLite library. It supports what export formats like Anki's
+// .apkg need: rowid tables with NULL, integer, float, text and blob values,
+// spread over as many pages as required. Indexes, WITHOUT ROWID tables and
+// anything else that needs the SQL engine are out of scope.
+//
+// The layout follows https://www.sqlite.org/fileformat2.html.
+package sqlitefile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	pageSize  = 4096
+	usable    = pageSize // no reserved bytes per page
+	headerLen = 100      // database header at the start of page 1
+
+	leafTablePage     = 0x0d
+	interiorTablePage = 0x05
+)
+
+// Table is a rowid table and its rows. SQL is the CREATE TABLE statement
+// stored in the schema; it must match the values written.
+type Table struct {
+	Name string
+	SQL  string
+	Rows []Row
+}
+
+// Row is one table row. Values are nil, int, int64, float64, string or
+// []byte. A column declared INTEGER PRIMARY KEY must be nil here: SQLite
+// stores it as the rowid.
+type Row struct {
+	RowID  int64
+	Values []interface{}
+}
+
+// builder accumulates pages; pages[0] is page 1.
+type builder struct {
+	pages [][]byte
+}
+
+func (b *builder) alloc() uint32 {
+	b.pages = append(b.pages, make([]byte, pageSize))
+	return uint32(len(b.pages))
+}
+
+func (b *builder) page(n uint32) []byte { return b.pages[n-1] }
+
+// Build returns the bytes of a database file holding the given tables.
+func Build(tables []Table) ([]byte, error) {
+	b := &builder{}
+	b.alloc() // page 1: database header + sqlite_schema root
+
+	schema := make([]Row, len(tables))
+	for i, t := range tables {
+		rows := append([]Row(nil), t.Rows...)
+		sort.Slice(rows, func(x, y int) bool { return rows[x].RowID < rows[y].RowID })
+		for j := 1; j < len(rows); j++ {
+			if rows[j].RowID == rows[j-1].RowID {
+				return nil, fmt.Errorf("sqlitefile: table %s: duplicate rowid %d", t.Name, rows[j].RowID)
+			}
+		}
+		root, err := b.buildTree(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqlitefile: table %s: %w", t.Name, err)
+		}
+		schema[i] = Row{RowID: int64(i + 1), Values: []interface{}{"table", t.Name, t.Name, int64(root), t.SQL}}
+	}
+
+	// The schema table's root must be page 1, after the 100-byte header
+	cells := make([][]byte, len(schema))
+	for i, r := range schema {
+		cell, err := b.leafCell(r)
+		if err != nil {
+			return nil, fmt.Errorf("sqlitefile: schema: %w", err)
+		}
+		cells[i] = cell
+	}
+	if n := fitCells(cells, headerLen+8); n < len(cells) {
+		return nil, fmt.Errorf("sqlitefile: schema does not fit on the first page")
+	}
+	writePage(b.page(1), headerLen, leafTablePage, cells, 0)
+	writeHeader(b.page(1), uint32(len(b.pages)))
+
+	out := make([]byte, 0, len(b.pages)*pageSize)
+	for _, p := range b.pages {
+		out = append(out, p...)
+	}
+	return out, nil
+}
+
+// buildTree writes a table b-tree for rows (sorted by rowid) and returns its
+// root page. Leaves are packed left to right, then interior levels are built
+// over them until a single root remains.
+func (b *builder) buildTree(rows []Row) (uint32, error) {
+	type child struct {
+		page   uint32
+		maxKey int64
+	}
+
+	cells := make([][]byte, len(rows))
+	for i, r := range rows {
+		cell, err := b.leafCell(r)
+		if err != nil {
+			return 0, err
+		}
+		cells[i] = cell
+	}
+
+	var level []child
+	for start := 0; start < len(cells) || len(level) == 0; {
+		n := fitCells(cells[start:], 8)
+		page := b.alloc()
+		writePage(b.page(page), 0, leafTablePage, cells[start:start+n], 0)
+		maxKey := int64(0)
+		if n > 0 {
+			maxKey = rows[start+n-1].RowID
+		}
+		level = append(level, child{page, maxKey})
+		start += n
+	}
+
+	for len(level) > 1 {
+		var next []child
+		for start := 0; start < len(level); {
+			// Every child but the last gets a cell; the last is the right pointer
+			var cells [][]byte
+			end := start
+			used := 12
+			for end < len(level)-1 {
+				cell := binary.BigEndian.AppendUint32(nil, level[end].page)
+				cell = appendVarint(cell, uint64(level[end].maxKey))
+				if used+len(cell)+2 > usable && len(cells) > 0 {
+					// Don't leave a lone child for a cell-less page
+					if end == len(level)-2 {
+						end--
+						cells = cells[:len(cells)-1]
+					}
+					break
+				}
+				used += len(cell) + 2
+				cells = append(cells, cell)
+				end++
+			}
+			right := level[end]
+			page := b.alloc()
+			writePage(b.page(page), 0, interiorTablePage, cells, right.page)
+			next = append(next, child{page, right.maxKey})
+			start = end + 1
+		}
+		level = next
+	}
+	return level[0].page, nil
+}
+
+// fitCells returns how many of cells fit on a page whose b-tree header
+// starts after `used` bytes (header offset plus header length).
+func fitCells(cells [][]byte, used int) int {
+	for i, c := range cells {
+		used += len(c) + 2 // cell plus its pointer
+		if used > usable {
+			return i
+		}
+	}
+	return len(cells)
+}
+
+// writePage lays out a b-tree page: header at offset, cell pointers after
+// it, cell content packed against the end of the page.
+func writePage(p []byte, offset int, kind byte, cells [][]byte, rightChild uint32) {
+	p[offset] = kind
+	binary.BigEndian.PutUint16(p[offset+3:], uint16(len(cells)))
+	ptr := offset + 8
+	if kind == interiorTablePage {
+		binary.BigEndian.PutUint32(p[offset+8:], rightChild)
+		ptr = offset + 12
+	}
+
+	content := pageSize
+	for i, c := range cells {
+		content -= len(c)
+		copy(p[content:], c)
+		binary.BigEndian.PutUint16(p[ptr+2*i:], uint16(content))
+	}
+	binary.BigEndian.PutUint16(p[offset+5:], uint16(content%65536))
+}
+
+// leafCell encodes a table leaf cell, moving the tail of a large record
+// onto overflow pages.
+func (b *builder) leafCell(r Row) ([]byte, error) {
+	payload, err := encodeRecord(r.Values)
+	if err != nil {
+		return nil, err
+	}
+	cell := appendVarint(nil, uint64(len(payload)))
+	cell = appendVarint(cell, uint64(r.RowID))
+
+	local := localPayload(len(payload))
+	cell = append(cell, payload[:local]...)
+	if local == len(payload) {
+		return cell, nil
+	}
+
+	// Overflow chain: each page is a 4-byte next-page number and content
+	rest := payload[local:]
+	first := b.alloc()
+	cell = binary.BigEndian.AppendUint32(cell, first)
+	for page := first; ; {
+		n := copy(b.page(page)[4:], rest)
+		rest = rest[n:]
+		if len(rest) == 0 {
+			break
+		}
+		next := b.alloc()
+		binary.BigEndian.PutUint32(b.page(page), next)
+		page = next
+	}
+	return cell, nil
+}
+
+// localPayload is how many payload bytes a table leaf cell keeps on its page.
+func localPayload(p int) int {
+	x := usable - 35
+	if p <= x {
+		return p
+	}
+	m := (usable-12)*32/255 - 23
+	k := m + (p-m)%(usable-4)
+	if k <= x {
+		return k
+	}
+	return m
+}
+
+// encodeRecord serializes values in the SQLite record format.
+func encodeRecord(values []interface{}) ([]byte, error) {
+	var types, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			types = appendVarint(types, 0)
+		case int:
+			types, body = appendInt(types, body, int64(v))
+		case int64:
+			types, body = appendInt(types, body, v)
+		case float64:
+			types = appendVarint(types, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			types = appendVarint(types, uint64(len(v))*2+13)
+			body = append(body, v...)
+		case []byte:
+			types = appendVarint(types, uint64(len(v))*2+12)
+			body = append(body, v...)
+		default:
+			return nil, fmt.Errorf("unsupported value type %T", v)
+		}
+	}
+
+	// The header length includes its own varint
+	headerSize := len(types) + 1
+	for varintLen(uint64(headerSize)) != headerSize-len(types) {
+		headerSize = len(types) + varintLen(uint64(headerSize))
+	}
+	record := appendVarint(make([]byte, 0, headerSize+len(body)), uint64(headerSize))
+	record = append(record, types...)
+	return append(record, body...), nil
+}
+
+// appendInt adds an integer using the smallest serial type that holds it.
+func appendInt(types, body []byte, v int64) ([]byte, []byte) {
+	switch {
+	case v == 0:
+		return appendVarint(types, 8), body
+	case v == 1:
+		return appendVarint(types, 9), body
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return appendVarint(types, 1), append(body, byte(v))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return appendVarint(types, 2), binary.BigEndian.AppendUint16(body, uint16(v))
+	case v >= -1<<23 && v < 1<<23:
+		return appendVarint(types, 3), append(body, byte(v>>16), byte(v>>8), byte(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return appendVarint(types, 4), binary.BigEndian.AppendUint32(body, uint32(v))
+	case v >= -1<<47 && v < 1<<47:
+		return appendVarint(types, 5), append(body, byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		return appendVarint(types, 6), binary.BigEndian.AppendUint64(body, uint64(v))
+	}
+}
+
+// appendVarint appends v as a SQLite varint: big-endian groups of 7 bits
+// with a continuation bit, where a ninth byte carries a full 8 bits.
+func appendVarint(buf []byte, v uint64) []byte {
+	if v > 1<<56-1 {
+		var tmp [9]byte
+		tmp[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			tmp[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(buf, tmp[:]...)
+	}
+	var tmp [8]byte
+	n := 0
+	for {
+		tmp[n] = byte(v & 0x7f)
+		n++
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		if i > 0 {
+			buf = append(buf, tmp[i]|0x80)
+		} else {
+			buf = append(buf, tmp[i])
+		}
+	}
+	return buf
+}
+
+func varintLen(v uint64) int { return len(appendVarint(nil, v)) }
+
+// writeHeader fills in the 100-byte database header on page 1.
+func writeHeader(p []byte, pageCount uint32) {
+	copy(p, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(p[16:], pageSize)
+	p[18], p[19] = 1, 1 // legacy (rollback journal) read/write versions
+	p[20] = 0           // reserved bytes per page
+	p[21], p[22], p[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(p[24:], 1) // file change counter
+	binary.BigEndian.PutUint32(p[28:], pageCount)
+	binary.BigEndian.PutUint32(p[40:], 1) // schema cookie
+	binary.BigEndian.PutUint32(p[44:], 4) // schema format
+	binary.BigEndian.PutUint32(p[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(p[92:], 1) // version-valid-for, matches the change counter
+	binary.BigEndian.PutUint32(p[96:], 3045000)
+}
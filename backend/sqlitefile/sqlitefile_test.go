@@ -0,0 +1,90 @@
+package sqlitefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestAppendVarint(t *testing.T) {
+	tests := []struct {
+		v    uint64
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{0x7f, []byte{0x7f}},
+		{0x80, []byte{0x81, 0x00}},
+		{300, []byte{0x82, 0x2c}},
+		{1 << 56, []byte{0x80, 0xc0, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}},
+		{^uint64(0), bytes.Repeat([]byte{0xff}, 9)},
+	}
+	for _, tt := range tests {
+		if got := appendVarint(nil, tt.v); !bytes.Equal(got, tt.want) {
+			t.Errorf("appendVarint(%d) = % x, want % x", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeRecord(t *testing.T) {
+	got, err := encodeRecord([]interface{}{nil, 0, 1, 200, "hi", []byte{7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header: size 7, NULL, zero, one, int16, text(2), blob(1); then 200, "hi", 7
+	want := []byte{7, 0, 8, 9, 2, 17, 14, 0x00, 0xc8, 'h', 'i', 7}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeRecord = % x, want % x", got, want)
+	}
+
+	if _, err := encodeRecord([]interface{}{struct{}{}}); err == nil {
+		t.Error("expected an error for an unsupported type")
+	}
+}
+
+func TestLocalPayload(t *testing.T) {
+	if got := localPayload(100); got != 100 {
+		t.Errorf("small payloads stay local, got %d", got)
+	}
+	if got := localPayload(4061); got != 4061 {
+		t.Errorf("payload at the limit stays local, got %d", got)
+	}
+	// Larger payloads keep between M and X bytes on the page
+	for _, p := range []int{4062, 5000, 50000} {
+		if got := localPayload(p); got < 489 || got > 4061 {
+			t.Errorf("localPayload(%d) = %d, out of range", p, got)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	var rows []Row
+	for i := 1; i <= 2000; i++ {
+		rows = append(rows, Row{RowID: int64(i), Values: []interface{}{nil, strings.Repeat("x", i%500)}})
+	}
+	data, err := Build([]Table{
+		{Name: "t", SQL: "CREATE TABLE t (id integer primary key, s text)", Rows: rows},
+		{Name: "big", SQL: "CREATE TABLE big (s text)", Rows: []Row{{RowID: 1, Values: []interface{}{strings.Repeat("y", 20000)}}}},
+		{Name: "empty", SQL: "CREATE TABLE empty (a integer)"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(data, []byte("SQLite format 3\x00")) {
+		t.Fatal("missing SQLite header")
+	}
+	if len(data)%pageSize != 0 {
+		t.Fatalf("file size %d is not a multiple of the page size", len(data))
+	}
+	if pages := binary.BigEndian.Uint32(data[28:]); int(pages)*pageSize != len(data) {
+		t.Errorf("header page count %d doesn't match file size %d", pages, len(data))
+	}
+	if data[headerLen] != leafTablePage || binary.BigEndian.Uint16(data[headerLen+3:]) != 3 {
+		t.Error("page 1 should be a schema leaf with one row per table")
+	}
+
+	if _, err := Build([]Table{{Name: "d", SQL: "CREATE TABLE d (a)", Rows: []Row{{RowID: 1}, {RowID: 1}}}}); err == nil {
+		t.Error("expected an error for duplicate rowids")
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// defaultTrashRetentionDays is how long trashed problems stay restorable when
+// TRASH_RETENTION_DAYS is not set.
+const defaultTrashRetentionDays = 30
+
+// TrashRetentionDays returns the configured trash retention window in days.
+func TrashRetentionDays() int {
+	if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+		slog.Warn("Invalid TRASH_RETENTION_DAYS, using the default", "value", v, "default", defaultTrashRetentionDays)
+	}
+	return defaultTrashRetentionDays
+}
+
+// TrashedProblem is a problem in the trash along with when it will be purged.
+type TrashedProblem struct {
+	Problem
+	TrashedAt time.Time `json:"trashed_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// GetTrash lists the user's trashed problems, most recently trashed first.
+func GetTrash(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	retention := TrashRetentionDays()
+
+	rows, err := db.Query(`
+		SELECT `+problemColumns+`,
+		       trashed_at
+		FROM problems
+		WHERE user_id = $1 AND status = 'trashed'
+		ORDER BY trashed_at DESC`, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	trash := []TrashedProblem{}
+	for rows.Next() {
+		var t TrashedProblem
+		p := &t.Problem
+		if err := scanProblem(rows, p, &t.TrashedAt); err != nil {
+			respondError(w, r, err)
+			return
+		}
+		t.PurgeAt = t.TrashedAt.AddDate(0, 0, retention)
+		trash = append(trash, t)
+	}
+
+	respondJSONConditional(w, r, trash)
+}
+
+// UnarchiveProblem moves a retired problem back into active rotation
+func UnarchiveProblem(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	idStr := chi.URLParam(r, "id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return
+	}
+
+	result, err := db.Exec(`
+		UPDATE problems
+		SET status = 'active'
+		WHERE id = $1 AND user_id = $2 AND status = 'retired'`, id, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		respondError(w, r, notFound("Archived problem"))
+		return
+	}
+
+	publishProblemEvent(userID, EventProblemUpdated, id)
+	respondJSON(w, http.StatusOK, map[string]string{"status": "active"})
+}
+
+// RestoreProblem takes a problem out of the trash and returns it to the status
+// it had before it was trashed. Only possible within the retention window.
+func RestoreProblem(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	idStr := chi.URLParam(r, "id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return
+	}
+
+	var status string
+	err = db.QueryRow(`
+		UPDATE problems
+		SET status = COALESCE(previous_status, 'active'), previous_status = NULL, trashed_at = NULL
+		WHERE id = $1 AND user_id = $2 AND status = 'trashed'
+		  AND trashed_at > NOW() - make_interval(days => $3)
+		RETURNING status`, id, userID, TrashRetentionDays()).Scan(&status)
+	if isUniqueViolation(err) {
+		// The same problem was added again while this copy was in the trash
+		respondDuplicate(w, r, nil)
+		return
+	}
+	if err == sql.ErrNoRows {
+		respondError(w, r, notFound("Trashed problem"))
+		return
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	publishProblemEvent(userID, EventProblemUpdated, id)
+	respondJSON(w, http.StatusOK, map[string]string{"status": status})
+}
+
+// PurgeProblem permanently removes a trashed problem and its history without
+// waiting for the retention window.
+func PurgeProblem(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	idStr := chi.URLParam(r, "id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		respondError(w, r, invalidField("path.id", "must be a UUID"))
+		return
+	}
+
+	// Start a transaction to delete history and problem
+	tx, err := db.Begin()
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	// 1. Keep past plans intact, then delete history (ownership and trash
+	// status checked via the problem)
+	err = snapshotPlanItems(tx, `SELECT id FROM problems WHERE id = $1 AND user_id = $2 AND status = 'trashed'`, id, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	_, err = tx.Exec(`
+		DELETE FROM revisit_history
+		WHERE problem_id IN (SELECT id FROM problems WHERE id = $1 AND user_id = $2 AND status = 'trashed')`,
+		id, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	// 2. Delete problem
+	result, err := tx.Exec(`DELETE FROM problems WHERE id = $1 AND user_id = $2 AND status = 'trashed'`, id, userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		respondError(w, r, notFound("Trashed problem"))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	publishEvent(userID, EventProblemDeleted, map[string]interface{}{"problem_id": id, "purged": true})
+	respondJSON(w, http.StatusOK, map[string]string{"status": "purged"})
+}
+
+// snapshotPlanItems copies the title, link and completion time of the
+// problems selected by the problemIDs subquery into their daily plan items,
+// so plan history reads the same after the problems are purged.
+func snapshotPlanItems(tx *sql.Tx, problemIDs string, args ...interface{}) error {
+	_, err := tx.Exec(`
+		UPDATE daily_plan_items dpi
+		SET title = p.title, link = p.link,
+		    completed_at = (SELECT MIN(rh.revisited_at) FROM revisit_history rh
+		                    WHERE rh.problem_id = p.id AND rh.revisited_at::date = dp.plan_date)
+		FROM problems p, daily_plans dp
+		WHERE p.id = dpi.problem_id AND dp.id = dpi.plan_id
+		  AND p.id IN (`+problemIDs+`)`, args...)
+	return err
+}
+
+// PurgeExpiredTrash permanently deletes problems that have been in the trash
+// longer than the retention window. Returns the number of problems removed.
+func PurgeExpiredTrash(retentionDays int) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = snapshotPlanItems(tx, `
+		SELECT id FROM problems
+		WHERE status = 'trashed' AND trashed_at <= NOW() - make_interval(days => $1)`, retentionDays)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM revisit_history
+		WHERE problem_id IN (
+			SELECT id FROM problems
+			WHERE status = 'trashed' AND trashed_at <= NOW() - make_interval(days => $1)
+		)`, retentionDays)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`
+		DELETE FROM problems
+		WHERE status = 'trashed' AND trashed_at <= NOW() - make_interval(days => $1)`, retentionDays)
+	if err != nil {
+		return 0, err
+	}
+	purged, _ := result.RowsAffected()
+
+	return purged, tx.Commit()
+}
+
+// RunPurgeTrashJob is the entry point for -job purge-trash and the hourly cron.
+func RunPurgeTrashJob() {
+	ctx := startJobRun(context.Background(), "purge-trash")
+	retention := TrashRetentionDays()
+	purged, err := PurgeExpiredTrash(retention)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error purging trash", "error", err)
+		return
+	}
+	if purged > 0 {
+		slog.InfoContext(ctx, "Purged expired trash", "problems", purged, "retention_days", retention)
+	}
+}
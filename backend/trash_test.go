@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// trashTestProblem inserts a problem for userID that was trashed daysAgo
+// days ago, from the active status.
+func trashTestProblem(t *testing.T, userID uuid.UUID, title string, daysAgo int) uuid.UUID {
+	t.Helper()
+	id := testProblem(t, userID, title, "https://example.com/"+uuid.NewString())
+	_, err := db.Exec(`
+		UPDATE problems SET previous_status = 'active', status = 'trashed',
+		       trashed_at = NOW() - make_interval(days => $2)
+		WHERE id = $1`, id, daysAgo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func serveAs(userID uuid.UUID, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	newRouter(testAuth(userID)).ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func problemStatus(t *testing.T, id uuid.UUID) string {
+	t.Helper()
+	var status string
+	if err := db.QueryRow(`SELECT status FROM problems WHERE id = $1`, id).Scan(&status); err != nil {
+		return ""
+	}
+	return status
+}
+
+func TestTrashedProblemsAreHidden(t *testing.T) {
+	userID := testUser(t)
+	id := trashTestProblem(t, userID, "Two Sum", 1)
+
+	for _, target := range []string{"/api/problems/" + id.String(), "/api/problems/" + id.String() + "/weight"} {
+		if rec := serveAs(userID, "GET", target); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: %d, want 404", target, rec.Code)
+		}
+	}
+}
+
+func TestRestoreProblem(t *testing.T) {
+	t.Setenv("TRASH_RETENTION_DAYS", "30")
+	userID := testUser(t)
+
+	recent := trashTestProblem(t, userID, "Two Sum", 1)
+	rec := serveAs(userID, "POST", "/api/problems/"+recent.String()+"/restore")
+	if rec.Code != http.StatusOK || problemStatus(t, recent) != "active" {
+		t.Errorf("restore within the window: %d %s, status %q", rec.Code, rec.Body, problemStatus(t, recent))
+	}
+
+	expired := trashTestProblem(t, userID, "Three Sum", 31)
+	if rec := serveAs(userID, "POST", "/api/problems/"+expired.String()+"/restore"); rec.Code != http.StatusNotFound {
+		t.Errorf("restore after the window: %d, want 404", rec.Code)
+	}
+	if problemStatus(t, expired) != "trashed" {
+		t.Errorf("an expired problem was restored")
+	}
+
+	// The same link was added again while the first copy sat in the trash
+	trashed := trashTestProblem(t, userID, "Valid Anagram", 1)
+	readded := testProblem(t, userID, "Valid Anagram", "https://leetcode.com/problems/valid-anagram/")
+	db.Exec(`UPDATE problems SET platform = 'leetcode', canonical_key = 'valid-anagram' WHERE id IN ($1, $2)`, trashed, readded)
+	rec = serveAs(userID, "POST", "/api/problems/"+trashed.String()+"/restore")
+	var body errorEnvelope
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusConflict || body.Error.Code != "duplicate_problem" {
+		t.Errorf("restoring a duplicate: %d %s", rec.Code, rec.Body)
+	}
+	if problemStatus(t, trashed) != "trashed" {
+		t.Errorf("the duplicate left the trash")
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	userID := testUser(t)
+
+	id := trashTestProblem(t, userID, "Two Sum", 1)
+	db.Exec(`INSERT INTO revisit_history (problem_id, revisited_at) VALUES ($1, NOW() - INTERVAL '3 days')`, id)
+	if rec := serveAs(userID, "DELETE", "/api/trash/"+id.String()); rec.Code != http.StatusOK {
+		t.Fatalf("purge: %d %s", rec.Code, rec.Body)
+	}
+	var history int
+	db.QueryRow(`SELECT COUNT(*) FROM revisit_history WHERE problem_id = $1`, id).Scan(&history)
+	if problemStatus(t, id) != "" || history != 0 {
+		t.Errorf("purge left the problem or %d revisits behind", history)
+	}
+
+	active := testProblem(t, userID, "Three Sum", "https://example.com/3sum")
+	if rec := serveAs(userID, "DELETE", "/api/trash/"+active.String()); rec.Code != http.StatusNotFound {
+		t.Errorf("purging an active problem: %d, want 404", rec.Code)
+	}
+
+	expired := trashTestProblem(t, userID, "Old", 31)
+	kept := trashTestProblem(t, userID, "New", 5)
+	if _, err := PurgeExpiredTrash(30); err != nil {
+		t.Fatal(err)
+	}
+	if problemStatus(t, expired) != "" || problemStatus(t, kept) != "trashed" || problemStatus(t, active) != "active" {
+		t.Errorf("PurgeExpiredTrash: expired %q, kept %q, active %q",
+			problemStatus(t, expired), problemStatus(t, kept), problemStatus(t, active))
+	}
+}
+
+func TestPurgeKeepsPlanHistory(t *testing.T) {
+	userID := testUser(t)
+	id := trashTestProblem(t, userID, "Two Sum", 1)
+
+	// Planned and completed three days ago
+	_, err := db.Exec(`
+		WITH plan AS (
+			INSERT INTO daily_plans (user_id, plan_date, eligible_count)
+			VALUES ($1, CURRENT_DATE - 3, 5) RETURNING id
+		)
+		INSERT INTO daily_plan_items (plan_id, problem_id, position) SELECT id, $2, 0 FROM plan`, userID, id)
+	if err == nil {
+		_, err = db.Exec(`INSERT INTO revisit_history (problem_id, revisited_at) VALUES ($1, NOW() - INTERVAL '3 days')`, id)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := serveAs(userID, "DELETE", "/api/trash/"+id.String()); rec.Code != http.StatusOK {
+		t.Fatalf("purge: %d %s", rec.Code, rec.Body)
+	}
+
+	history, err := LoadPlanHistory(userID, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].TotalFocus != 1 || history[0].Completed != 1 || history[0].EligibleCount != 5 {
+		t.Fatalf("plan history after the purge: %+v", history)
+	}
+	if item := history[0].Problems[0]; item.Title != "Two Sum" || item.ProblemID.Valid {
+		t.Errorf("purged plan item = %+v, want the title kept and no problem ID", item)
+	}
+}
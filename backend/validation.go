@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Request size limits. maxRequestBodyBytes caps every request body; it leaves
+// room for a solution of maxSolutionCodeBytes plus its JSON escaping.
+const (
+	maxRequestBodyBytes = 256 << 10
+	maxNotesLength      = 20000
+	maxLinkLength       = 2048
+)
+
+// Difficulties a problem can be tagged with; "" means unknown.
+var difficulties = []string{"Easy", "Medium", "Hard"}
+
+// stringRule declares the constraints on one string field. Values are
+// trimmed before they are checked.
+type stringRule struct {
+	Field     string   // JSON name, used in error details as "body.<Field>"
+	Required  bool     // must be present and non-blank
+	MaxLength int      // in characters; 0 means unbounded
+	OneOf     []string // allowed non-empty values; nil allows any
+	URL       bool     // non-empty values must be absolute http(s) URLs
+}
+
+// check returns the trimmed value, or what is wrong with it.
+func (rule stringRule) check(value string) (string, string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		if rule.Required {
+			return value, "must not be blank"
+		}
+		return value, ""
+	}
+	if rule.MaxLength > 0 && utf8.RuneCountInString(value) > rule.MaxLength {
+		return value, fmt.Sprintf("must be at most %d characters", rule.MaxLength)
+	}
+	if rule.OneOf != nil {
+		allowed := false
+		for _, v := range rule.OneOf {
+			allowed = allowed || v == value
+		}
+		if !allowed {
+			return value, "must be one of " + strings.Join(rule.OneOf, ", ")
+		}
+	}
+	if rule.URL {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return value, "must be an http or https URL"
+		}
+	}
+	return value, ""
+}
+
+// problemRules declares the constraints on the editable problem fields,
+// matching the column sizes in schema.sql.
+var problemRules = struct {
+	Title, Link, Difficulty, Source, Topic, Notes stringRule
+}{
+	Title:      stringRule{Field: "title", Required: true, MaxLength: 255},
+	Link:       stringRule{Field: "link", MaxLength: maxLinkLength, URL: true},
+	Difficulty: stringRule{Field: "difficulty", OneOf: difficulties},
+	Source:     stringRule{Field: "source", MaxLength: 255},
+	Topic:      stringRule{Field: "topic", MaxLength: 255},
+	Notes:      stringRule{Field: "notes", MaxLength: maxNotesLength},
+}
+
+// ProblemInput is the body of the problem create, update and patch
+// endpoints. Nil fields were omitted by the client.
+type ProblemInput struct {
+	Title      *string `json:"title"`
+	Link       *string `json:"link"`
+	Difficulty *string `json:"difficulty"`
+	Source     *string `json:"source"`
+	Topic      *string `json:"topic"`
+	Notes      *string `json:"notes"`
+}
+
+// boundField pairs a rule with the input field it checks.
+type boundField struct {
+	rule  stringRule
+	value **string
+}
+
+func (in *ProblemInput) fields() []boundField {
+	return []boundField{
+		{problemRules.Title, &in.Title},
+		{problemRules.Link, &in.Link},
+		{problemRules.Difficulty, &in.Difficulty},
+		{problemRules.Source, &in.Source},
+		{problemRules.Topic, &in.Topic},
+		{problemRules.Notes, &in.Notes},
+	}
+}
+
+// validate trims every given field and checks it against problemRules. A
+// partial input (PATCH) may omit required fields but not blank them.
+func (in *ProblemInput) validate(partial bool) error {
+	var errs []FieldError
+	for _, f := range in.fields() {
+		if *f.value == nil {
+			if f.rule.Required && !partial {
+				errs = append(errs, FieldError{Field: "body." + f.rule.Field, Message: "is required"})
+			}
+			continue
+		}
+		value, problem := f.rule.check(**f.value)
+		*f.value = &value
+		if problem != "" {
+			errs = append(errs, FieldError{Field: "body." + f.rule.Field, Message: problem})
+		}
+	}
+	if len(errs) == 1 {
+		return invalid(errs[0].Field+" "+errs[0].Message, errs...)
+	}
+	if len(errs) > 1 {
+		return invalid(fmt.Sprintf("%d fields are invalid", len(errs)), errs...)
+	}
+	return nil
+}
+
+// apply copies the given fields onto p.
+func (in *ProblemInput) apply(p *Problem) {
+	set := func(dst *string, src *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	set(&p.Title, in.Title)
+	set(&p.Link, in.Link)
+	set(&p.Difficulty, in.Difficulty)
+	set(&p.Source, in.Source)
+	set(&p.Topic, in.Topic)
+	set(&p.Notes, in.Notes)
+}
+
+// decodeProblemInput reads and validates a problem body.
+func decodeProblemInput(r *http.Request, partial bool) (ProblemInput, error) {
+	var in ProblemInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		return in, invalidBody(err)
+	}
+	return in, in.validate(partial)
+}
+
+// LimitRequestBody rejects bodies larger than limit with 413.
+func LimitRequestBody(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				respondError(w, r, payloadTooLarge(limit))
+				return
+			}
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProblemInputValidate(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	in := ProblemInput{Title: str("  Two Sum  "), Link: str("https://leetcode.com/problems/two-sum/"), Difficulty: str("Easy")}
+	if err := in.validate(false); err != nil {
+		t.Fatalf("valid input rejected: %v", err)
+	}
+	if *in.Title != "Two Sum" {
+		t.Errorf("title not trimmed: %q", *in.Title)
+	}
+
+	cases := []struct {
+		name    string
+		in      ProblemInput
+		partial bool
+		fields  []string
+	}{
+		{"missing title", ProblemInput{Link: str("https://example.com")}, false, []string{"body.title"}},
+		{"blank title", ProblemInput{Title: str("   ")}, false, []string{"body.title"}},
+		{"blank title in patch", ProblemInput{Title: str("")}, true, []string{"body.title"}},
+		{"long title", ProblemInput{Title: str(strings.Repeat("é", 256))}, false, []string{"body.title"}},
+		{"bad scheme", ProblemInput{Title: str("x"), Link: str("javascript:alert(1)")}, false, []string{"body.link"}},
+		{"relative link", ProblemInput{Title: str("x"), Link: str("leetcode.com/problems/two-sum")}, false, []string{"body.link"}},
+		{"difficulty", ProblemInput{Title: str("x"), Difficulty: str("Insane")}, false, []string{"body.difficulty"}},
+		{"huge notes", ProblemInput{Title: str("x"), Notes: str(strings.Repeat("a", maxNotesLength+1))}, false, []string{"body.notes"}},
+		{"several", ProblemInput{Difficulty: str("easy"), Source: str(strings.Repeat("s", 256))}, false,
+			[]string{"body.title", "body.difficulty", "body.source"}},
+	}
+	for _, c := range cases {
+		err := c.in.validate(c.partial)
+		apiErr, ok := err.(*APIError)
+		if !ok || apiErr.Status != http.StatusBadRequest {
+			t.Errorf("%s: got %v, want a 400", c.name, err)
+			continue
+		}
+		fields, _ := apiErr.Details.([]FieldError)
+		var got []string
+		for _, f := range fields {
+			got = append(got, f.Field)
+		}
+		if strings.Join(got, ",") != strings.Join(c.fields, ",") {
+			t.Errorf("%s: fields %v, want %v", c.name, got, c.fields)
+		}
+	}
+
+	// Empty optional fields are allowed and clear the column
+	if err := (&ProblemInput{Title: str("x"), Link: str(""), Difficulty: str("")}).validate(false); err != nil {
+		t.Errorf("empty optional fields rejected: %v", err)
+	}
+	// A patch may omit the title
+	if err := (&ProblemInput{Notes: str("more notes")}).validate(true); err != nil {
+		t.Errorf("partial input rejected: %v", err)
+	}
+}
+
+func TestProblemInputApply(t *testing.T) {
+	str := func(s string) *string { return &s }
+	p := Problem{Title: "Two Sum", Link: "https://leetcode.com/problems/two-sum/", Difficulty: "Easy", Notes: "hash map"}
+	(&ProblemInput{Title: str("2Sum"), Notes: str("")}).apply(&p)
+	if p.Title != "2Sum" || p.Notes != "" || p.Difficulty != "Easy" || p.Link == "" {
+		t.Errorf("unexpected problem after patch: %+v", p)
+	}
+}
+
+func TestLimitRequestBody(t *testing.T) {
+	handler := LimitRequestBody(16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		in, err := decodeProblemInput(r, true)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, in)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{"title":"ok"}`)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("small body: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{"title":"much too long"}`)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large body: status %d, want 413", rec.Code)
+	}
+
+	// Chunked bodies have no Content-Length and are cut off while reading
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"title":"much too long"}`))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("chunked body: status %d, want 413", rec.Code)
+	}
+}
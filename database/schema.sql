@@ -11,6 +11,34 @@ CREATE TABLE IF NOT EXISTS users (
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Catalog Problems Table (shared, seeded from backend/catalog/*.json)
+CREATE TABLE IF NOT EXISTS catalog_problems (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    platform VARCHAR(50) NOT NULL,
+    canonical_key VARCHAR(255) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    link TEXT NOT NULL,
+    difficulty VARCHAR(50),
+    topics JSONB NOT NULL DEFAULT '[]',
+    UNIQUE (platform, canonical_key)
+);
+
+-- Catalog Lists Table (curated study lists such as Blind 75)
+CREATE TABLE IF NOT EXISTS catalog_lists (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    slug VARCHAR(100) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT
+);
+
+-- Catalog List Items Table
+CREATE TABLE IF NOT EXISTS catalog_list_items (
+    list_id UUID NOT NULL REFERENCES catalog_lists(id) ON DELETE CASCADE,
+    catalog_problem_id UUID NOT NULL REFERENCES catalog_problems(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    PRIMARY KEY (list_id, catalog_problem_id)
+);
+
 -- Problems Table
 CREATE TABLE IF NOT EXISTS problems (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -20,10 +48,23 @@ CREATE TABLE IF NOT EXISTS problems (
     date_added TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     last_revisited_at TIMESTAMP WITH TIME ZONE,
     times_revisited INT DEFAULT 0,
-    status VARCHAR(50) DEFAULT 'active', -- active, retired
+    status VARCHAR(50) DEFAULT 'active', -- active, retired, trashed
     topic VARCHAR(255),
     difficulty VARCHAR(50), -- Easy, Medium, Hard
-    source VARCHAR(255) DEFAULT 'LeetCode'
+    source VARCHAR(255) DEFAULT 'LeetCode',
+    notes TEXT,
+    trashed_at TIMESTAMP WITH TIME ZONE,
+    previous_status VARCHAR(50), -- status to restore from the trash
+    snoozed_until TIMESTAMP WITH TIME ZONE, -- not scheduled before this time
+    hold_until TIMESTAMP WITH TIME ZONE, -- never scheduled before this time, even when pinned
+    pinned BOOLEAN NOT NULL DEFAULT FALSE, -- always in the daily focus until revisited
+    priority_multiplier DOUBLE PRECISION NOT NULL DEFAULT 1.0,
+    platform VARCHAR(50), -- recognized platform of link, '' if unrecognized
+    canonical_key VARCHAR(255), -- platform-unique problem ID used for duplicate detection
+    catalog_problem_id UUID REFERENCES catalog_problems(id) ON DELETE SET NULL,
+    version INTEGER NOT NULL DEFAULT 1, -- bumped on every update; the problem's ETag
+    title_tsv tsvector GENERATED ALWAYS AS (to_tsvector('english', COALESCE(title, ''))) STORED,
+    notes_tsv tsvector GENERATED ALWAYS AS (to_tsvector('english', COALESCE(notes, ''))) STORED
 );
 
 -- Revisit History Table
@@ -31,9 +72,163 @@ CREATE TABLE IF NOT EXISTS revisit_history (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     problem_id UUID NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
     revisited_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    notes TEXT
+    notes TEXT,
+    notes_tsv tsvector GENERATED ALWAYS AS (to_tsvector('english', COALESCE(notes, ''))) STORED
+);
+
+-- Daily Plans Table (one materialized Today's Focus selection per user per day)
+CREATE TABLE IF NOT EXISTS daily_plans (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plan_date DATE NOT NULL,
+    eligible_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, plan_date)
 );
 
+-- Daily Plan Items Table
+CREATE TABLE IF NOT EXISTS daily_plan_items (
+    plan_id UUID NOT NULL REFERENCES daily_plans(id) ON DELETE CASCADE,
+    problem_id UUID REFERENCES problems(id) ON DELETE SET NULL, -- NULL once the problem is purged
+    position INT NOT NULL,
+    -- Snapshot taken when the problem is purged, so past plans keep their items
+    title VARCHAR(255),
+    link TEXT,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_daily_plan_items_plan_problem ON daily_plan_items(plan_id, problem_id);
+
+-- Collections Table (user-defined ordered groups of problems)
+CREATE TABLE IF NOT EXISTS collections (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+-- Collection Items Table
+CREATE TABLE IF NOT EXISTS collection_items (
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    problem_id UUID NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    PRIMARY KEY (collection_id, problem_id)
+);
+
+-- Goals Table (interview date "cram mode"; at most one active goal per user)
+CREATE TABLE IF NOT EXISTS goals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_date DATE NOT NULL,
+    start_date DATE NOT NULL DEFAULT CURRENT_DATE,
+    collection_id UUID REFERENCES collections(id) ON DELETE SET NULL,
+    topic VARCHAR(255),
+    daily_budget INT NOT NULL,
+    min_revisits INT NOT NULL DEFAULT 3, -- K revisits per in-scope problem before target_date
+    weak_topics JSONB NOT NULL DEFAULT '[]',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Solutions Table (versioned solution code per problem, optionally tied to a revisit)
+CREATE TABLE IF NOT EXISTS solutions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    problem_id UUID NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    revisit_id UUID REFERENCES revisit_history(id) ON DELETE SET NULL,
+    version INT NOT NULL, -- 1, 2, ... per problem
+    language VARCHAR(50) NOT NULL,
+    code TEXT NOT NULL,
+    time_complexity VARCHAR(100),
+    space_complexity VARCHAR(100),
+    approach VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (problem_id, version)
+);
+
+-- Flashcards Table (prompt/answer insights per problem; source is manual or notes)
+CREATE TABLE IF NOT EXISTS flashcards (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    problem_id UUID NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    prompt TEXT NOT NULL,
+    answer TEXT NOT NULL,
+    source VARCHAR(10) NOT NULL DEFAULT 'manual',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_reviewed_at TIMESTAMP WITH TIME ZONE,
+    times_reviewed INT NOT NULL DEFAULT 0, -- successful reviews in a row
+    lapses INT NOT NULL DEFAULT 0
+);
+
+-- Flashcard Reviews Table (drill answers)
+CREATE TABLE IF NOT EXISTS flashcard_reviews (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    flashcard_id UUID NOT NULL REFERENCES flashcards(id) ON DELETE CASCADE,
+    reviewed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    remembered BOOLEAN NOT NULL
+);
+
+-- Feed Tokens Table (secret URLs for calendar/feed readers; only a SHA-256 of the token is stored)
+CREATE TABLE IF NOT EXISTS feed_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL, -- calendar, focus or journal
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (user_id, kind)
+);
+
+-- Bump problems.version on every update (optimistic concurrency via If-Match)
+CREATE OR REPLACE FUNCTION bump_problem_version() RETURNS trigger AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS problems_bump_version ON problems;
+CREATE TRIGGER problems_bump_version BEFORE UPDATE ON problems
+    FOR EACH ROW EXECUTE FUNCTION bump_problem_version();
+
+-- Idempotency Keys Table (first response to each keyed POST, replayed on retries; status is NULL while running)
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status INTEGER,
+    content_type TEXT NOT NULL DEFAULT '',
+    body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, key)
+);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at);
+
+-- Rate Limit Buckets Table (token buckets shared by all instances when RATE_LIMIT_STORE=postgres; full again at expires_at)
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key VARCHAR(255) PRIMARY KEY, -- budget name and client IP or user ID, e.g. "user:<uuid>"
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_expires ON rate_limit_buckets(expires_at);
+
 -- Index for scheduling queries
 CREATE INDEX IF NOT EXISTS idx_problems_user_scheduling ON problems(user_id, status, last_revisited_at);
 CREATE INDEX IF NOT EXISTS idx_revisit_history_problem ON revisit_history(problem_id, revisited_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_problems_catalog ON problems(catalog_problem_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_goals_one_active ON goals(user_id) WHERE active;
+CREATE INDEX IF NOT EXISTS idx_solutions_revisit ON solutions(revisit_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_flashcards_notes_prompt ON flashcards(problem_id, prompt) WHERE source = 'notes';
+CREATE INDEX IF NOT EXISTS idx_flashcard_reviews_card ON flashcard_reviews(flashcard_id, reviewed_at DESC);
+
+-- One live problem per canonical link per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_problems_user_canonical_link
+    ON problems(user_id, platform, canonical_key)
+    WHERE canonical_key IS NOT NULL AND status <> 'trashed';
+
+-- Full-text search indexes
+CREATE INDEX IF NOT EXISTS idx_problems_title_tsv ON problems USING GIN (title_tsv);
+CREATE INDEX IF NOT EXISTS idx_problems_notes_tsv ON problems USING GIN (notes_tsv);
+CREATE INDEX IF NOT EXISTS idx_revisit_history_notes_tsv ON revisit_history USING GIN (notes_tsv);
@@ -7,7 +7,7 @@ interface AddProblemModalProps {
     isOpen: boolean;
     onClose: () => void;
     onSuccess?: () => void;
-    problem?: { id: string; title: string; link: string; difficulty?: string; source?: string; notes?: string } | null;
+    problem?: { id: string; version: number; title: string; link: string; difficulty?: string; source?: string; notes?: string } | null;
 }
 
 const AddProblemModal: React.FC<AddProblemModalProps> = ({ isOpen, onClose, onSuccess, problem }) => {
@@ -51,7 +51,7 @@ const AddProblemModal: React.FC<AddProblemModalProps> = ({ isOpen, onClose, onSu
         };
 
         if (problem) {
-            updateMutation.mutate({ id: problem.id, data }, {
+            updateMutation.mutate({ id: problem.id, version: problem.version, data }, {
                 onSuccess: () => {
                     onSuccess?.();
                     onClose();
@@ -4,6 +4,7 @@ import { PlusCircle, Settings as SettingsIcon, LayoutDashboard, Archive, History
 import { UserButton } from '@clerk/clerk-react';
 import AddProblemModal from './AddProblemModal';
 import Logo from './Logo';
+import { useLiveUpdates } from '../hooks/useLiveUpdates';
 
 interface LayoutProps {
     children: React.ReactNode;
@@ -13,6 +14,7 @@ interface LayoutProps {
 const Layout: React.FC<LayoutProps> = ({ children, onProblemAdded }) => {
     const location = useLocation();
     const [isModalOpen, setIsModalOpen] = useState(false);
+    useLiveUpdates();
 
     const handleAddProblemSuccess = () => {
         onProblemAdded?.();
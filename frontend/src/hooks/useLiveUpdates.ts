@@ -0,0 +1,68 @@
+import { useEffect } from 'react';
+import { useAuth } from '@clerk/clerk-react';
+import { useQueryClient } from '@tanstack/react-query';
+import { apiFetch } from '../lib/api';
+import { problemKeys } from './useProblems';
+
+const RECONNECT_DELAY_MS = 5000;
+
+/**
+ * Subscribes to the server's event stream (GET /events) and refreshes cached
+ * queries when the user's data changes in another tab or device.
+ * EventSource can't send the Authorization header, so the stream is read
+ * through fetch instead.
+ */
+export function useLiveUpdates() {
+    const { getToken, isSignedIn } = useAuth();
+    const queryClient = useQueryClient();
+
+    useEffect(() => {
+        if (!isSignedIn) return;
+
+        const controller = new AbortController();
+        let retryTimer: ReturnType<typeof setTimeout> | undefined;
+
+        const handleEvent = (type: string) => {
+            if (type === 'settings.changed') {
+                queryClient.invalidateQueries({ queryKey: ['settings'] });
+            }
+            // Every event can change what the problem lists and today's focus show
+            queryClient.invalidateQueries({ queryKey: problemKeys.all });
+        };
+
+        const connect = async () => {
+            try {
+                const res = await apiFetch('/events', { signal: controller.signal }, getToken);
+                if (!res.ok || !res.body) throw new Error(`Event stream failed: ${res.status}`);
+
+                const reader = res.body.pipeThrough(new TextDecoderStream()).getReader();
+                let buffer = '';
+                for (;;) {
+                    const { value, done } = await reader.read();
+                    if (done) break;
+                    buffer += value;
+
+                    // Frames are separated by a blank line
+                    let sep: number;
+                    while ((sep = buffer.indexOf('\n\n')) >= 0) {
+                        const frame = buffer.slice(0, sep);
+                        buffer = buffer.slice(sep + 2);
+                        const eventLine = frame.split('\n').find((line) => line.startsWith('event: '));
+                        if (eventLine) handleEvent(eventLine.slice('event: '.length));
+                    }
+                }
+            } catch {
+                if (controller.signal.aborted) return;
+            }
+            if (!controller.signal.aborted) {
+                retryTimer = setTimeout(connect, RECONNECT_DELAY_MS);
+            }
+        };
+
+        connect();
+        return () => {
+            controller.abort();
+            clearTimeout(retryTimer);
+        };
+    }, [isSignedIn, getToken, queryClient]);
+}
@@ -1,6 +1,6 @@
 import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
 import { useAuth } from '@clerk/clerk-react';
-import { apiFetch } from '../lib/api';
+import { apiFetch, idempotencyKey, problemETag, retryNetworkErrors } from '../lib/api';
 import { toast } from 'react-toastify';
 
 export interface Problem {
@@ -14,6 +14,7 @@ export interface Problem {
     notes?: string;
     tags?: string[];
     status?: string;
+    version: number;
 }
 
 export interface WeightInfo {
@@ -138,11 +139,18 @@ export function useAddProblemMutation() {
         mutationFn: async (data: Partial<Problem>) => {
             const res = await apiFetch('/problems', {
                 method: 'POST',
+                headers: { 'Idempotency-Key': idempotencyKey(data) },
                 body: JSON.stringify(data),
             }, getToken);
+            if (res.status === 409) {
+                const body = await res.json();
+                const title = body.error?.details?.existing_title;
+                throw new Error(title ? `already tracking "${title}"` : 'already tracking this problem');
+            }
             if (!res.ok) throw new Error('Failed to add problem');
             return res.json();
         },
+        retry: retryNetworkErrors,
         onSuccess: () => {
             queryClient.invalidateQueries({ queryKey: problemKeys.all });
             toast.success('Problem added successfully!');
@@ -158,11 +166,20 @@ export function useUpdateProblemMutation() {
     const queryClient = useQueryClient();
 
     return useMutation({
-        mutationFn: async ({ id, data }: { id: string; data: Partial<Problem> }) => {
+        mutationFn: async ({ id, version, data }: { id: string; version: number; data: Partial<Problem> }) => {
             const res = await apiFetch(`/problems/${id}`, {
                 method: 'PUT',
+                headers: { 'If-Match': problemETag(version) },
                 body: JSON.stringify(data),
             }, getToken);
+            if (res.status === 412) {
+                throw new Error('it was changed elsewhere; reload and try again');
+            }
+            if (res.status === 409) {
+                const body = await res.json();
+                const title = body.error?.details?.existing_title;
+                throw new Error(title ? `link matches "${title}"` : 'link matches another problem');
+            }
             if (!res.ok) throw new Error('Failed to update problem');
             return res.json();
         },
@@ -182,10 +199,14 @@ export function useDeleteProblemMutation() {
     const queryClient = useQueryClient();
 
     return useMutation({
-        mutationFn: async (id: string) => {
+        mutationFn: async ({ id, version }: { id: string; version: number }) => {
             const res = await apiFetch(`/problems/${id}`, {
                 method: 'DELETE',
+                headers: { 'If-Match': problemETag(version) },
             }, getToken);
+            if (res.status === 412) {
+                throw new Error('it was changed elsewhere; reload and try again');
+            }
             if (!res.ok) throw new Error('Failed to delete problem');
             return id;
         },
@@ -204,9 +225,11 @@ export function useRevisitProblemMutation() {
     const queryClient = useQueryClient();
 
     return useMutation({
-        mutationFn: async ({ id, notes }: { id: string; notes?: string }) => {
+        mutationFn: async (variables: { id: string; notes?: string }) => {
+            const { id, notes } = variables;
             const res = await apiFetch(`/problems/${id}/revisit`, {
                 method: 'POST',
+                headers: { 'Idempotency-Key': idempotencyKey(variables) },
                 body: notes ? JSON.stringify({ notes }) : undefined,
             }, getToken);
 
@@ -216,6 +239,7 @@ export function useRevisitProblemMutation() {
 
             return { id, alreadyRevisited: res.status === 409 };
         },
+        retry: retryNetworkErrors,
         onSuccess: (data, variables) => {
             queryClient.invalidateQueries({ queryKey: problemKeys.all });
             queryClient.invalidateQueries({ queryKey: problemKeys.detail(variables.id) });
@@ -36,3 +36,29 @@ export async function apiFetch(
         headers,
     });
 }
+
+/** ETag of a problem version, sent as If-Match when writing the problem. */
+export function problemETag(version: number): string {
+    return `"v${version}"`;
+}
+
+const idempotencyKeys = new WeakMap<object, string>();
+
+/**
+ * Idempotency-Key for a POST, stable per mutation variables object: retries
+ * of one mutate() call reuse it, so the server replays the first response
+ * instead of repeating the write.
+ */
+export function idempotencyKey(variables: object): string {
+    let key = idempotencyKeys.get(variables);
+    if (!key) {
+        key = crypto.randomUUID();
+        idempotencyKeys.set(variables, key);
+    }
+    return key;
+}
+
+/** Retry a keyed POST after a network failure (fetch rejects with TypeError). */
+export function retryNetworkErrors(failureCount: number, error: Error): boolean {
+    return error instanceof TypeError && failureCount < 2;
+}
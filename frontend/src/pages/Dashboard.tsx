@@ -44,8 +44,10 @@ const Dashboard: React.FC = () => {
     };
 
     const handleDelete = async (id: string) => {
+        const problem = problems.find((p) => p.id === id);
         try {
-            await deleteMutation.mutateAsync(id);
+            if (!problem) throw new Error('Problem not found');
+            await deleteMutation.mutateAsync({ id, version: problem.version });
         } catch (error) {
             console.error('Failed to delete problem', error);
         } finally {
@@ -474,8 +476,8 @@ const Dashboard: React.FC = () => {
                     }}
                     onConfirm={() => deletingProblemId && handleDelete(deletingProblemId)}
                     title="Delete Problem"
-                    description="Are you sure you want to permanently delete this problem and its entire revisit history? This action cannot be undone."
-                    confirmLabel={deleteMutation.isPending ? "Deleting..." : "Delete Permanently"}
+                    description="Move this problem to the trash? It will be hidden from your lists and schedule, and can be restored until it is permanently deleted after the retention period."
+                    confirmLabel={deleteMutation.isPending ? "Deleting..." : "Move to Trash"}
                     variant="danger"
                     loading={deleteMutation.isPending}
                 />